@@ -2,7 +2,9 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"monkey/internal/token"
+	"reflect"
 	"strings"
 )
 
@@ -11,11 +13,36 @@ type Node interface {
 	String() string
 }
 
+// 节点是否为空。可选的子节点字段是具体的指针类型，如没有 else 的
+// IfExpression.Alternative，作为 Node 传递时是带类型的 nil，不能直接与 nil 比较
+func IsNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
 type Statement interface {
 	Node
 	statementNode()
 }
 
+// 语句的第一个词法单元，用于报告语句所在的位置
+func StatementToken(stmt Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		return stmt.Token
+	case *ReturnStatement:
+		return stmt.Token
+	case *ExpressionStatement:
+		return stmt.Token
+	case *BlockStatement:
+		return stmt.Token
+	}
+	return token.Token{}
+}
+
 type Expression interface {
 	Node
 	expressionNode()
@@ -73,6 +100,11 @@ func (ls *LetStatement) String() string {
 type Identifier struct {
 	Token token.Token // token.IDENT
 	Value string
+
+	// 由 resolver 填写，Resolved 为 false 时求值器按名字逐层查找
+	Resolved bool
+	Depth    int // 从使用处向外跨过的环境层数
+	Slot     int // 绑定在环境中的槽位，-1 表示按名字查找（全局作用域）
 }
 
 func (i *Identifier) expressionNode() {}
@@ -126,6 +158,7 @@ func (es *ExpressionStatement) String() string {
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // 超出 int64 范围时的值，此时 Value 为 0
 }
 
 func (il *IntegerLiteral) expressionNode() {}
@@ -239,10 +272,39 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+type ForExpression struct {
+	Token    token.Token   // 'for' token
+	Names    []*Identifier // 一个或两个循环变量
+	Iterable Expression
+	Body     *BlockStatement
+	Slots    []string // 由 resolver 填写，循环体作用域中各槽位的名字
+}
+
+func (fe *ForExpression) expressionNode() {}
+func (fe *ForExpression) TokenLiteral() string {
+	return fe.Token.Literal
+}
+func (fe *ForExpression) String() string {
+	var out bytes.Buffer
+
+	names := []string{}
+	for _, n := range fe.Names {
+		names = append(names, n.String())
+	}
+	out.WriteString("for (")
+	out.WriteString(strings.Join(names, ", "))
+	out.WriteString(" in ")
+	out.WriteString(fe.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fe.Body.String())
+	return out.String()
+}
+
 type FunctionLiteral struct {
 	Token      token.Token // 'fn' token
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Slots      []string // 由 resolver 填写，函数作用域中各槽位的名字
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -307,6 +369,28 @@ func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
 
+// 模板字符串 "a ${x} b"，Parts 由 *StringLiteral 文本片段与插值表达式交替组成
+type TemplateLiteral struct {
+	Token token.Token // the TEMPLATE_HEAD token
+	Parts []Expression
+}
+
+func (tl *TemplateLiteral) expressionNode()      {}
+func (tl *TemplateLiteral) TokenLiteral() string { return tl.Token.Literal }
+func (tl *TemplateLiteral) String() string {
+	var out bytes.Buffer
+
+	for _, part := range tl.Parts {
+		if text, ok := part.(*StringLiteral); ok {
+			out.WriteString(text.Value)
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
@@ -352,9 +436,44 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+type SliceExpression struct {
+	Token token.Token // The [ token
+	Left  Expression
+	Start Expression // 可以为空，表示从头开始
+	End   Expression // 可以为空，表示直到末尾
+}
+
+func (se *SliceExpression) expressionNode() {}
+func (se *SliceExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
 type HashLiteral struct {
 	Token token.Token // The '{' token
-	Pairs map[Expression]Expression
+	Pairs []HashPair  // 按源码顺序排列
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -363,8 +482,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 
 	out.WriteString("{")
@@ -0,0 +1,380 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"monkey/internal/token"
+)
+
+// JSON 中的一个节点，kind 为节点的类型名，其余字段按类型取用，没有的字段省略
+type jsonNode struct {
+	Kind  string     `json:"kind"`
+	Token *jsonToken `json:"token,omitempty"`
+
+	Value    json.RawMessage `json:"value,omitempty"` // 字面量与标识符的值；LetStatement、ReturnStatement 的值为子节点
+	Operator string          `json:"operator,omitempty"`
+
+	Name        *jsonNode `json:"name,omitempty"`
+	Expression  *jsonNode `json:"expression,omitempty"`
+	Left        *jsonNode `json:"left,omitempty"`
+	Right       *jsonNode `json:"right,omitempty"`
+	Condition   *jsonNode `json:"condition,omitempty"`
+	Consequence *jsonNode `json:"consequence,omitempty"`
+	Alternative *jsonNode `json:"alternative,omitempty"`
+	Iterable    *jsonNode `json:"iterable,omitempty"`
+	Function    *jsonNode `json:"function,omitempty"`
+	Index       *jsonNode `json:"index,omitempty"`
+	Start       *jsonNode `json:"start,omitempty"`
+	End         *jsonNode `json:"end,omitempty"`
+	Body        *jsonNode `json:"body,omitempty"`
+
+	Statements []*jsonNode `json:"statements,omitempty"`
+	Names      []*jsonNode `json:"names,omitempty"`
+	Parameters []*jsonNode `json:"parameters,omitempty"`
+	Arguments  []*jsonNode `json:"arguments,omitempty"`
+	Elements   []*jsonNode `json:"elements,omitempty"`
+	Parts      []*jsonNode `json:"parts,omitempty"`
+	Pairs      []jsonPair  `json:"pairs,omitempty"`
+
+	// resolver 的标注
+	Resolved bool     `json:"resolved,omitempty"`
+	Depth    int      `json:"depth,omitempty"`
+	Slot     int      `json:"slot,omitempty"`
+	Slots    []string `json:"slots,omitempty"`
+}
+
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Pos     token.Position  `json:"pos"`
+	End     token.Position  `json:"end"`
+}
+
+type jsonPair struct {
+	Key   *jsonNode `json:"key"`
+	Value *jsonNode `json:"value"`
+}
+
+// 把节点及其全部子节点编码为 JSON，包括词法单元、位置与 resolver 的标注
+func EncodeJSON(node Node) ([]byte, error) {
+	n, err := toJSON(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(n)
+}
+
+func toJSON(node Node) (*jsonNode, error) {
+	if IsNil(node) {
+		return nil, nil
+	}
+	var err error
+	child := func(n Node) *jsonNode {
+		var j *jsonNode
+		if err == nil {
+			j, err = toJSON(n)
+		}
+		return j
+	}
+	value := func(v interface{}) json.RawMessage {
+		data, e := json.Marshal(v)
+		if err == nil {
+			err = e
+		}
+		return data
+	}
+	tok := func(t token.Token) *jsonToken {
+		return &jsonToken{t.Type, t.Literal, t.Pos, t.End}
+	}
+
+	var n *jsonNode
+	switch node := node.(type) {
+	case *Program:
+		n = &jsonNode{Kind: "Program", Statements: []*jsonNode{}}
+		for _, s := range node.Statements {
+			n.Statements = append(n.Statements, child(s))
+		}
+	case *LetStatement:
+		n = &jsonNode{Kind: "LetStatement", Token: tok(node.Token), Name: child(node.Name)}
+		if v := child(node.Value); v != nil {
+			n.Value = value(v)
+		}
+	case *ReturnStatement:
+		n = &jsonNode{Kind: "ReturnStatement", Token: tok(node.Token)}
+		if v := child(node.ReturnValue); v != nil {
+			n.Value = value(v)
+		}
+	case *ExpressionStatement:
+		n = &jsonNode{Kind: "ExpressionStatement", Token: tok(node.Token), Expression: child(node.Expression)}
+	case *BlockStatement:
+		n = &jsonNode{Kind: "BlockStatement", Token: tok(node.Token), Statements: []*jsonNode{}}
+		for _, s := range node.Statements {
+			n.Statements = append(n.Statements, child(s))
+		}
+	case *Identifier:
+		n = &jsonNode{Kind: "Identifier", Token: tok(node.Token), Value: value(node.Value),
+			Resolved: node.Resolved, Depth: node.Depth, Slot: node.Slot}
+	case *IntegerLiteral:
+		n = &jsonNode{Kind: "IntegerLiteral", Token: tok(node.Token), Value: value(node.Value)}
+		if node.Big != nil {
+			n.Value = value(node.Big)
+		}
+	case *Boolean:
+		n = &jsonNode{Kind: "Boolean", Token: tok(node.Token), Value: value(node.Value)}
+	case *StringLiteral:
+		n = &jsonNode{Kind: "StringLiteral", Token: tok(node.Token), Value: value(node.Value)}
+	case *TemplateLiteral:
+		n = &jsonNode{Kind: "TemplateLiteral", Token: tok(node.Token), Parts: []*jsonNode{}}
+		for _, p := range node.Parts {
+			n.Parts = append(n.Parts, child(p))
+		}
+	case *PrefixExpression:
+		n = &jsonNode{Kind: "PrefixExpression", Token: tok(node.Token), Operator: node.Operator, Right: child(node.Right)}
+	case *InfixExpression:
+		n = &jsonNode{Kind: "InfixExpression", Token: tok(node.Token), Left: child(node.Left), Operator: node.Operator, Right: child(node.Right)}
+	case *IfExpression:
+		n = &jsonNode{Kind: "IfExpression", Token: tok(node.Token), Condition: child(node.Condition),
+			Consequence: child(node.Consequence), Alternative: child(node.Alternative)}
+	case *ForExpression:
+		n = &jsonNode{Kind: "ForExpression", Token: tok(node.Token), Names: []*jsonNode{},
+			Iterable: child(node.Iterable), Body: child(node.Body), Slots: node.Slots}
+		for _, name := range node.Names {
+			n.Names = append(n.Names, child(name))
+		}
+	case *FunctionLiteral:
+		n = &jsonNode{Kind: "FunctionLiteral", Token: tok(node.Token), Parameters: []*jsonNode{}, Body: child(node.Body), Slots: node.Slots}
+		for _, p := range node.Parameters {
+			n.Parameters = append(n.Parameters, child(p))
+		}
+	case *CallExpression:
+		n = &jsonNode{Kind: "CallExpression", Token: tok(node.Token), Function: child(node.Function), Arguments: []*jsonNode{}}
+		for _, a := range node.Arguments {
+			n.Arguments = append(n.Arguments, child(a))
+		}
+	case *ArrayLiteral:
+		n = &jsonNode{Kind: "ArrayLiteral", Token: tok(node.Token), Elements: []*jsonNode{}}
+		for _, e := range node.Elements {
+			n.Elements = append(n.Elements, child(e))
+		}
+	case *IndexExpression:
+		n = &jsonNode{Kind: "IndexExpression", Token: tok(node.Token), Left: child(node.Left), Index: child(node.Index)}
+	case *SliceExpression:
+		n = &jsonNode{Kind: "SliceExpression", Token: tok(node.Token), Left: child(node.Left), Start: child(node.Start), End: child(node.End)}
+	case *HashLiteral:
+		n = &jsonNode{Kind: "HashLiteral", Token: tok(node.Token), Pairs: []jsonPair{}}
+		for _, p := range node.Pairs {
+			n.Pairs = append(n.Pairs, jsonPair{child(p.Key), child(p.Value)})
+		}
+	default:
+		return nil, fmt.Errorf("cannot encode %T", node)
+	}
+	return n, err
+}
+
+// 从 EncodeJSON 的输出重建节点
+func DecodeJSON(data []byte) (Node, error) {
+	var n jsonNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	return fromJSON(&n, "")
+}
+
+// 从 JSON 重建程序，顶层节点必须是 Program
+func DecodeProgram(data []byte) (*Program, error) {
+	node, err := DecodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	program, ok := node.(*Program)
+	if !ok {
+		return nil, fmt.Errorf("expected Program, got %T", node)
+	}
+	return program, nil
+}
+
+// path 为节点在 JSON 中的位置，用于错误信息
+func fromJSON(n *jsonNode, path string) (Node, error) {
+	var err error
+	fail := func(format string, args ...interface{}) {
+		if err == nil {
+			err = fmt.Errorf("%s: %s", pathOrRoot(path), fmt.Sprintf(format, args...))
+		}
+	}
+	node := func(j *jsonNode, field string) Node {
+		if j == nil || err != nil {
+			return nil
+		}
+		var child Node
+		child, err = fromJSON(j, path+"."+field)
+		return child
+	}
+	expr := func(j *jsonNode, field string, required bool) Expression {
+		child := node(j, field)
+		if child == nil {
+			if required {
+				fail("missing %s", field)
+			}
+			return nil
+		}
+		e, ok := child.(Expression)
+		if !ok {
+			fail("%s must be an expression, got %s", field, j.Kind)
+		}
+		return e
+	}
+	exprs := func(js []*jsonNode, field string) []Expression {
+		list := []Expression{}
+		for i, j := range js {
+			list = append(list, expr(j, fmt.Sprintf("%s[%d]", field, i), true))
+		}
+		return list
+	}
+	stmts := func(js []*jsonNode) []Statement {
+		list := []Statement{}
+		for i, j := range js {
+			field := fmt.Sprintf("statements[%d]", i)
+			child := node(j, field)
+			s, ok := child.(Statement)
+			if !ok {
+				fail("%s must be a statement", field)
+			}
+			list = append(list, s)
+		}
+		return list
+	}
+	ident := func(j *jsonNode, field string) *Identifier {
+		child := node(j, field)
+		id, ok := child.(*Identifier)
+		if !ok {
+			fail("%s must be an Identifier", field)
+		}
+		return id
+	}
+	idents := func(js []*jsonNode, field string) []*Identifier {
+		list := []*Identifier{}
+		for i, j := range js {
+			list = append(list, ident(j, fmt.Sprintf("%s[%d]", field, i)))
+		}
+		return list
+	}
+	block := func(j *jsonNode, field string, required bool) *BlockStatement {
+		child := node(j, field)
+		if child == nil {
+			if required {
+				fail("missing %s", field)
+			}
+			return nil
+		}
+		b, ok := child.(*BlockStatement)
+		if !ok {
+			fail("%s must be a BlockStatement, got %s", field, j.Kind)
+		}
+		return b
+	}
+	value := func(v interface{}) {
+		if len(n.Value) == 0 {
+			fail("missing value")
+		} else if e := json.Unmarshal(n.Value, v); e != nil {
+			fail("value: %s", e)
+		}
+	}
+	valueNode := func() *jsonNode {
+		if len(n.Value) == 0 {
+			return nil
+		}
+		var j jsonNode
+		if e := json.Unmarshal(n.Value, &j); e != nil {
+			fail("value: %s", e)
+			return nil
+		}
+		return &j
+	}
+	var tok token.Token
+	if n.Token != nil {
+		tok = token.Token{Type: n.Token.Type, Literal: n.Token.Literal, Pos: n.Token.Pos, End: n.Token.End}
+	} else if n.Kind != "Program" {
+		fail("missing token")
+	}
+
+	var result Node
+	switch n.Kind {
+	case "Program":
+		result = &Program{Statements: stmts(n.Statements)}
+	case "LetStatement":
+		result = &LetStatement{Token: tok, Name: ident(n.Name, "name"), Value: expr(valueNode(), "value", false)}
+	case "ReturnStatement":
+		result = &ReturnStatement{Token: tok, ReturnValue: expr(valueNode(), "value", false)}
+	case "ExpressionStatement":
+		result = &ExpressionStatement{Token: tok, Expression: expr(n.Expression, "expression", true)}
+	case "BlockStatement":
+		result = &BlockStatement{Token: tok, Statements: stmts(n.Statements)}
+	case "Identifier":
+		id := &Identifier{Token: tok, Resolved: n.Resolved, Depth: n.Depth, Slot: n.Slot}
+		value(&id.Value)
+		result = id
+	case "IntegerLiteral":
+		il := &IntegerLiteral{Token: tok}
+		v := new(big.Int)
+		value(v)
+		if v.IsInt64() {
+			il.Value = v.Int64()
+		} else {
+			il.Big = v
+		}
+		result = il
+	case "Boolean":
+		b := &Boolean{Token: tok}
+		value(&b.Value)
+		result = b
+	case "StringLiteral":
+		s := &StringLiteral{Token: tok}
+		value(&s.Value)
+		result = s
+	case "TemplateLiteral":
+		result = &TemplateLiteral{Token: tok, Parts: exprs(n.Parts, "parts")}
+	case "PrefixExpression":
+		result = &PrefixExpression{Token: tok, Operator: n.Operator, Right: expr(n.Right, "right", true)}
+	case "InfixExpression":
+		result = &InfixExpression{Token: tok, Left: expr(n.Left, "left", true), Operator: n.Operator, Right: expr(n.Right, "right", true)}
+	case "IfExpression":
+		result = &IfExpression{Token: tok, Condition: expr(n.Condition, "condition", true),
+			Consequence: block(n.Consequence, "consequence", true), Alternative: block(n.Alternative, "alternative", false)}
+	case "ForExpression":
+		result = &ForExpression{Token: tok, Names: idents(n.Names, "names"), Iterable: expr(n.Iterable, "iterable", true),
+			Body: block(n.Body, "body", true), Slots: n.Slots}
+	case "FunctionLiteral":
+		result = &FunctionLiteral{Token: tok, Parameters: idents(n.Parameters, "parameters"), Body: block(n.Body, "body", true), Slots: n.Slots}
+	case "CallExpression":
+		result = &CallExpression{Token: tok, Function: expr(n.Function, "function", true), Arguments: exprs(n.Arguments, "arguments")}
+	case "ArrayLiteral":
+		result = &ArrayLiteral{Token: tok, Elements: exprs(n.Elements, "elements")}
+	case "IndexExpression":
+		result = &IndexExpression{Token: tok, Left: expr(n.Left, "left", true), Index: expr(n.Index, "index", true)}
+	case "SliceExpression":
+		result = &SliceExpression{Token: tok, Left: expr(n.Left, "left", true), Start: expr(n.Start, "start", false), End: expr(n.End, "end", false)}
+	case "HashLiteral":
+		h := &HashLiteral{Token: tok, Pairs: []HashPair{}}
+		for i, p := range n.Pairs {
+			h.Pairs = append(h.Pairs, HashPair{
+				Key:   expr(p.Key, fmt.Sprintf("pairs[%d].key", i), true),
+				Value: expr(p.Value, fmt.Sprintf("pairs[%d].value", i), true),
+			})
+		}
+		result = h
+	default:
+		fail("unknown node kind %q", n.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "root"
+	}
+	return "root" + path
+}
@@ -0,0 +1,114 @@
+package ast_test
+
+import (
+	"bytes"
+	"monkey/internal/ast"
+	"monkey/internal/evaluator"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"strings"
+	"testing"
+)
+
+// 覆盖所有节点类型的程序
+const program = `let add = fn(a, b) { return a + b; };
+let xs = [1, 2, 3];
+let h = {"one": 1, true: !false, 2: -3};
+let total = 0;
+for (i, x in xs) { let total = total + x * i; total };
+let s = "x = ${add(1, 2)} and ${xs[1:]}";
+let r = if (len(xs) > 2) { xs[0] } else { h["one"] };
+let q = quote(1 + 2);
+let m = import("math");
+let big = 18446744073709551616;
+[add(1, 2), total, s, r, xs[:2], h[true], h[2], q, m["max"](3, 4), fn() { return 5; }(), big]
+`
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return prog
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, resolved := range []bool{false, true} {
+		prog := parse(t, program)
+		if resolved {
+			resolver.Resolve(prog, nil)
+		}
+		data, err := ast.EncodeJSON(prog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := ast.DecodeProgram(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		again, err := ast.EncodeJSON(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Errorf("re-encoding differs.\nfirst=%s\nsecond=%s", data, again)
+		}
+		if decoded.String() != prog.String() {
+			t.Errorf("decoded program differs.\nwant=%s\ngot=%s", prog.String(), decoded.String())
+		}
+
+		want := evaluator.Eval(prog, object.NewEnvironment()).Inspect()
+		got := evaluator.Eval(decoded, object.NewEnvironment()).Inspect()
+		if want != got || strings.HasPrefix(got, "ERROR") {
+			t.Errorf("decoded program evaluates differently.\nwant=%s\ngot=%s", want, got)
+		}
+	}
+}
+
+func TestJSONFields(t *testing.T) {
+	prog := parse(t, "let f = fn(x) { x };")
+	resolver.Resolve(prog, nil)
+	data, err := ast.EncodeJSON(prog.Statements[0].(*ast.LetStatement).Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"kind":"FunctionLiteral","token":{"type":"FUNCTION","literal":"fn","pos":{"offset":8,"line":1,"column":9},"end":{"offset":10,"line":1,"column":11}},` +
+		`"body":{"kind":"BlockStatement","token":{"type":"{","literal":"{","pos":{"offset":14,"line":1,"column":15},"end":{"offset":15,"line":1,"column":16}},` +
+		`"statements":[{"kind":"ExpressionStatement","token":{"type":"IDENT","literal":"x","pos":{"offset":16,"line":1,"column":17},"end":{"offset":17,"line":1,"column":18}},` +
+		`"expression":{"kind":"Identifier","token":{"type":"IDENT","literal":"x","pos":{"offset":16,"line":1,"column":17},"end":{"offset":17,"line":1,"column":18}},"value":"x","resolved":true}}]},` +
+		`"parameters":[{"kind":"Identifier","token":{"type":"IDENT","literal":"x","pos":{"offset":11,"line":1,"column":12},"end":{"offset":12,"line":1,"column":13}},"value":"x","resolved":true}],"slots":["x"]}`
+	if string(data) != want {
+		t.Errorf("wrong encoding.\nwant=%s\ngot=%s", want, data)
+	}
+}
+
+func TestJSONDecodeErrors(t *testing.T) {
+	tok := `"token":{"type":"INT","literal":"1"}`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[]`, "cannot unmarshal array"},
+		{`{"kind":"Nope",` + tok + `}`, `root: unknown node kind "Nope"`},
+		{`{"kind":"IntegerLiteral"}`, "root: missing token"},
+		{`{"kind":"IntegerLiteral",` + tok + `}`, "root: missing value"},
+		{`{"kind":"IntegerLiteral",` + tok + `,"value":"1"}`, "root: value: math/big: cannot unmarshal"},
+		{`{"kind":"Program","statements":[{"kind":"IntegerLiteral",` + tok + `,"value":1}]}`, "root: statements[0] must be a statement"},
+		{`{"kind":"ExpressionStatement",` + tok + `}`, "root: missing expression"},
+		{`{"kind":"InfixExpression",` + tok + `,"operator":"+","left":{"kind":"IntegerLiteral",` + tok + `,"value":1},"right":{"kind":"IntegerLiteral",` + tok + `}}`, "root.right: missing value"},
+		{`{"kind":"FunctionLiteral",` + tok + `,"parameters":[{"kind":"Boolean",` + tok + `,"value":true}],"body":{"kind":"BlockStatement",` + tok + `}}`, "root: parameters[0] must be an Identifier"},
+		{`{"kind":"IntegerLiteral",` + tok + `,"value":1}`, "expected Program, got *ast.IntegerLiteral"},
+	}
+
+	for _, tt := range tests {
+		_, err := ast.DecodeProgram([]byte(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package ast
+
+import (
+	"fmt"
+)
+
+// Walk 对每个节点调用 Visit，返回的 w 不为 nil 时用 w 遍历子节点，最后调用 w.Visit(nil)
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// 深度优先遍历语法树，与 go/ast.Walk 相同。子节点按源码顺序访问，跳过为空的子节点
+func Walk(v Visitor, node Node) {
+	if IsNil(node) {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+	case *ReturnStatement:
+		Walk(v, n.ReturnValue)
+	case *ExpressionStatement:
+		Walk(v, n.Expression)
+	case *PrefixExpression:
+		Walk(v, n.Right)
+	case *InfixExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *IfExpression:
+		Walk(v, n.Condition)
+		Walk(v, n.Consequence)
+		Walk(v, n.Alternative)
+	case *ForExpression:
+		walkIdentifiers(v, n.Names)
+		Walk(v, n.Iterable)
+		Walk(v, n.Body)
+	case *FunctionLiteral:
+		walkIdentifiers(v, n.Parameters)
+		Walk(v, n.Body)
+	case *CallExpression:
+		Walk(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *TemplateLiteral:
+		walkExpressions(v, n.Parts)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *IndexExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Index)
+	case *SliceExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Start)
+		Walk(v, n.End)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			Walk(v, pair.Key)
+			Walk(v, pair.Value)
+		}
+	case *Identifier, *IntegerLiteral, *Boolean, *StringLiteral:
+		// 没有子节点
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, list []Statement) {
+	for _, s := range list {
+		Walk(v, s)
+	}
+}
+
+func walkExpressions(v Visitor, list []Expression) {
+	for _, e := range list {
+		Walk(v, e)
+	}
+}
+
+func walkIdentifiers(v Visitor, list []*Identifier) {
+	for _, ident := range list {
+		Walk(v, ident)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// 深度优先遍历语法树，f(node) 返回 true 时进入子节点，访问完子节点后调用 f(nil)
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// 自底向上替换节点：先替换子节点，再把 node 交给 f，返回 f 的结果。
+// 语法树在原处修改。f 返回 nil 时从列表中删除该节点（哈希中删除整个键值对），
+// 单个子节点则置为空；返回的节点类型与字段不符时 panic
+func Rewrite(node Node, f func(Node) Node) Node {
+	if IsNil(node) {
+		return node
+	}
+	expr := func(e Expression) Expression {
+		if IsNil(e) {
+			return e
+		}
+		return asExpression(Rewrite(e, f), e)
+	}
+	block := func(b *BlockStatement) *BlockStatement {
+		if b == nil {
+			return nil
+		}
+		return asBlock(Rewrite(b, f), b)
+	}
+	ident := func(i *Identifier) *Identifier {
+		if i == nil {
+			return nil
+		}
+		return asIdentifier(Rewrite(i, f), i)
+	}
+	stmts := func(list []Statement) []Statement {
+		out := list[:0]
+		for _, s := range list {
+			if r := Rewrite(s, f); r != nil {
+				out = append(out, asStatement(r, s))
+			}
+		}
+		return out
+	}
+	exprs := func(list []Expression) []Expression {
+		out := list[:0]
+		for _, e := range list {
+			if r := expr(e); r != nil || IsNil(e) {
+				out = append(out, r)
+			}
+		}
+		return out
+	}
+	idents := func(list []*Identifier) []*Identifier {
+		out := list[:0]
+		for _, i := range list {
+			if r := ident(i); r != nil || i == nil {
+				out = append(out, r)
+			}
+		}
+		return out
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = stmts(n.Statements)
+	case *BlockStatement:
+		n.Statements = stmts(n.Statements)
+	case *LetStatement:
+		n.Name = ident(n.Name)
+		n.Value = expr(n.Value)
+	case *ReturnStatement:
+		n.ReturnValue = expr(n.ReturnValue)
+	case *ExpressionStatement:
+		n.Expression = expr(n.Expression)
+	case *PrefixExpression:
+		n.Right = expr(n.Right)
+	case *InfixExpression:
+		n.Left = expr(n.Left)
+		n.Right = expr(n.Right)
+	case *IfExpression:
+		n.Condition = expr(n.Condition)
+		n.Consequence = block(n.Consequence)
+		n.Alternative = block(n.Alternative)
+	case *ForExpression:
+		n.Names = idents(n.Names)
+		n.Iterable = expr(n.Iterable)
+		n.Body = block(n.Body)
+	case *FunctionLiteral:
+		n.Parameters = idents(n.Parameters)
+		n.Body = block(n.Body)
+	case *CallExpression:
+		n.Function = expr(n.Function)
+		n.Arguments = exprs(n.Arguments)
+	case *TemplateLiteral:
+		n.Parts = exprs(n.Parts)
+	case *ArrayLiteral:
+		n.Elements = exprs(n.Elements)
+	case *IndexExpression:
+		n.Left = expr(n.Left)
+		n.Index = expr(n.Index)
+	case *SliceExpression:
+		n.Left = expr(n.Left)
+		n.Start = expr(n.Start)
+		n.End = expr(n.End)
+	case *HashLiteral:
+		pairs := n.Pairs[:0]
+		for _, pair := range n.Pairs {
+			key, value := expr(pair.Key), expr(pair.Value)
+			if (key == nil && !IsNil(pair.Key)) || (value == nil && !IsNil(pair.Value)) {
+				continue
+			}
+			pairs = append(pairs, HashPair{Key: key, Value: value})
+		}
+		n.Pairs = pairs
+	case *Identifier, *IntegerLiteral, *Boolean, *StringLiteral:
+	default:
+		panic(fmt.Sprintf("ast.Rewrite: unexpected node type %T", n))
+	}
+	return f(node)
+}
+
+func asExpression(n Node, old Node) Expression {
+	if n == nil {
+		return nil
+	}
+	e, ok := n.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace %T with %T, want an Expression", old, n))
+	}
+	return e
+}
+
+func asStatement(n Node, old Node) Statement {
+	s, ok := n.(Statement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace %T with %T, want a Statement", old, n))
+	}
+	return s
+}
+
+func asBlock(n Node, old Node) *BlockStatement {
+	if n == nil {
+		return nil
+	}
+	b, ok := n.(*BlockStatement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace %T with %T, want a *BlockStatement", old, n))
+	}
+	return b
+}
+
+func asIdentifier(n Node, old Node) *Identifier {
+	if n == nil {
+		return nil
+	}
+	i, ok := n.(*Identifier)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace %T with %T, want an *Identifier", old, n))
+	}
+	return i
+}
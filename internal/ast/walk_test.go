@@ -0,0 +1,256 @@
+package ast_test
+
+import (
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/token"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 按类型名与字面量记录访问顺序，子节点访问完时记为 ")"
+type recorder struct {
+	visits []string
+}
+
+func (r *recorder) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		r.visits = append(r.visits, ")")
+		return nil
+	}
+	name := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	if lit := node.TokenLiteral(); lit != "" && name != "Program" {
+		name += " " + lit
+	}
+	r.visits = append(r.visits, name)
+	return r
+}
+
+func TestWalk(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;", "Program, LetStatement let, Identifier x, ), IntegerLiteral 1, ), ), )"},
+		{"return true;", "Program, ReturnStatement return, Boolean true, ), ), )"},
+		{`-"a" * b`, "Program, ExpressionStatement -, InfixExpression *, PrefixExpression -, StringLiteral a, ), ), Identifier b, ), ), ), )"},
+		{"if (a) { b } else { c }", "Program, ExpressionStatement if, IfExpression if, Identifier a, ), BlockStatement {, ExpressionStatement b, Identifier b, ), ), ), BlockStatement {, ExpressionStatement c, Identifier c, ), ), ), ), ), )"},
+		{"if (a) { b }", "Program, ExpressionStatement if, IfExpression if, Identifier a, ), BlockStatement {, ExpressionStatement b, Identifier b, ), ), ), ), ), )"},
+		{"for (i, x in xs) { x }", "Program, ExpressionStatement for, ForExpression for, Identifier i, ), Identifier x, ), Identifier xs, ), BlockStatement {, ExpressionStatement x, Identifier x, ), ), ), ), ), )"},
+		{"fn(a, b) { a }", "Program, ExpressionStatement fn, FunctionLiteral fn, Identifier a, ), Identifier b, ), BlockStatement {, ExpressionStatement a, Identifier a, ), ), ), ), ), )"},
+		{"f(1, 2)", "Program, ExpressionStatement f, CallExpression (, Identifier f, ), IntegerLiteral 1, ), IntegerLiteral 2, ), ), ), )"},
+		{`"a${b}"`, "Program, ExpressionStatement a, TemplateLiteral a, StringLiteral a, ), Identifier b, ), ), ), )"},
+		{"[1][0]", "Program, ExpressionStatement [, IndexExpression [, ArrayLiteral [, IntegerLiteral 1, ), ), IntegerLiteral 0, ), ), ), )"},
+		{"a[1:]", "Program, ExpressionStatement a, SliceExpression [, Identifier a, ), IntegerLiteral 1, ), ), ), )"},
+		{`{"k": 1, 2: v}`, "Program, ExpressionStatement {, HashLiteral {, StringLiteral k, ), IntegerLiteral 1, ), IntegerLiteral 2, ), Identifier v, ), ), ), )"},
+	}
+
+	for _, tt := range tests {
+		r := &recorder{}
+		ast.Walk(r, parse(t, tt.input))
+		if got := strings.Join(r.visits, ", "); got != tt.expected {
+			t.Errorf("%s: wrong visits.\nwant=%s\ngot=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	program := parse(t, "let f = fn(x) { x + 1 }; f(2) + 3")
+
+	// 不进入函数字面量
+	var ints []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.IntegerLiteral:
+			ints = append(ints, node.String())
+		}
+		return true
+	})
+	if got := strings.Join(ints, " "); got != "2 3" {
+		t.Errorf("wrong integers. got=%q", got)
+	}
+
+	// 每个返回 true 的节点之后都有一次 f(nil)
+	depth, max := 0, 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		depth++
+		if depth > max {
+			max = depth
+		}
+		return true
+	})
+	if depth != 0 || max != 7 {
+		t.Errorf("wrong depth. depth=%d, max=%d", depth, max)
+	}
+}
+
+// 把整数加倍
+func double(node ast.Node) ast.Node {
+	if il, ok := node.(*ast.IntegerLiteral); ok {
+		tok := il.Token
+		tok.Literal = strconv.FormatInt(il.Value*2, 10)
+		return &ast.IntegerLiteral{Token: tok, Value: il.Value * 2}
+	}
+	return node
+}
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;", "let x = 2;"},
+		{"return 1;", "return 2;"},
+		{"-1 + 2 * 3", "((-2) + (4 * 6))"},
+		{"if (1) { 2 } else { 3 }", "if2 4else 6"},
+		{"for (x in [1, 2]) { 3 }", "for (x in [2, 4]) 6"},
+		{"fn(x) { 1; return 2; }", "fn(x)2return 4;"},
+		{"f(1, g(2))", "f(2, g(4))"},
+		{`"a${1}b"`, "a${2}b"},
+		{"[1, 2][3]", "([2, 4][6])"},
+		{"a[1:2]", "(a[2:4])"},
+		{"{1: 2, 3: 4}", "{2:4, 6:8}"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		if got := ast.Rewrite(program, double).String(); got != tt.expected {
+			t.Errorf("%s: wrong result.\nwant=%s\ngot=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestRewriteBottomUp(t *testing.T) {
+	// 先替换子节点，所以这里看到的是已经折叠过的常量
+	fold := func(node ast.Node) ast.Node {
+		ie, ok := node.(*ast.InfixExpression)
+		if !ok || ie.Operator != "+" {
+			return node
+		}
+		left, lok := ie.Left.(*ast.IntegerLiteral)
+		right, rok := ie.Right.(*ast.IntegerLiteral)
+		if !lok || !rok {
+			return node
+		}
+		tok := left.Token
+		tok.Literal = strconv.FormatInt(left.Value+right.Value, 10)
+		return &ast.IntegerLiteral{Token: tok, Value: left.Value + right.Value}
+	}
+	program := parse(t, "1 + 2 + 3 + x")
+	if got := ast.Rewrite(program, fold).String(); got != "(6 + x)" {
+		t.Errorf("wrong result. got=%s", got)
+	}
+}
+
+func TestRewriteDelete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// 删除列表中的元素
+		{"1; drop; 2", "12"},
+		{"fn() { drop; 1 }", "fn()1"},
+		{"if (x) { 1 } else { drop }", "ifx 1else "},
+		{"[1, drop, 2]", "[1, 2]"},
+		{"f(drop, 1)", "f(1)"},
+		{`{"a": 1, "b": drop, drop: 2, "c": 3}`, "{a:1, c:3}"},
+		// 删除单个子节点
+		{"return drop;", "return ;"},
+		{"a[drop:1]", "(a[:1])"},
+	}
+
+	drop := func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			if node.Value == "drop" {
+				return nil
+			}
+		case *ast.ExpressionStatement:
+			if node.Expression == nil {
+				return nil
+			}
+		}
+		return node
+	}
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		if got := ast.Rewrite(program, drop).String(); got != tt.expected {
+			t.Errorf("%s: wrong result.\nwant=%s\ngot=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestRewriteAlternativeAndBody(t *testing.T) {
+	// 替换整个块
+	program := parse(t, "let f = fn() { if (c) { 1 } else { 2 } };")
+	tok := token.Token{Type: token.LBRACE, Literal: "{"}
+	replaced := 0
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		if _, ok := node.(*ast.BlockStatement); ok {
+			replaced++
+			return &ast.BlockStatement{Token: tok, Statements: []ast.Statement{
+				&ast.ExpressionStatement{Expression: &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: fmt.Sprint("block", replaced)}}},
+			}}
+		}
+		return node
+	})
+	// 先是 if 的两个分支，最后是函数体
+	if replaced != 3 {
+		t.Errorf("wrong number of blocks. got=%d", replaced)
+	}
+	fn := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if got := fn.Body.String(); got != "block3" {
+		t.Errorf("wrong body. got=%s", got)
+	}
+	if got := program.String(); got != "let f = fn()block3;" {
+		t.Errorf("wrong program. got=%s", got)
+	}
+}
+
+func TestRewritePanics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (c) { 1 }", "cannot replace *ast.BlockStatement with *ast.IntegerLiteral, want a *BlockStatement"},
+		{"let x = 1;", "cannot replace *ast.Identifier with *ast.IntegerLiteral, want an *Identifier"},
+		{"1", "cannot replace *ast.ExpressionStatement with *ast.IntegerLiteral, want a Statement"},
+		{"-x", "cannot replace *ast.Identifier with *ast.BlockStatement, want an Expression"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(fmt.Sprint(r), tt.expected) {
+					t.Errorf("%s: expected panic %q, got %v", tt.input, tt.expected, r)
+				}
+			}()
+			ast.Rewrite(program, func(node ast.Node) ast.Node {
+				one := &ast.IntegerLiteral{Value: 1}
+				switch node := node.(type) {
+				case *ast.BlockStatement:
+					return one
+				case *ast.Identifier:
+					if tt.input == "-x" {
+						return &ast.BlockStatement{}
+					}
+					return one
+				case *ast.ExpressionStatement:
+					if _, ok := node.Expression.(*ast.IntegerLiteral); ok && tt.input == "1" {
+						return one
+					}
+				}
+				return node
+			})
+		}()
+	}
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/internal/ast"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"os"
+	"strings"
+)
+
+// monkey ast [-json] [-resolve] [file.mk]，输出语法树，没有文件时读取标准输入
+func runAST(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the tree as JSON that ast.DecodeProgram can read back")
+	resolve := flags.Bool("resolve", false, "include the resolver's depth and slot annotations")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monkey ast [-json] [-resolve] [file.mk]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := "<standard input>"
+	var src []byte
+	var err error
+	if flags.NArg() == 0 {
+		src, err = io.ReadAll(stdin)
+	} else {
+		path = flags.Arg(0)
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey ast: %s\n", err)
+		return 1
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		fmt.Fprintf(stderr, "%s: %s\n", path, strings.Join(p.Errors(), "\n\t"))
+		return 1
+	}
+	if *resolve {
+		resolver.Resolve(program, nil)
+	}
+
+	data, err := ast.EncodeJSON(program)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey ast: %s\n", err)
+		return 1
+	}
+	if *asJSON {
+		var out bytes.Buffer
+		json.Indent(&out, data, "", "  ")
+		out.WriteByte('\n')
+		stdout.Write(out.Bytes())
+		return 0
+	}
+	var tree map[string]interface{}
+	json.Unmarshal(data, &tree)
+	writeOutline(stdout, tree, "", 0)
+	return 0
+}
+
+// 子节点的字段，按 JSON 中的顺序
+var outlineFields = []string{
+	"name", "value", "expression", "left", "right", "condition", "consequence", "alternative",
+	"names", "iterable", "parameters", "function", "arguments", "index", "start", "end", "body",
+	"statements", "elements", "parts", "pairs",
+}
+
+// 每个节点一行：字段名、类型、值或运算符与位置，子节点多缩进两格
+func writeOutline(w io.Writer, node map[string]interface{}, field string, depth int) {
+	line := strings.Repeat("  ", depth)
+	if field != "" {
+		line += field + ": "
+	}
+	line += node["kind"].(string)
+	if op, ok := node["operator"].(string); ok {
+		line += " " + op
+	}
+	if v, ok := node["value"]; ok {
+		if _, isNode := v.(map[string]interface{}); !isNode {
+			data, _ := json.Marshal(v)
+			line += " " + string(data)
+		}
+	}
+	if tok, ok := node["token"].(map[string]interface{}); ok {
+		pos := tok["pos"].(map[string]interface{})
+		line += fmt.Sprintf(" @%v:%v", pos["line"], pos["column"])
+	}
+	if node["resolved"] == true {
+		line += fmt.Sprintf(" depth=%v slot=%v", orZero(node["depth"]), orZero(node["slot"]))
+	}
+	fmt.Fprintln(w, line)
+
+	for _, f := range outlineFields {
+		switch child := node[f].(type) {
+		case map[string]interface{}:
+			writeOutline(w, child, f, depth+1)
+		case []interface{}:
+			for i, c := range child {
+				if f == "pairs" {
+					pair := c.(map[string]interface{})
+					writeOutline(w, pair["key"].(map[string]interface{}), fmt.Sprintf("key[%d]", i), depth+1)
+					writeOutline(w, pair["value"].(map[string]interface{}), fmt.Sprintf("value[%d]", i), depth+1)
+					continue
+				}
+				writeOutline(w, c.(map[string]interface{}), fmt.Sprintf("%s[%d]", f, i), depth+1)
+			}
+		}
+	}
+}
+
+// JSON 中省略的零值
+func orZero(v interface{}) interface{} {
+	if v == nil {
+		return 0
+	}
+	return v
+}
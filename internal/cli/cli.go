@@ -0,0 +1,53 @@
+// 命令行子命令，不带参数运行 monkey 时进入 REPL
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// 子命令，返回进程退出码
+type command struct {
+	usage string
+	run   func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = map[string]command{
+	"ast":     {"print the syntax tree of a Monkey program, optionally as JSON", runAST},
+	"dap":     {"run the debug adapter over stdio", runDAP},
+	"fmt":     {"format Monkey source files", runFmt},
+	"lint":    {"report suspicious constructs in Monkey source files", runLint},
+	"lsp":     {"run the language server over stdio", runLSP},
+	"profile": {"run a program and report time and allocations per function", runProfile},
+	"serve":   {"host the web playground on localhost", runServe},
+	"test":    {"run test_* functions in *_test.mk files", runTest},
+}
+
+// 执行子命令并返回退出码
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdout)
+		return 0
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "monkey: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	return cmd.run(args[1:], stdin, stdout, stderr)
+}
+
+func usage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: monkey [command] [arguments]")
+	fmt.Fprintln(out, "\nWithout a command, monkey starts the REPL.\n\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-8s %s\n", name, commands[name].usage)
+	}
+}
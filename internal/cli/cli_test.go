@@ -0,0 +1,289 @@
+package cli
+
+import (
+	"bytes"
+	"monkey/internal/ast"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestUnknownCommand(t *testing.T) {
+	code, _, stderr := run(t, "", "nope")
+	if code != 2 || !strings.Contains(stderr, `unknown command "nope"`) {
+		t.Fatalf("wrong result. code=%d, stderr=%q", code, stderr)
+	}
+}
+
+func TestFmt(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.mk")
+	bad := filepath.Join(dir, "sub", "bad.mk")
+	os.WriteFile(good, []byte("let x=1\nx"), 0644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(bad, []byte("let = 1;"), 0644)
+
+	code, stdout, _ := run(t, "puts( 1 )", "fmt")
+	if code != 0 || stdout != "puts(1)\n" {
+		t.Fatalf("stdin: wrong result. code=%d, stdout=%q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "", "fmt", "-d", good)
+	want := "--- " + good + "\n+++ " + good + "\n@@ -1,2 +1,2 @@\n-let x=1\n-x\n\\ No newline at end of file\n+let x = 1;\n+x\n"
+	if code != 0 || stdout != want {
+		t.Fatalf("-d: wrong result. code=%d, stdout=%q", code, stdout)
+	}
+
+	code, _, stderr := run(t, "", "fmt", "-w", dir)
+	if code != 1 || !strings.Contains(stderr, bad+": expected next token to be IDENT, got = instead") {
+		t.Fatalf("-w: wrong result. code=%d, stderr=%q", code, stderr)
+	}
+	if src, _ := os.ReadFile(good); string(src) != "let x = 1;\nx\n" {
+		t.Fatalf("-w: file not rewritten. got=%q", src)
+	}
+	if src, _ := os.ReadFile(bad); string(src) != "let = 1;" {
+		t.Fatalf("-w: file with errors was modified. got=%q", src)
+	}
+
+	code, stdout, _ = run(t, "", "fmt", "-d", good)
+	if code != 0 || stdout != "" {
+		t.Fatalf("-d after -w: expected no diff. code=%d, stdout=%q", code, stdout)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	want := `--- f
++++ f
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+	if got := unifiedDiff("f", a, b); got != want {
+		t.Errorf("wrong diff.\nwant=%q\ngot= %q", want, got)
+	}
+	if got := unifiedDiff("f", a, a); got != "" {
+		t.Errorf("expected empty diff, got=%q", got)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		lcs  int
+	}{
+		{"a b c d e f", "a x c y e f", 4},
+		{"a b c", "c b a", 1},
+		{"x y", "", 0},
+		{"", "x y", 0},
+		{"a b a b a b", "b a b a", 4},
+		{"1 2 3 4 5 6 7 8", "8 1 2 9 4 5 3 7", 5},
+	}
+
+	for _, tt := range tests {
+		a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+		var gotA, gotB []string
+		common := 0
+		for _, l := range diffLines(a, b) {
+			if l.kind != '+' {
+				gotA = append(gotA, l.text)
+			}
+			if l.kind != '-' {
+				gotB = append(gotB, l.text)
+			}
+			if l.kind == ' ' {
+				common++
+			}
+		}
+		if strings.Join(gotA, " ") != tt.a || strings.Join(gotB, " ") != tt.b {
+			t.Errorf("diff of %q and %q does not reproduce both sides. got a=%q, b=%q", tt.a, tt.b, gotA, gotB)
+		}
+		if common != tt.lcs {
+			t.Errorf("diff of %q and %q keeps %d lines, want %d", tt.a, tt.b, common, tt.lcs)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	src := "let f = fn(a, b) { return 1; a };\nlen(1, 2)"
+
+	code, stdout, _ := run(t, src, "lint")
+	want := "<standard input>:1:15: unused parameter b (unused)\n" +
+		"<standard input>:1:30: unreachable code (unreachable)\n" +
+		"<standard input>:2:1: len expects 1 argument, got 2 (builtin-arity)\n"
+	if code != 1 || stdout != want {
+		t.Fatalf("wrong result. code=%d, stdout=%q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, src, "lint", "-json", "-disable", "unused,unreachable")
+	want = `[
+  {
+    "file": "<standard input>",
+    "rule": "builtin-arity",
+    "pos": {
+      "offset": 34,
+      "line": 2,
+      "column": 1
+    },
+    "end": {
+      "offset": 37,
+      "line": 2,
+      "column": 4
+    },
+    "message": "len expects 1 argument, got 2"
+  }
+]
+`
+	if code != 1 || stdout != want {
+		t.Fatalf("-json: wrong result. code=%d, stdout=%s", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "let x = 1;", "lint", "-json")
+	if code != 0 || stdout != "[]\n" {
+		t.Fatalf("clean input: wrong result. code=%d, stdout=%q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "let = 1;", "lint")
+	if code != 1 || !strings.Contains(stdout, "<standard input>:1:5: expected next token to be IDENT, got = instead (syntax)") {
+		t.Fatalf("syntax error: wrong result. code=%d, stdout=%q", code, stdout)
+	}
+
+	code, _, stderr := run(t, "", "lint", "-enable", "nope")
+	if code != 2 || !strings.Contains(stderr, `unknown rule "nope"`) {
+		t.Fatalf("unknown rule: wrong result. code=%d, stderr=%q", code, stderr)
+	}
+}
+
+func TestTest(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "math_test.mk")
+	os.WriteFile(file, []byte("let test_ok = fn() { assert_eq(1 + 1, 2) };\nlet test_bad = fn() { assert_true(false) };"), 0644)
+	os.WriteFile(filepath.Join(dir, "math.mk"), []byte("let = 1;"), 0644)
+
+	code, stdout, _ := run(t, "", "test", dir)
+	want := "--- FAIL: test_bad"
+	if code != 1 || !strings.Contains(stdout, want) || !strings.Contains(stdout, file+":2: assert_true failed") {
+		t.Fatalf("wrong result. code=%d, stdout=%q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "", "test", "-run", "ok", "-format", "tap", dir)
+	if code != 0 || stdout != "TAP version 13\n1..1\nok 1 - "+file+" test_ok\n" {
+		t.Fatalf("-format tap: wrong result. code=%d, stdout=%q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "", "test", "-format", "junit", file)
+	if code != 1 || !strings.Contains(stdout, `<testsuites tests="2" failures="1" errors="0"`) {
+		t.Fatalf("-format junit: wrong result. code=%d, stdout=%q", code, stdout)
+	}
+
+	lcov := filepath.Join(t.TempDir(), "cover.lcov")
+	code, stdout, _ = run(t, "", "test", "-run", "ok", "-cover", "-lcov", lcov, file)
+	if code != 0 || !strings.Contains(stdout, "coverage: "+file+": 75.0% of statements, 100.0% of branches\n") {
+		t.Fatalf("-cover: wrong result. code=%d, stdout=%q", code, stdout)
+	}
+	if data, _ := os.ReadFile(lcov); !strings.Contains(string(data), "SF:"+file+"\n") || !strings.Contains(string(data), "FNDA:0,test_bad\n") {
+		t.Fatalf("-lcov: wrong profile:\n%s", data)
+	}
+
+	code, _, stderr := run(t, "", "test", "-format", "xml", dir)
+	if code != 2 || !strings.Contains(stderr, `unknown format "xml"`) {
+		t.Fatalf("unknown format: wrong result. code=%d, stderr=%q", code, stderr)
+	}
+	code, _, stderr = run(t, "", "test", t.TempDir())
+	if code != 1 || !strings.Contains(stderr, "no *_test.mk files found") {
+		t.Fatalf("empty directory: wrong result. code=%d, stderr=%q", code, stderr)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "fib.mk")
+	out := filepath.Join(dir, "fib.pb.gz")
+	os.WriteFile(file, []byte("let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };\nfib(5)"), 0644)
+
+	code, stdout, _ := run(t, "", "profile", "-o", out, file)
+	if code != 0 || !strings.Contains(stdout, "fib "+file+":1:11") {
+		t.Fatalf("wrong result. code=%d, stdout=%q", code, stdout)
+	}
+	if data, err := os.ReadFile(out); err != nil || len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		t.Fatalf("-o: expected a gzipped profile, err=%v", err)
+	}
+
+	code, _, stderr := run(t, "", "profile")
+	if code != 2 || !strings.Contains(stderr, "Usage: monkey profile") {
+		t.Fatalf("no file: wrong result. code=%d, stderr=%q", code, stderr)
+	}
+}
+
+func TestAST(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.mk")
+	os.WriteFile(file, []byte("let x = -1;\nx"), 0644)
+
+	code, stdout, _ := run(t, "", "ast", file)
+	want := `Program
+  statements[0]: LetStatement @1:1
+    name: Identifier "x" @1:5
+    value: PrefixExpression - @1:9
+      right: IntegerLiteral 1 @1:10
+  statements[1]: ExpressionStatement @2:1
+    expression: Identifier "x" @2:1
+`
+	if code != 0 || stdout != want {
+		t.Fatalf("wrong result. code=%d, stdout=%q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "", "ast", "--json", file)
+	if code != 0 || !strings.HasPrefix(stdout, "{\n  \"kind\": \"Program\"") {
+		t.Fatalf("-json: wrong result. code=%d, stdout=%q", code, stdout)
+	}
+	program, err := ast.DecodeProgram([]byte(stdout))
+	if err != nil || program.String() != "let x = (-1);x" {
+		t.Fatalf("-json: cannot decode output. err=%v", err)
+	}
+
+	code, stdout, _ = run(t, "x", "ast", "-resolve")
+	if code != 0 || stdout != "Program\n  statements[0]: ExpressionStatement @1:1\n    expression: Identifier \"x\" @1:1\n" {
+		t.Fatalf("stdin: wrong result. code=%d, stdout=%q", code, stdout)
+	}
+
+	code, _, stderr := run(t, "let", "ast")
+	if code != 1 || !strings.Contains(stderr, "<standard input>: ") {
+		t.Fatalf("syntax error: wrong result. code=%d, stderr=%q", code, stderr)
+	}
+}
+
+func TestServe(t *testing.T) {
+	code, _, stderr := run(t, "", "serve", "extra")
+	if code != 2 || !strings.Contains(stderr, "Usage: monkey serve") {
+		t.Fatalf("extra argument: wrong result. code=%d, stderr=%q", code, stderr)
+	}
+	code, _, stderr = run(t, "", "serve", "-timeout", "0s")
+	if code != 2 || !strings.Contains(stderr, "Usage: monkey serve") {
+		t.Fatalf("zero timeout: wrong result. code=%d, stderr=%q", code, stderr)
+	}
+
+	t.Setenv("MONKEY_SANDBOX", "lots")
+	code, _, stderr = run(t, "", "serve")
+	if code != 2 || stderr != "monkey serve: bad MONKEY_SANDBOX: lots\n" {
+		t.Fatalf("sandbox: wrong result. code=%d, stderr=%q", code, stderr)
+	}
+}
@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"monkey/internal/dap"
+)
+
+// monkey dap，通过标准输入输出与调试器前端通信
+func runDAP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) != 0 {
+		fmt.Fprintln(stderr, "Usage: monkey dap")
+		return 2
+	}
+	if err := dap.NewServer(stdin, stdout).Run(); err != nil {
+		fmt.Fprintf(stderr, "monkey dap: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3 // 差异前后保留的上下文行数
+
+type diffLine struct {
+	kind byte // ' '、'-' 或 '+'
+	text string
+}
+
+// 生成统一格式（unified）的逐行差异，内容相同时返回空字符串
+func unifiedDiff(name, a, b string) string {
+	if a == b {
+		return ""
+	}
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", name, name)
+	for start := 0; start < len(lines); {
+		// 找到下一处改动
+		for start < len(lines) && lines[start].kind == ' ' {
+			start++
+		}
+		if start == len(lines) {
+			break
+		}
+		from := start - diffContext
+		if from < 0 {
+			from = 0
+		}
+		// 相邻改动之间的相同行不超过两倍上下文时合并为一个片段
+		end := start
+		for i := start; i < len(lines); i++ {
+			if lines[i].kind != ' ' {
+				end = i + 1
+			} else if i-end >= 2*diffContext {
+				break
+			}
+		}
+		to := end + diffContext
+		if to > len(lines) {
+			to = len(lines)
+		}
+		writeHunk(&out, lines, from, to)
+		start = to
+	}
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, lines []diffLine, from, to int) {
+	// 计算片段在两个文件中的起始行号与行数
+	aStart, bStart := 1, 1
+	for _, l := range lines[:from] {
+		if l.kind != '+' {
+			aStart++
+		}
+		if l.kind != '-' {
+			bStart++
+		}
+	}
+	aCount, bCount := 0, 0
+	for _, l := range lines[from:to] {
+		if l.kind != '+' {
+			aCount++
+		}
+		if l.kind != '-' {
+			bCount++
+		}
+	}
+	if aCount == 0 {
+		aStart--
+	}
+	if bCount == 0 {
+		bStart--
+	}
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+	for _, l := range lines[from:to] {
+		out.WriteByte(l.kind)
+		out.WriteString(l.text)
+		if !strings.HasSuffix(l.text, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// 按行拆分，每行保留结尾的换行符
+func splitLines(s string) []string {
+	var lines []string
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+	return lines
+}
+
+// 基于最长公共子序列计算逐行差异，去掉相同的首尾后用 Hirschberg 算法求解，只占用线性空间
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var lines []diffLine
+	lines = appendLines(lines, ' ', a[:prefix])
+	lines = diffRange(lines, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	return appendLines(lines, ' ', a[len(a)-suffix:])
+}
+
+// 在 a 的中点处找到最长公共子序列经过 b 的位置，拆成两半分别求差异
+func diffRange(lines []diffLine, a, b []string) []diffLine {
+	switch {
+	case len(a) == 0:
+		return appendLines(lines, '+', b)
+	case len(b) == 0:
+		return appendLines(lines, '-', a)
+	case len(a) == 1:
+		for j, line := range b {
+			if line == a[0] {
+				lines = appendLines(lines, '+', b[:j])
+				lines = append(lines, diffLine{' ', line})
+				return appendLines(lines, '+', b[j+1:])
+			}
+		}
+		lines = append(lines, diffLine{'-', a[0]})
+		return appendLines(lines, '+', b)
+	}
+
+	mid := len(a) / 2
+	forward := lcsLengths(a[:mid], b)
+	backward := lcsLengths(reversed(a[mid:]), reversed(b))
+	// 取最靠前的拆分点，使删除的行排在插入的行之前
+	split, best := 0, -1
+	for j := 0; j <= len(b); j++ {
+		if n := forward[j] + backward[len(b)-j]; n > best {
+			split, best = j, n
+		}
+	}
+	lines = diffRange(lines, a[:mid], b[:split])
+	return diffRange(lines, a[mid:], b[split:])
+}
+
+// 返回 a 与 b 的每个前缀 b[:j] 的最长公共子序列长度
+func lcsLengths(a, b []string) []int {
+	row := make([]int, len(b)+1)
+	for _, x := range a {
+		diag := 0 // 上一行的 row[j]
+		for j, y := range b {
+			up := row[j+1]
+			if x == y {
+				row[j+1] = diag + 1
+			} else if row[j] > up {
+				row[j+1] = row[j]
+			}
+			diag = up
+		}
+	}
+	return row
+}
+
+func reversed(lines []string) []string {
+	r := make([]string, len(lines))
+	for i, line := range lines {
+		r[len(lines)-1-i] = line
+	}
+	return r
+}
+
+func appendLines(lines []diffLine, kind byte, text []string) []diffLine {
+	for _, t := range text {
+		lines = append(lines, diffLine{kind, t})
+	}
+	return lines
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"monkey/internal/format"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// monkey fmt [-w] [-d] [path ...]，没有路径时格式化标准输入
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write result to the source file instead of stdout")
+	diff := flags.Bool("d", false, "display diffs instead of rewriting files")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monkey fmt [-w] [-d] [path ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey fmt: %s\n", err)
+			return 1
+		}
+		formatted, err := format.Source(string(src))
+		if err != nil {
+			fmt.Fprintf(stderr, "<standard input>: %s\n", indentErrors(err))
+			return 1
+		}
+		if *diff {
+			io.WriteString(stdout, unifiedDiff("<standard input>", string(src), formatted))
+		} else {
+			io.WriteString(stdout, formatted)
+		}
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		files, err := sourceFiles(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey fmt: %s\n", err)
+			status = 1
+			continue
+		}
+		for _, file := range files {
+			if err := formatFile(file, *write, *diff, stdout); err != nil {
+				fmt.Fprintf(stderr, "%s: %s\n", file, indentErrors(err))
+				status = 1
+			}
+		}
+	}
+	return status
+}
+
+func formatFile(file string, write, diff bool, stdout io.Writer) error {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	formatted, err := format.Source(string(src))
+	if err != nil {
+		return err
+	}
+	if diff {
+		io.WriteString(stdout, unifiedDiff(file, string(src), formatted))
+	}
+	if write {
+		if formatted == string(src) {
+			return nil
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(file, []byte(formatted), info.Mode().Perm())
+	}
+	if !diff {
+		io.WriteString(stdout, formatted)
+	}
+	return nil
+}
+
+// 展开路径，目录中递归查找 .mk 文件
+func sourceFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(file, ".mk") {
+			files = append(files, file)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// 多条错误时每条单独一行
+func indentErrors(err error) string {
+	return strings.ReplaceAll(err.Error(), "\n", "\n\t")
+}
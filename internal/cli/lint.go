@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/internal/lexer"
+	"monkey/internal/lint"
+	"monkey/internal/parser"
+	"os"
+	"strings"
+)
+
+// JSON 输出中的一条结果
+type lintResult struct {
+	File string `json:"file"`
+	lint.Diagnostic
+}
+
+// monkey lint [-json] [-enable rules] [-disable rules] [path ...]，没有路径时检查标准输入
+func runLint(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print results as a JSON array")
+	enable := flags.String("enable", "", "comma-separated rules to enable in addition to the defaults")
+	disable := flags.String("disable", "", "comma-separated rules to disable")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monkey lint [-json] [-enable rules] [-disable rules] [path ...]")
+		flags.PrintDefaults()
+		fmt.Fprintf(stderr, "\nRules:\n%s", lint.Describe())
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	rules, err := lint.Select(splitList(*enable), splitList(*disable))
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey lint: %s\n", err)
+		return 2
+	}
+
+	status := 0
+	results := []lintResult{}
+	check := func(file string, src []byte) {
+		for _, d := range lintSource(string(src), rules) {
+			results = append(results, lintResult{file, d})
+		}
+	}
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey lint: %s\n", err)
+			return 1
+		}
+		check("<standard input>", src)
+	}
+	for _, path := range flags.Args() {
+		files, err := sourceFiles(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey lint: %s\n", err)
+			status = 1
+			continue
+		}
+		for _, file := range files {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				fmt.Fprintf(stderr, "monkey lint: %s\n", err)
+				status = 1
+				continue
+			}
+			check(file, src)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	} else {
+		for _, r := range results {
+			fmt.Fprintf(stdout, "%s:%s\n", r.File, r.Diagnostic)
+		}
+	}
+	if len(results) > 0 {
+		status = 1
+	}
+	return status
+}
+
+// 语法错误作为 syntax 规则的结果报告，此时不再运行其他规则
+func lintSource(src string, rules []*lint.Rule) []lint.Diagnostic {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errors := p.DetailedErrors(); len(errors) > 0 {
+		var diags []lint.Diagnostic
+		for _, err := range errors {
+			diags = append(diags, lint.Diagnostic{Rule: "syntax", Pos: err.Pos, End: err.End, Message: err.Msg})
+		}
+		return diags
+	}
+	return lint.Program(program, rules)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"monkey/internal/lsp"
+)
+
+// monkey lsp，通过标准输入输出与编辑器通信
+func runLSP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) != 0 {
+		fmt.Fprintln(stderr, "Usage: monkey lsp")
+		return 2
+	}
+	if err := lsp.NewServer(stdin, stdout).Run(); err != nil {
+		fmt.Fprintf(stderr, "monkey lsp: %s\n", err)
+		return 1
+	}
+	return 0
+}
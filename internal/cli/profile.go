@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/internal/evaluator"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/profiler"
+	"monkey/internal/resolver"
+	"os"
+	"strings"
+)
+
+// monkey profile [-o file] [-n count] file.mk，运行程序并输出各函数的耗时与分配
+func runProfile(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("profile", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	out := flags.String("o", "", "write a gzipped pprof profile to `file` for go tool pprof")
+	n := flags.Int("n", 20, "list at most `count` functions, 0 for all")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monkey profile [-o file] [-n count] file.mk")
+		fmt.Fprintln(stderr, "Every function call is instrumented rather than sampled: call counts are exact, but programs making many small calls run noticeably slower while profiled.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey profile: %s\n", err)
+		return 1
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		fmt.Fprintf(stderr, "%s: %s\n", path, strings.Join(p.Errors(), "\n\t"))
+		return 1
+	}
+	resolver.Resolve(program, nil)
+
+	prof := profiler.New()
+	prof.Add(path, p)
+	ctx := &evaluator.Context{Tracer: prof}
+	prof.Start()
+	result := ctx.Eval(program, object.NewEnvironment())
+	prof.Stop()
+
+	status := 0
+	if err, ok := result.(*object.Error); ok {
+		fmt.Fprintf(stderr, "%s: %s\n", path, err.Message)
+		status = 1
+	}
+	prof.WriteText(stdout, *n)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err == nil {
+			err = prof.WritePprof(f)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey profile: %s\n", err)
+			return 1
+		}
+	}
+	return status
+}
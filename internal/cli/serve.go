@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/internal/playground"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// monkey serve [-addr host:port] [-dir path] [-timeout d] [-memory MB]，在本机运行网页版练习场
+func runServe(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	// 练习场用同一个可执行文件启动沙箱进程
+	if limits := os.Getenv(playground.SandboxEnv); limits != "" {
+		memory, data, err := playground.ParseLimits(limits)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey serve: bad %s: %s\n", playground.SandboxEnv, limits)
+			return 2
+		}
+		return playground.Sandbox(stdin, stdout, stderr, memory, data)
+	}
+
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	addr := flags.String("addr", "localhost:8080", "listen on `address`")
+	dir := flags.String("dir", "", "store shared snippets in `path` (default: monkey/snippets in the user config directory)")
+	timeout := flags.Duration("timeout", 5*time.Second, "stop a program after `duration`")
+	memory := flags.Int64("memory", 64, "stop a program whose heap exceeds `MB` megabytes")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monkey serve [-addr host:port] [-dir path] [-timeout duration] [-memory MB]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 || *timeout <= 0 || *memory <= 0 {
+		flags.Usage()
+		return 2
+	}
+
+	if *dir == "" {
+		config, err := os.UserConfigDir()
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey serve: %s\n", err)
+			return 1
+		}
+		*dir = filepath.Join(config, "monkey", "snippets")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey serve: %s\n", err)
+		return 1
+	}
+	s := playground.NewServer(*dir, []string{exe, "serve"})
+	s.Timeout = *timeout
+	s.MemoryLimit = *memory << 20
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey serve: %s\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Monkey playground on http://%s (snippets in %s)\n", l.Addr(), *dir)
+	server := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	if err := server.Serve(l); err != nil {
+		fmt.Fprintf(stderr, "monkey serve: %s\n", err)
+		return 1
+	}
+	return 0
+}
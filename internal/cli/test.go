@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/internal/coverage"
+	"monkey/internal/mktest"
+	"os"
+	"regexp"
+)
+
+// monkey test [-v] [-run regexp] [-format text|tap|junit] [-cover] [-lcov file] [-html file] [path ...]，没有路径时测试当前目录
+func runTest(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	verbose := flags.Bool("v", false, "list passing tests and their output")
+	run := flags.String("run", "", "run only tests whose names match the regular expression")
+	format := flags.String("format", "text", "report format: text, tap or junit")
+	cover := flags.Bool("cover", false, "print statement and branch coverage of each file")
+	lcovFile := flags.String("lcov", "", "write coverage to `file` in lcov format")
+	htmlFile := flags.String("html", "", "write an HTML coverage report to `file`")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monkey test [-v] [-run regexp] [-format text|tap|junit] [-cover] [-lcov file] [-html file] [path ...]")
+		fmt.Fprintln(stderr, "Each test_* function runs in a fresh environment: the file's top-level code is evaluated again before every test, so keep it cheap.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "tap" && *format != "junit" {
+		fmt.Fprintf(stderr, "monkey test: unknown format %q\n", *format)
+		return 2
+	}
+	var opts mktest.Options
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey test: -run: %s\n", err)
+			return 2
+		}
+		opts.Filter = re
+	}
+	if *cover || *lcovFile != "" || *htmlFile != "" {
+		opts.Coverage = coverage.New()
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	var results []*mktest.FileResult
+	for _, path := range paths {
+		files, err := mktest.Discover(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey test: %s\n", err)
+			return 1
+		}
+		for _, file := range files {
+			results = append(results, mktest.RunFile(file, opts))
+		}
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(stderr, "monkey test: no *_test.mk files found")
+		return 1
+	}
+
+	switch *format {
+	case "tap":
+		mktest.WriteTAP(stdout, results)
+	case "junit":
+		if err := mktest.WriteJUnit(stdout, results); err != nil {
+			fmt.Fprintf(stderr, "monkey test: %s\n", err)
+			return 1
+		}
+	default:
+		mktest.WriteText(stdout, results, *verbose)
+	}
+	if opts.Coverage != nil {
+		if err := writeCoverage(opts.Coverage, *cover, *lcovFile, *htmlFile, *format, stdout, stderr); err != nil {
+			fmt.Fprintf(stderr, "monkey test: %s\n", err)
+			return 1
+		}
+	}
+	for _, r := range results {
+		if r.Failed() {
+			return 1
+		}
+	}
+	return 0
+}
+
+// 输出覆盖率摘要与报告文件。摘要在 TAP 与 JUnit 格式下写到标准错误，以免破坏报告
+func writeCoverage(profile *coverage.Profile, summary bool, lcovFile, htmlFile, format string, stdout, stderr io.Writer) error {
+	if summary {
+		out := stdout
+		if format != "text" {
+			out = stderr
+		}
+		for _, f := range profile.Files {
+			fmt.Fprintf(out, "coverage: %s: %.1f%% of statements, %.1f%% of branches\n", f.Path, f.Percent(), f.BranchPercent())
+		}
+	}
+	write := func(path string, report func(io.Writer) error) error {
+		if path == "" {
+			return nil
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := report(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	if err := write(lcovFile, profile.WriteLCOV); err != nil {
+		return err
+	}
+	return write(htmlFile, profile.WriteHTML)
+}
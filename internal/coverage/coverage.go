@@ -0,0 +1,218 @@
+// 记录 Monkey 程序执行了哪些语句、if 分支与函数，输出 lcov 与 HTML 覆盖率报告
+package coverage
+
+import (
+	"fmt"
+	"io"
+	"monkey/internal/ast"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/token"
+	"sort"
+)
+
+// 实现 evaluator.Tracer 与 evaluator.BranchTracer，只统计用 Add 登记过的程序
+type Profile struct {
+	Files []*File
+
+	statements map[ast.Statement]*Statement
+	branches   map[*ast.IfExpression]*Branch
+	functions  map[*ast.BlockStatement]*Function // 以函数体区分函数
+}
+
+type File struct {
+	Path       string
+	Source     string
+	Statements []*Statement // 按位置排序，下同
+	Branches   []*Branch
+	Functions  []*Function
+}
+
+type Statement struct {
+	Pos, End token.Position
+	Count    int
+}
+
+// if 表达式的两个分支，没有 else 时 Alternative 统计条件为假的次数
+type Branch struct {
+	Pos         token.Position // if 关键字的位置
+	Consequence int
+	Alternative int
+}
+
+type Function struct {
+	Name  string // let 绑定的名字，匿名函数为 fn@行:列
+	Pos   token.Position
+	Count int
+}
+
+func New() *Profile {
+	return &Profile{
+		statements: map[ast.Statement]*Statement{},
+		branches:   map[*ast.IfExpression]*Branch{},
+		functions:  map[*ast.BlockStatement]*Function{},
+	}
+}
+
+// 登记一个源文件，p 为解析 program 的解析器，用于取得节点范围
+func (prof *Profile) Add(path, src string, program *ast.Program, p *parser.Parser) *File {
+	f := &File{Path: path, Source: src}
+	names := map[*ast.FunctionLiteral]string{}
+	for _, node := range p.Nodes() {
+		if let, ok := node.(*ast.LetStatement); ok {
+			if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+				names[fn] = let.Name.Value
+			}
+		}
+	}
+
+	for _, node := range p.Nodes() {
+		span, ok := p.Span(node)
+		if !ok {
+			continue
+		}
+		switch node := node.(type) {
+		case *ast.LetStatement, *ast.ReturnStatement, *ast.ExpressionStatement:
+			s := &Statement{Pos: span.Start, End: span.End}
+			prof.statements[node.(ast.Statement)] = s
+			f.Statements = append(f.Statements, s)
+		case *ast.IfExpression:
+			b := &Branch{Pos: node.Token.Pos}
+			prof.branches[node] = b
+			f.Branches = append(f.Branches, b)
+		case *ast.FunctionLiteral:
+			name, ok := names[node]
+			if !ok {
+				name = fmt.Sprintf("fn@%d:%d", node.Token.Pos.Line, node.Token.Pos.Column)
+			}
+			fn := &Function{Name: name, Pos: node.Token.Pos}
+			prof.functions[node.Body] = fn
+			f.Functions = append(f.Functions, fn)
+		}
+	}
+	sort.Slice(f.Statements, func(i, j int) bool { return f.Statements[i].Pos.Offset < f.Statements[j].Pos.Offset })
+	sort.Slice(f.Branches, func(i, j int) bool { return f.Branches[i].Pos.Offset < f.Branches[j].Pos.Offset })
+	sort.Slice(f.Functions, func(i, j int) bool { return f.Functions[i].Pos.Offset < f.Functions[j].Pos.Offset })
+	prof.Files = append(prof.Files, f)
+	return f
+}
+
+func (prof *Profile) Statement(stmt ast.Statement, env *object.Environment) {
+	if s, ok := prof.statements[stmt]; ok {
+		s.Count++
+	}
+}
+
+func (prof *Profile) Call(fn *object.Function, args []object.Object, env *object.Environment) {
+	if f, ok := prof.functions[fn.Body]; ok {
+		f.Count++
+	}
+}
+
+func (prof *Profile) Return(fn *object.Function, result object.Object) {}
+
+func (prof *Profile) Branch(ie *ast.IfExpression, taken bool) {
+	b, ok := prof.branches[ie]
+	switch {
+	case !ok:
+	case taken:
+		b.Consequence++
+	default:
+		b.Alternative++
+	}
+}
+
+// 每个有语句开始的行的执行次数，取该行开始的语句中最大的次数
+func (f *File) Lines() map[int]int {
+	lines := map[int]int{}
+	for _, s := range f.Statements {
+		if count, ok := lines[s.Pos.Line]; !ok || s.Count > count {
+			lines[s.Pos.Line] = s.Count
+		}
+	}
+	return lines
+}
+
+// 执行过的语句占比，没有语句时为 100
+func (f *File) Percent() float64 {
+	covered := 0
+	for _, s := range f.Statements {
+		if s.Count > 0 {
+			covered++
+		}
+	}
+	return percent(covered, len(f.Statements))
+}
+
+// 执行过的分支占比，每个 if 有两个分支
+func (f *File) BranchPercent() float64 {
+	covered := 0
+	for _, b := range f.Branches {
+		if b.Consequence > 0 {
+			covered++
+		}
+		if b.Alternative > 0 {
+			covered++
+		}
+	}
+	return percent(covered, 2*len(f.Branches))
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// 以 lcov 跟踪文件格式输出，可交给 genhtml 或编辑器插件
+func (prof *Profile) WriteLCOV(w io.Writer) error {
+	for _, f := range prof.Files {
+		fmt.Fprintf(w, "TN:\nSF:%s\n", f.Path)
+
+		hit := 0
+		for _, fn := range f.Functions {
+			fmt.Fprintf(w, "FN:%d,%s\n", fn.Pos.Line, fn.Name)
+		}
+		for _, fn := range f.Functions {
+			fmt.Fprintf(w, "FNDA:%d,%s\n", fn.Count, fn.Name)
+			if fn.Count > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(w, "FNF:%d\nFNH:%d\n", len(f.Functions), hit)
+
+		hit = 0
+		for i, b := range f.Branches {
+			for j, count := range []int{b.Consequence, b.Alternative} {
+				taken := "-" // 条件从未求值
+				if b.Consequence+b.Alternative > 0 {
+					taken = fmt.Sprint(count)
+				}
+				if count > 0 {
+					hit++
+				}
+				fmt.Fprintf(w, "BRDA:%d,%d,%d,%s\n", b.Pos.Line, i, j, taken)
+			}
+		}
+		fmt.Fprintf(w, "BRF:%d\nBRH:%d\n", 2*len(f.Branches), hit)
+
+		lines := f.Lines()
+		numbers := make([]int, 0, len(lines))
+		for line := range lines {
+			numbers = append(numbers, line)
+		}
+		sort.Ints(numbers)
+		hit = 0
+		for _, line := range numbers {
+			fmt.Fprintf(w, "DA:%d,%d\n", line, lines[line])
+			if lines[line] > 0 {
+				hit++
+			}
+		}
+		if _, err := fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", len(lines), hit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package coverage
+
+import (
+	"bytes"
+	"monkey/internal/evaluator"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"strings"
+	"testing"
+)
+
+const source = `let sign = fn(x) {
+	if (x < 0) { return -1; }
+	if (x == 0) { 0 } else { 1 }
+};
+let unused = fn() { 1 };
+[sign(3), sign(0), map([2], fn(x) { sign(x) })];
+`
+
+func run(t *testing.T) *Profile {
+	t.Helper()
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	resolver.Resolve(program, nil)
+
+	profile := New()
+	profile.Add("sign.mk", source, program, p)
+	ctx := &evaluator.Context{Tracer: profile}
+	if result := ctx.Eval(program, object.NewEnvironment()); result.Inspect() != "[1, 0, [1]]" {
+		t.Fatalf("wrong result. got=%s", result.Inspect())
+	}
+	return profile
+}
+
+func TestProfile(t *testing.T) {
+	f := run(t).Files[0]
+
+	var counts []int
+	for _, s := range f.Statements {
+		counts = append(counts, s.Count)
+	}
+	// let sign、两个 if 与其中的语句、let unused、unused 的函数体、最后一行、sign(x)
+	want := []int{1, 3, 0, 3, 1, 2, 1, 0, 1, 1}
+	if len(counts) != len(want) {
+		t.Fatalf("wrong statements. want=%v, got=%v", want, counts)
+	}
+	for i := range want {
+		if counts[i] != want[i] {
+			t.Fatalf("wrong statement counts. want=%v, got=%v", want, counts)
+		}
+	}
+	if f.Percent() != 80 {
+		t.Errorf("wrong statement percent. got=%f", f.Percent())
+	}
+	if f.BranchPercent() != 75 {
+		t.Errorf("wrong branch percent. got=%f", f.BranchPercent())
+	}
+}
+
+func TestWriteLCOV(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(t).WriteLCOV(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `TN:
+SF:sign.mk
+FN:1,sign
+FN:5,unused
+FN:6,fn@6:29
+FNDA:3,sign
+FNDA:0,unused
+FNDA:1,fn@6:29
+FNF:3
+FNH:2
+BRDA:2,0,0,0
+BRDA:2,0,1,3
+BRDA:3,1,0,1
+BRDA:3,1,1,2
+BRF:4
+BRH:3
+DA:1,1
+DA:2,3
+DA:3,3
+DA:5,1
+DA:6,1
+LF:5
+LH:5
+end_of_record
+`
+	if out.String() != want {
+		t.Errorf("wrong lcov.\nwant=%s\ngot=%s", want, out.String())
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(t).WriteHTML(&out); err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+	for _, want := range []string{
+		`<option value="file0">sign.mk (80.0% statements, 75.0% branches)</option>`,
+		`<span class="partial" title="consequence 0, alternative 3">if</span>`,
+		`<span class="cov0" title="count 0">1</span>`,
+		`<span class="cov1" title="count 1">[sign(3), sign(0), map([2], fn(x) { sign(x) })];</span>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML report does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package coverage
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+)
+
+// 源码中连续的一段，class 相同的字符合并在一起
+type segment struct {
+	Text  string
+	Class string // 空为不是语句，cov0 未执行，cov1 已执行，partial 为只走过一个分支的 if
+	Title string
+}
+
+type htmlFile struct {
+	Path     string
+	Percent  string
+	Segments []segment
+}
+
+// 生成单个 HTML 页面，按语句着色，内层语句的颜色覆盖外层
+func (prof *Profile) WriteHTML(w io.Writer) error {
+	var files []htmlFile
+	for _, f := range prof.Files {
+		files = append(files, htmlFile{
+			Path:     f.Path,
+			Percent:  fmt.Sprintf("%.1f%% statements, %.1f%% branches", f.Percent(), f.BranchPercent()),
+			Segments: f.segments(),
+		})
+	}
+	return page.Execute(w, files)
+}
+
+func (f *File) segments() []segment {
+	classes := make([]string, len(f.Source))
+	titles := make([]string, len(f.Source))
+	paint := func(from, to int, class, title string) {
+		for i := from; i < to && i < len(classes); i++ {
+			classes[i], titles[i] = class, title
+		}
+	}
+
+	// 先画范围大的外层语句
+	stmts := append([]*Statement(nil), f.Statements...)
+	sort.SliceStable(stmts, func(i, j int) bool {
+		return stmts[i].End.Offset-stmts[i].Pos.Offset > stmts[j].End.Offset-stmts[j].Pos.Offset
+	})
+	for _, s := range stmts {
+		class := "cov0"
+		if s.Count > 0 {
+			class = "cov1"
+		}
+		paint(s.Pos.Offset, s.End.Offset, class, fmt.Sprintf("count %d", s.Count))
+	}
+	for _, b := range f.Branches {
+		if (b.Consequence == 0) != (b.Alternative == 0) {
+			title := fmt.Sprintf("consequence %d, alternative %d", b.Consequence, b.Alternative)
+			paint(b.Pos.Offset, b.Pos.Offset+len("if"), "partial", title)
+		}
+	}
+
+	var segments []segment
+	start := 0
+	for i := 1; i <= len(f.Source); i++ {
+		if i == len(f.Source) || classes[i] != classes[start] || titles[i] != titles[start] {
+			segments = append(segments, segment{Text: f.Source[start:i], Class: classes[start], Title: titles[start]})
+			start = i
+		}
+	}
+	return segments
+}
+
+var page = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Monkey coverage</title>
+<style>
+body { background: #1e1e1e; color: #808080; font-family: sans-serif; margin: 0; }
+#topbar { background: #000; padding: 8px 12px; }
+#topbar select { font-size: 14px; }
+#legend { color: #808080; margin-left: 16px; }
+pre { font-family: Menlo, Consolas, monospace; font-size: 14px; margin: 12px; }
+.cov0 { color: #f44; }
+.cov1 { color: #2c2; }
+.partial { color: #ec4; text-decoration: underline; }
+</style>
+</head>
+<body>
+<div id="topbar">
+<select id="files" onchange="show(this.value)">
+{{range $i, $f := .}}<option value="file{{$i}}">{{$f.Path}} ({{$f.Percent}})</option>
+{{end}}</select>
+<span id="legend">
+<span class="cov1">executed</span>
+<span class="cov0">not executed</span>
+<span class="partial">if with one branch taken</span>
+<span>not tracked</span>
+</span>
+</div>
+{{range $i, $f := .}}<pre class="file" id="file{{$i}}" style="display: none">{{range $f.Segments}}{{if .Class}}<span class="{{.Class}}" title="{{.Title}}">{{.Text}}</span>{{else}}{{.Text}}{{end}}{{end}}</pre>
+{{end}}<script>
+function show(id) {
+	for (const pre of document.querySelectorAll("pre.file")) {
+		pre.style.display = pre.id === id ? "block" : "none";
+	}
+}
+show("file0");
+</script>
+</body>
+</html>
+`))
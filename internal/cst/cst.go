@@ -0,0 +1,324 @@
+// 无损的具体语法树，保留空白、注释与原始文本，可以逐字节还原源码，供格式化与重构工具使用
+package cst
+
+import (
+	"monkey/internal/ast"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/token"
+	"sort"
+	"strings"
+)
+
+type TriviaKind int
+
+const (
+	Whitespace   TriviaKind = iota // 空格、制表符与回车
+	Newline                        // 换行，\r\n 视为一个换行
+	LineComment                    // 行注释
+	BlockComment                   // 块注释
+)
+
+// 词法单元之间的空白与注释
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+	Pos  token.Position
+}
+
+// 带前后附属内容的词法单元
+type Token struct {
+	token.Token
+	Text     string   // 源码中的原始文本
+	Leading  []Trivia // 之前的附属内容
+	Trailing []Trivia // 之后同一行内的附属内容，不含换行
+}
+
+func (t *Token) String() string {
+	var out strings.Builder
+	t.writeTo(&out, true, true)
+	return out.String()
+}
+
+func (t *Token) writeTo(out *strings.Builder, leading, trailing bool) {
+	if leading {
+		for _, trivia := range t.Leading {
+			out.WriteString(trivia.Text)
+		}
+	}
+	out.WriteString(t.Text)
+	if trailing {
+		for _, trivia := range t.Trailing {
+			out.WriteString(trivia.Text)
+		}
+	}
+}
+
+// 语法树中的元素，*Token 或 *Node
+type Element interface {
+	String() string
+}
+
+// 语法节点，子元素按源码顺序排列
+type Node struct {
+	AST      ast.Node // 对应的语法树节点，根节点为 *ast.Program
+	Children []Element
+}
+
+// 按源码顺序返回节点包含的全部词法单元
+func (n *Node) Tokens() []*Token {
+	var tokens []*Token
+	for _, child := range n.Children {
+		switch child := child.(type) {
+		case *Token:
+			tokens = append(tokens, child)
+		case *Node:
+			tokens = append(tokens, child.Tokens()...)
+		}
+	}
+	return tokens
+}
+
+// 节点的第一个词法单元，不必展开全部词法单元；节点为空时返回 nil
+func (n *Node) First() *Token {
+	for _, child := range n.Children {
+		switch child := child.(type) {
+		case *Token:
+			return child
+		case *Node:
+			if tok := child.First(); tok != nil {
+				return tok
+			}
+		}
+	}
+	return nil
+}
+
+// 节点的最后一个词法单元，节点为空时返回 nil
+func (n *Node) Last() *Token {
+	for i := len(n.Children) - 1; i >= 0; i-- {
+		switch child := n.Children[i].(type) {
+		case *Token:
+			return child
+		case *Node:
+			if tok := child.Last(); tok != nil {
+				return tok
+			}
+		}
+	}
+	return nil
+}
+
+// 节点第一个词法单元的起点
+func (n *Node) Pos() token.Position {
+	if tok := n.First(); tok != nil {
+		return tok.Pos
+	}
+	return token.Position{}
+}
+
+// 节点最后一个词法单元的终点
+func (n *Node) End() token.Position {
+	if tok := n.Last(); tok != nil {
+		return tok.End
+	}
+	return token.Position{}
+}
+
+// 包括首尾附属内容在内的全部文本
+func (n *Node) String() string {
+	var out strings.Builder
+	for _, tok := range n.Tokens() {
+		tok.writeTo(&out, true, true)
+	}
+	return out.String()
+}
+
+// 从第一个词法单元到最后一个词法单元的源码文本，不含首尾附属内容
+func (n *Node) Text() string {
+	var out strings.Builder
+	tokens := n.Tokens()
+	for i, tok := range tokens {
+		tok.writeTo(&out, i > 0, i < len(tokens)-1)
+	}
+	return out.String()
+}
+
+type Tree struct {
+	Root   *Node
+	Tokens []*Token // 全部词法单元，最后一个为 EOF
+	Errors []string // 词法与语法错误
+
+	nodes map[ast.Node]*Node
+}
+
+// 解析源码，即使存在语法错误也能还原全部输入
+func Parse(src string) *Tree {
+	tree := &Tree{Tokens: scan(src), nodes: make(map[ast.Node]*Node)}
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	tree.Errors = p.Errors()
+	tree.Root = tree.build(program, p)
+	return tree
+}
+
+// 还原源码
+func (t *Tree) String() string {
+	return t.Root.String()
+}
+
+// 返回语法树节点对应的节点
+func (t *Tree) Node(node ast.Node) (*Node, bool) {
+	n, ok := t.nodes[node]
+	return n, ok
+}
+
+// 读取全部词法单元，并把空白与注释作为附属内容挂到相邻的词法单元上
+func scan(src string) []*Token {
+	l := lexer.New(src)
+	l.KeepComments(true)
+
+	var tokens []*Token
+	var leading []Trivia
+	var last *Token // 当前行上一个词法单元，之后的附属内容挂到它的 Trailing
+	offset := 0
+	pos := token.Position{Line: 1, Column: 1}
+
+	add := func(trivia Trivia) {
+		if trivia.Kind == Newline {
+			last = nil
+		}
+		if last != nil {
+			last.Trailing = append(last.Trailing, trivia)
+		} else {
+			leading = append(leading, trivia)
+		}
+	}
+
+	for {
+		tok := l.NextToken()
+		for _, trivia := range whitespace(src[offset:tok.Pos.Offset], pos) {
+			add(trivia)
+		}
+		offset, pos = tok.End.Offset, tok.End
+
+		if tok.Type == token.COMMENT {
+			kind := BlockComment
+			if strings.HasPrefix(tok.Literal, "//") {
+				kind = LineComment
+			}
+			add(Trivia{Kind: kind, Text: tok.Literal, Pos: tok.Pos})
+			continue
+		}
+
+		t := &Token{Token: tok, Text: src[tok.Pos.Offset:tok.End.Offset], Leading: leading}
+		tokens = append(tokens, t)
+		leading, last = nil, t
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+// 把词法单元之间的空白拆分为空白与换行
+func whitespace(text string, pos token.Position) []Trivia {
+	var result []Trivia
+	for len(text) > 0 {
+		n := strings.IndexByte(text, '\n')
+		switch {
+		case n == 0:
+			result = append(result, Trivia{Kind: Newline, Text: "\n", Pos: pos})
+			pos = token.Position{Offset: pos.Offset + 1, Line: pos.Line + 1, Column: 1}
+			text = text[1:]
+			continue
+		case n == 1 && text[0] == '\r':
+			result = append(result, Trivia{Kind: Newline, Text: "\r\n", Pos: pos})
+			pos = token.Position{Offset: pos.Offset + 2, Line: pos.Line + 1, Column: 1}
+			text = text[2:]
+			continue
+		case n < 0:
+			n = len(text)
+		case text[n-1] == '\r':
+			n--
+		}
+		result = append(result, Trivia{Kind: Whitespace, Text: text[:n], Pos: pos})
+		pos = token.Position{Offset: pos.Offset + n, Line: pos.Line, Column: pos.Column + n}
+		text = text[n:]
+	}
+	return result
+}
+
+// 按语法分析器记录的节点范围把词法单元组织成树
+func (t *Tree) build(program *ast.Program, p *parser.Parser) *Node {
+	first := make(map[int]int) // 起始偏移 -> 词法单元下标
+	last := make(map[int]int)  // 结束偏移 -> 词法单元下标
+	for i, tok := range t.Tokens[:len(t.Tokens)-1] {
+		first[tok.Pos.Offset] = i
+		last[tok.End.Offset] = i
+	}
+
+	type item struct {
+		node        *Node
+		first, last int
+		order       int
+	}
+	var items []item
+	for order, node := range p.Nodes() {
+		span, _ := p.Span(node)
+		i, ok := first[span.Start.Offset]
+		j, ok2 := last[span.End.Offset]
+		if !ok || !ok2 || i > j {
+			continue
+		}
+		items = append(items, item{node: &Node{AST: node}, first: i, last: j, order: order})
+	}
+	// 外层节点在前，范围相同时后完成解析的节点在外层
+	sort.Slice(items, func(a, b int) bool {
+		x, y := items[a], items[b]
+		if x.first != y.first {
+			return x.first < y.first
+		}
+		if x.last != y.last {
+			return x.last > y.last
+		}
+		return x.order > y.order
+	})
+
+	type frame struct {
+		node *Node
+		next int // 下一个尚未加入的词法单元
+		last int
+	}
+	root := &Node{AST: program}
+	t.nodes[program] = root
+	stack := []*frame{{node: root, last: len(t.Tokens) - 1}}
+
+	// 把 frame 中 end 之前尚未加入的词法单元加入节点
+	flush := func(f *frame, end int) {
+		for ; f.next < end; f.next++ {
+			f.node.Children = append(f.node.Children, t.Tokens[f.next])
+		}
+	}
+
+	for _, it := range items {
+		for stack[len(stack)-1].last < it.first {
+			top := stack[len(stack)-1]
+			flush(top, top.last+1)
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		if it.last > parent.last || it.first < parent.next {
+			continue
+		}
+		flush(parent, it.first)
+		parent.node.Children = append(parent.node.Children, it.node)
+		parent.next = it.last + 1
+		t.nodes[it.node.AST] = it.node
+		stack = append(stack, &frame{node: it.node, next: it.first, last: it.last})
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		flush(stack[i], stack[i].last+1)
+	}
+	return root
+}
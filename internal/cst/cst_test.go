@@ -0,0 +1,149 @@
+package cst
+
+import (
+	"monkey/internal/ast"
+	"monkey/internal/token"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []string{
+		"",
+		"   \n\n",
+		"let x=1;",
+		"let  add = fn(a,b){ a+b };   // 相加\n\n\nadd(1 ,  2)\n",
+		"/* 头部 */\r\nlet s = \"a ${ x +1 } b\" ;\r\n`raw\n  text`\t\n",
+		"if (x<y) {\n\t// 注释\n\tx\n} else { y } /* 尾部 /* 嵌套 */ */",
+		"{\"k\" : [1,2 ,3][0:2], 0x1F: 1_000}",
+		"let = ;; )(",
+		"\"unterminated",
+		"x /* never closed",
+		"变量 + \xff",
+		"a\x00b",
+		"let s = \"a\x00b\"; `c\x00` // d\x00e\n/* f\x00 */ \"\\\x00\"",
+	}
+
+	for _, input := range tests {
+		tree := Parse(input)
+		if got := tree.String(); got != input {
+			t.Errorf("round trip failed. want=%q, got=%q", input, got)
+		}
+		var text string
+		for _, tok := range tree.Tokens {
+			text += tok.String()
+		}
+		if text != input {
+			t.Errorf("tokens do not cover input. want=%q, got=%q", input, text)
+		}
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	input := "// 开头\nlet x = 1; // 结尾\n\n  x\n"
+	tree := Parse(input)
+
+	tests := []struct {
+		literal  string
+		leading  []TriviaKind
+		trailing []TriviaKind
+	}{
+		{"let", []TriviaKind{LineComment, Newline}, []TriviaKind{Whitespace}},
+		{"x", nil, []TriviaKind{Whitespace}},
+		{"=", nil, []TriviaKind{Whitespace}},
+		{"1", nil, nil},
+		{";", nil, []TriviaKind{Whitespace, LineComment}},
+		{"x", []TriviaKind{Newline, Newline, Whitespace}, nil},
+		{"", []TriviaKind{Newline}, nil},
+	}
+
+	if len(tree.Tokens) != len(tests) {
+		t.Fatalf("wrong number of tokens. want=%d, got=%d", len(tests), len(tree.Tokens))
+	}
+	for i, tt := range tests {
+		tok := tree.Tokens[i]
+		if tok.Text != tt.literal {
+			t.Errorf("tokens[%d] wrong text. want=%q, got=%q", i, tt.literal, tok.Text)
+		}
+		if !sameKinds(tok.Leading, tt.leading) {
+			t.Errorf("tokens[%d] wrong leading trivia. want=%v, got=%+v", i, tt.leading, tok.Leading)
+		}
+		if !sameKinds(tok.Trailing, tt.trailing) {
+			t.Errorf("tokens[%d] wrong trailing trivia. want=%v, got=%+v", i, tt.trailing, tok.Trailing)
+		}
+	}
+
+	comment := tree.Tokens[4].Trailing[1]
+	if comment.Text != "// 结尾" || comment.Pos.String() != "2:12" {
+		t.Errorf("wrong comment. got=%q at %s", comment.Text, comment.Pos)
+	}
+	indent := tree.Tokens[5].Leading[2]
+	if indent.Text != "  " || indent.Pos.String() != "4:1" {
+		t.Errorf("wrong indentation. got=%q at %s", indent.Text, indent.Pos)
+	}
+}
+
+func sameKinds(trivia []Trivia, kinds []TriviaKind) bool {
+	if len(trivia) != len(kinds) {
+		return false
+	}
+	for i, tr := range trivia {
+		if tr.Kind != kinds[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNodes(t *testing.T) {
+	input := "let  f = fn(a) { (a  +  1) * 2 }; // 注释\nf( 3 )"
+	tree := Parse(input)
+	if len(tree.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", tree.Errors)
+	}
+
+	program := tree.Root.AST.(*ast.Program)
+	let := program.Statements[0].(*ast.LetStatement)
+	fn := let.Value.(*ast.FunctionLiteral)
+	body := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	product := body.Expression.(*ast.InfixExpression)
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression
+
+	tests := []struct {
+		node     ast.Node
+		text     string
+		pos, end string
+	}{
+		{let, "let  f = fn(a) { (a  +  1) * 2 };", "1:1", "1:34"},
+		{let.Name, "f", "1:6", "1:7"},
+		{fn, "fn(a) { (a  +  1) * 2 }", "1:10", "1:33"},
+		{fn.Parameters[0], "a", "1:13", "1:14"},
+		{fn.Body, "{ (a  +  1) * 2 }", "1:16", "1:33"},
+		{product, "(a  +  1) * 2", "1:18", "1:31"},
+		{product.Left, "(a  +  1)", "1:18", "1:27"},
+		{call, "f( 3 )", "2:1", "2:7"},
+	}
+
+	for _, tt := range tests {
+		n, ok := tree.Node(tt.node)
+		if !ok {
+			t.Fatalf("node %q not found", tt.node.String())
+		}
+		if n.Text() != tt.text {
+			t.Errorf("wrong text. want=%q, got=%q", tt.text, n.Text())
+		}
+		if n.Pos().String() != tt.pos || n.End().String() != tt.end {
+			t.Errorf("%q: wrong span. want=%s-%s, got=%s-%s", tt.text, tt.pos, tt.end, n.Pos(), n.End())
+		}
+	}
+
+	// 子节点按源码顺序排列，语句之间的注释属于前一个语句的最后一个词法单元
+	if len(tree.Root.Children) != 3 {
+		t.Fatalf("root should have 2 statements and EOF, got=%d", len(tree.Root.Children))
+	}
+	if eof, ok := tree.Root.Children[2].(*Token); !ok || eof.Type != token.EOF {
+		t.Fatalf("last child should be EOF, got=%v", tree.Root.Children[2])
+	}
+	if got := tree.Root.Children[0].String(); got != "let  f = fn(a) { (a  +  1) * 2 }; // 注释" {
+		t.Errorf("wrong statement text. got=%q", got)
+	}
+}
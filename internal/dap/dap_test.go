@@ -0,0 +1,295 @@
+package dap
+
+import (
+	"encoding/json"
+	"io"
+	"monkey/internal/transport"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const program = `let add = fn(a, b) {
+	let sum = a + b;
+	sum
+};
+let xs = [1, 2];
+let total = add(xs[0], xs[1]);
+puts(total);
+let twice = add(total, total);
+puts(twice);
+`
+
+type message struct {
+	Type       string          `json:"type"`
+	Event      string          `json:"event"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+type client struct {
+	t        *testing.T
+	conn     *transport.Conn
+	seq      int
+	messages chan message
+	done     chan error
+}
+
+// 启动服务端，通过管道与之通信
+func start(t *testing.T) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, conn: transport.NewConn(outR, inW), messages: make(chan message, 100), done: make(chan error, 1)}
+	go func() {
+		c.done <- NewServer(inR, outW).Run()
+		outW.Close()
+	}()
+	go func() {
+		for {
+			body, err := c.conn.Read()
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var msg message
+			json.Unmarshal(body, &msg)
+			c.messages <- msg
+		}
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return c
+}
+
+// 等待下一条满足条件的消息，跳过 output 等其他消息
+func (c *client) wait(match func(message) bool) message {
+	c.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatal("connection closed")
+			}
+			if match(msg) {
+				return msg
+			}
+		case <-timeout:
+			c.t.Fatal("timed out waiting for message")
+		}
+	}
+}
+
+func (c *client) event(name string, v interface{}) {
+	c.t.Helper()
+	msg := c.wait(func(msg message) bool { return msg.Type == "event" && msg.Event == name })
+	if v != nil {
+		json.Unmarshal(msg.Body, v)
+	}
+}
+
+// 发送请求并等待响应，返回响应是否成功
+func (c *client) request(command string, args interface{}, v interface{}) message {
+	c.t.Helper()
+	c.seq++
+	seq := c.seq
+	c.conn.Write(map[string]interface{}{"seq": seq, "type": "request", "command": command, "arguments": args})
+	msg := c.wait(func(msg message) bool { return msg.Type == "response" && msg.RequestSeq == seq })
+	if v != nil {
+		json.Unmarshal(msg.Body, v)
+	}
+	return msg
+}
+
+func (c *client) do(command string, args interface{}, v interface{}) {
+	c.t.Helper()
+	if msg := c.request(command, args, v); !msg.Success {
+		c.t.Fatalf("%s failed: %s", command, msg.Message)
+	}
+}
+
+type stopped struct {
+	Reason   string `json:"reason"`
+	ThreadID int    `json:"threadId"`
+}
+
+// 当前暂停位置的调用栈，由内向外为 "名字:行"
+func (c *client) stack() []StackFrame {
+	c.t.Helper()
+	var body struct {
+		StackFrames []StackFrame `json:"stackFrames"`
+	}
+	c.do("stackTrace", map[string]int{"threadId": threadID}, &body)
+	return body.StackFrames
+}
+
+func (c *client) expectStop(reason string, line int, frames ...string) {
+	c.t.Helper()
+	var s stopped
+	c.event("stopped", &s)
+	if s.Reason != reason {
+		c.t.Errorf("stopped reason = %q, want %q", s.Reason, reason)
+	}
+	stack := c.stack()
+	if len(stack) != len(frames) {
+		c.t.Fatalf("stack has %d frames, want %d: %+v", len(stack), len(frames), stack)
+	}
+	for i, f := range stack {
+		if f.Name != frames[i] {
+			c.t.Errorf("frame %d = %q, want %q", i, f.Name, frames[i])
+		}
+	}
+	if stack[0].Line != line {
+		c.t.Errorf("stopped at line %d, want %d", stack[0].Line, line)
+	}
+}
+
+func launch(t *testing.T, c *client, stopOnEntry bool, breakpoints ...int) []Breakpoint {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.mk")
+	if err := os.WriteFile(path, []byte(program), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c.do("initialize", map[string]interface{}{"adapterID": "monkey"}, nil)
+	c.do("launch", LaunchArguments{Program: path, StopOnEntry: stopOnEntry}, nil)
+	c.event("initialized", nil)
+	var body struct {
+		Breakpoints []Breakpoint `json:"breakpoints"`
+	}
+	var lines []SourceBreakpoint
+	for _, line := range breakpoints {
+		lines = append(lines, SourceBreakpoint{Line: line})
+	}
+	c.do("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: path}, Breakpoints: lines}, &body)
+	c.do("configurationDone", nil, nil)
+	return body.Breakpoints
+}
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	c := start(t)
+	bps := launch(t, c, false, 2, 6, 20)
+	for i, want := range []bool{true, true, false} {
+		if bps[i].Verified != want {
+			t.Errorf("breakpoint %d verified = %t, want %t", i, bps[i].Verified, want)
+		}
+	}
+
+	c.expectStop("breakpoint", 6, "main")
+	c.do("stepIn", map[string]int{"threadId": threadID}, nil)
+	c.expectStop("step", 2, "add", "main")
+	c.do("next", map[string]int{"threadId": threadID}, nil)
+	c.expectStop("step", 3, "add", "main")
+	c.do("stepOut", map[string]int{"threadId": threadID}, nil)
+	c.expectStop("step", 7, "main")
+	c.do("next", map[string]int{"threadId": threadID}, nil)
+	var out struct {
+		Output string `json:"output"`
+	}
+	c.event("output", &out)
+	if out.Output != "3\n" {
+		t.Errorf("output = %q, want %q", out.Output, "3\n")
+	}
+	c.expectStop("step", 8, "main")
+	c.do("continue", map[string]int{"threadId": threadID}, nil)
+	c.expectStop("breakpoint", 2, "add", "main")
+	c.do("continue", map[string]int{"threadId": threadID}, nil)
+
+	var exited struct {
+		ExitCode int `json:"exitCode"`
+	}
+	c.event("terminated", nil)
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("exit code = %d, want 0", exited.ExitCode)
+	}
+	c.do("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestScopesAndEvaluate(t *testing.T) {
+	c := start(t)
+	launch(t, c, false, 3)
+	c.expectStop("breakpoint", 3, "add", "main")
+
+	var scopes struct {
+		Scopes []Scope `json:"scopes"`
+	}
+	c.do("scopes", ScopesArguments{FrameID: 2}, &scopes)
+	var names []string
+	for _, s := range scopes.Scopes {
+		names = append(names, s.Name)
+	}
+	if len(names) != 2 || names[0] != "Locals" || names[1] != "Globals" {
+		t.Fatalf("scopes = %v, want [Locals Globals]", names)
+	}
+
+	variables := func(ref int) map[string]Variable {
+		t.Helper()
+		var body struct {
+			Variables []Variable `json:"variables"`
+		}
+		c.do("variables", VariablesArguments{VariablesReference: ref}, &body)
+		vars := map[string]Variable{}
+		for _, v := range body.Variables {
+			vars[v.Name] = v
+		}
+		return vars
+	}
+	locals := variables(scopes.Scopes[0].VariablesReference)
+	for name, want := range map[string]string{"a": "1", "b": "2", "sum": "3"} {
+		if locals[name].Value != want {
+			t.Errorf("%s = %q, want %q", name, locals[name].Value, want)
+		}
+	}
+	globals := variables(scopes.Scopes[1].VariablesReference)
+	xs := globals["xs"]
+	if xs.Value != "[1, 2]" || xs.VariablesReference == 0 {
+		t.Fatalf("xs = %+v", xs)
+	}
+	if elems := variables(xs.VariablesReference); elems["1"].Value != "2" {
+		t.Errorf("xs[1] = %q, want 2", elems["1"].Value)
+	}
+
+	var result EvaluateResponse
+	c.do("evaluate", map[string]interface{}{"expression": "sum * 10", "frameId": 2}, &result)
+	if result.Result != "30" {
+		t.Errorf("evaluate = %q, want 30", result.Result)
+	}
+	c.do("evaluate", map[string]interface{}{"expression": "len(xs)"}, &result)
+	if result.Result != "2" {
+		t.Errorf("evaluate = %q, want 2", result.Result)
+	}
+	// 顶层调用帧中没有 sum
+	if msg := c.request("evaluate", map[string]interface{}{"expression": "sum", "frameId": 1}, nil); msg.Success {
+		t.Errorf("evaluate in main frame succeeded, want failure")
+	}
+
+	c.do("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestStopOnEntryAndErrors(t *testing.T) {
+	c := start(t)
+	if msg := c.request("launch", LaunchArguments{Program: "missing.mk"}, nil); msg.Success {
+		t.Errorf("launching a missing file succeeded")
+	}
+	if msg := c.request("stackTrace", nil, nil); msg.Success {
+		t.Errorf("stackTrace before launch succeeded")
+	}
+	launch(t, c, true)
+	c.expectStop("entry", 1, "main")
+	c.do("terminate", nil, nil)
+	c.event("terminated", nil)
+	c.do("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
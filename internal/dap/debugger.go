@@ -0,0 +1,145 @@
+package dap
+
+import (
+	"errors"
+	"monkey/internal/ast"
+	"monkey/internal/object"
+	"monkey/internal/token"
+	"sync"
+)
+
+// 单步执行的方式
+type mode int
+
+const (
+	running  mode = iota
+	entry         // 在第一条语句处暂停
+	pausing       // 客户端请求暂停
+	stepIn        // 在下一条语句处暂停
+	stepOver      // 在当前或外层调用帧的下一条语句处暂停
+	stepOut       // 在外层调用帧的下一条语句处暂停
+)
+
+// 调试器终止程序时在求值器中抛出，由运行程序的 goroutine 恢复
+var errTerminated = errors.New("terminated")
+
+type frame struct {
+	name string
+	env  *object.Environment // 当前语句所在的环境
+	pos  token.Position      // 当前语句的位置
+}
+
+// 实现 evaluator.Tracer，在语句之间暂停求值器
+type debugger struct {
+	mu          sync.Mutex
+	breakpoints map[int]bool // 断点所在的行
+	frames      []*frame     // frames[0] 为顶层代码
+	mode        mode
+	depth       int // 开始单步时的调用深度
+	lastLine    int // 上一条语句所在的行，同一行的多条语句只在断点处停一次
+	paused      bool
+	terminated  bool
+	resume      chan mode
+	stopped     func(reason string)
+}
+
+func newDebugger(stopOnEntry bool, stopped func(reason string)) *debugger {
+	d := &debugger{
+		breakpoints: map[int]bool{},
+		frames:      []*frame{{name: "main"}},
+		resume:      make(chan mode),
+		stopped:     stopped,
+	}
+	if stopOnEntry {
+		d.mode = entry
+	}
+	return d
+}
+
+func (d *debugger) Statement(stmt ast.Statement, env *object.Environment) {
+	d.mu.Lock()
+	if d.terminated {
+		d.mu.Unlock()
+		panic(errTerminated)
+	}
+	top := d.frames[len(d.frames)-1]
+	top.env, top.pos = env, ast.StatementToken(stmt).Pos
+
+	var reason string
+	switch {
+	case d.mode == entry:
+		reason = "entry"
+	case d.mode == pausing:
+		reason = "pause"
+	case d.mode == stepIn,
+		d.mode == stepOver && len(d.frames) <= d.depth,
+		d.mode == stepOut && len(d.frames) < d.depth:
+		reason = "step"
+	case d.breakpoints[top.pos.Line] && top.pos.Line != d.lastLine:
+		reason = "breakpoint"
+	}
+	d.lastLine = top.pos.Line
+	if reason == "" {
+		d.mu.Unlock()
+		return
+	}
+	d.paused = true
+	d.mu.Unlock()
+
+	d.stopped(reason)
+	m := <-d.resume
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.paused = false
+	if d.terminated {
+		panic(errTerminated)
+	}
+	d.mode, d.depth = m, len(d.frames)
+}
+
+func (d *debugger) Call(fn *object.Function, args []object.Object, env *object.Environment) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	name := fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	d.frames = append(d.frames, &frame{name: name, env: env, pos: fn.Pos})
+}
+
+func (d *debugger) Return(fn *object.Function, result object.Object) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.frames = d.frames[:len(d.frames)-1]
+}
+
+// 以 m 方式恢复暂停的求值器，没有暂停时什么也不做
+func (d *debugger) proceed(m mode) {
+	d.mu.Lock()
+	paused := d.paused
+	d.mu.Unlock()
+	if paused {
+		d.resume <- m
+	}
+}
+
+// 在下一条语句处暂停
+func (d *debugger) pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.paused {
+		d.mode = pausing
+	}
+}
+
+// 终止程序：暂停时立即恢复并抛出 errTerminated，运行时在下一条语句处抛出
+func (d *debugger) terminate() {
+	d.mu.Lock()
+	d.terminated = true
+	paused := d.paused
+	d.mu.Unlock()
+	if paused {
+		d.resume <- running
+	}
+}
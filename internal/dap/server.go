@@ -0,0 +1,456 @@
+// 通过标准输入输出提供 Debug Adapter Protocol 调试服务，一次调试一个 Monkey 程序
+package dap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/internal/ast"
+	"monkey/internal/evaluator"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"monkey/internal/transport"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const threadID = 1 // 求值器只有一个线程
+
+type Server struct {
+	conn *transport.Conn
+	mu   sync.Mutex // 保护 seq，事件可能来自运行程序的 goroutine
+	seq  int
+
+	lineBase, columnBase int // 客户端行号与列号的起始值
+
+	source     *Source
+	program    *ast.Program
+	lines      map[int]bool // 有语句开始的行
+	debugger   *debugger
+	configured bool
+	done       chan struct{} // 程序结束时关闭
+
+	refs map[int]interface{} // 暂停期间有效的变量引用：*object.Environment 或 object.Object
+
+	after func() // 在当前请求的响应发出之后执行
+}
+
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{conn: transport.NewConn(r, w), lineBase: 1, columnBase: 1}
+}
+
+type handler func(s *Server, args json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":        (*Server).initialize,
+	"launch":            (*Server).launch,
+	"setBreakpoints":    (*Server).setBreakpoints,
+	"configurationDone": (*Server).configurationDone,
+	"threads":           (*Server).threads,
+	"stackTrace":        (*Server).stackTrace,
+	"scopes":            (*Server).scopes,
+	"variables":         (*Server).variables,
+	"evaluate":          (*Server).evaluate,
+	"pause":             (*Server).pause,
+}
+
+// 恢复执行的请求在发送响应之后才恢复求值器，保证响应在后续事件之前
+var resumes = map[string]mode{
+	"continue": running,
+	"next":     stepOver,
+	"stepIn":   stepIn,
+	"stepOut":  stepOut,
+}
+
+// 处理请求直到客户端断开连接或输入结束
+func (s *Server) Run() error {
+	defer s.stop()
+	for {
+		body, err := s.conn.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return err
+		}
+
+		switch req.Command {
+		case "disconnect":
+			s.stop()
+			s.reply(req, nil, nil)
+			return nil
+		case "terminate":
+			s.reply(req, nil, nil)
+			s.stop()
+			continue
+		}
+		if m, ok := resumes[req.Command]; ok {
+			s.reply(req, map[string]bool{"allThreadsContinued": true}, nil)
+			if s.debugger != nil {
+				s.refs = nil
+				s.debugger.proceed(m)
+			}
+			continue
+		}
+		h, ok := handlers[req.Command]
+		if !ok {
+			s.reply(req, nil, fmt.Errorf("unsupported request %q", req.Command))
+			continue
+		}
+		result, err := h(s, req.Arguments)
+		s.reply(req, result, err)
+		if s.after != nil {
+			s.after()
+			s.after = nil
+		}
+	}
+}
+
+func (s *Server) send(msg interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+	s.conn.Write(msg)
+}
+
+func (s *Server) reply(req request, body interface{}, err error) {
+	resp := &response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	s.send(resp)
+}
+
+func (s *Server) event(name string, body interface{}) {
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+func decode(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return json.Unmarshal(args, v)
+}
+
+func (s *Server) initialize(args json.RawMessage) (interface{}, error) {
+	var a InitializeArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if a.LinesStartAt1 != nil && !*a.LinesStartAt1 {
+		s.lineBase = 0
+	}
+	if a.ColumnsStartAt1 != nil && !*a.ColumnsStartAt1 {
+		s.columnBase = 0
+	}
+	return map[string]bool{
+		"supportsConfigurationDoneRequest": true,
+		"supportsEvaluateForHovers":        true,
+		"supportsTerminateRequest":         true,
+	}, nil
+}
+
+// 加载并解析程序，在 configurationDone 之后才开始运行
+func (s *Server) launch(args json.RawMessage) (interface{}, error) {
+	var a LaunchArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if s.program != nil {
+		return nil, fmt.Errorf("a program is already running")
+	}
+	src, err := os.ReadFile(a.Program)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, fmt.Errorf("%s: %s", a.Program, strings.Join(p.Errors(), "\n"))
+	}
+	resolver.Resolve(program, nil)
+
+	s.lines = map[int]bool{}
+	for _, node := range p.Nodes() {
+		if _, ok := node.(ast.Statement); ok {
+			if span, ok := p.Span(node); ok {
+				s.lines[span.Start.Line] = true
+			}
+		}
+	}
+	s.source = &Source{Name: filepath.Base(a.Program), Path: a.Program}
+	s.program = program
+	s.debugger = newDebugger(a.StopOnEntry, func(reason string) {
+		s.event("stopped", map[string]interface{}{"reason": reason, "threadId": threadID, "allThreadsStopped": true})
+	})
+	// 程序加载之后才能验证断点
+	s.after = func() { s.event("initialized", nil) }
+	return nil, nil
+}
+
+func (s *Server) setBreakpoints(args json.RawMessage) (interface{}, error) {
+	var a SetBreakpointsArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if s.debugger == nil {
+		return nil, fmt.Errorf("no program launched")
+	}
+	lines := map[int]bool{}
+	breakpoints := []Breakpoint{}
+	for _, bp := range a.Breakpoints {
+		line := bp.Line - s.lineBase + 1
+		b := Breakpoint{Verified: s.lines[line], Line: bp.Line}
+		if b.Verified {
+			lines[line] = true
+		} else {
+			b.Message = "no statement on this line"
+		}
+		breakpoints = append(breakpoints, b)
+	}
+	s.debugger.mu.Lock()
+	s.debugger.breakpoints = lines
+	s.debugger.mu.Unlock()
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+func (s *Server) configurationDone(json.RawMessage) (interface{}, error) {
+	if s.debugger == nil {
+		return nil, fmt.Errorf("no program launched")
+	}
+	if !s.configured {
+		s.configured = true
+		s.after = s.start
+	}
+	return nil, nil
+}
+
+// 在新的 goroutine 中运行程序，结束时发送 terminated 与 exited 事件
+func (s *Server) start() {
+	s.done = make(chan struct{})
+	go func() {
+		code := 0
+		defer func() {
+			if r := recover(); r != nil && r != errTerminated {
+				panic(r)
+			}
+			s.event("terminated", nil)
+			s.event("exited", map[string]int{"exitCode": code})
+			close(s.done)
+		}()
+
+		ctx := &evaluator.Context{Tracer: s.debugger, Output: outputWriter{s, "stdout"}}
+		result := ctx.Eval(s.program, object.NewEnvironment())
+		if err, ok := result.(*object.Error); ok {
+			outputWriter{s, "stderr"}.Write([]byte("ERROR: " + err.Message + "\n"))
+			code = 1
+		}
+	}()
+}
+
+// 终止正在运行的程序并等待它结束
+func (s *Server) stop() {
+	if s.done == nil {
+		return
+	}
+	s.debugger.terminate()
+	<-s.done
+	s.done = nil
+}
+
+// 把程序的输出转为 output 事件
+type outputWriter struct {
+	s        *Server
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.event("output", map[string]string{"category": w.category, "output": string(p)})
+	return len(p), nil
+}
+
+func (s *Server) threads(json.RawMessage) (interface{}, error) {
+	return map[string][]Thread{"threads": {{ID: threadID, Name: "main"}}}, nil
+}
+
+// 只在暂停时返回调用帧，frame id 为调用帧在栈中的序号加一
+func (s *Server) paused() ([]*frame, error) {
+	if s.debugger == nil {
+		return nil, fmt.Errorf("no program launched")
+	}
+	d := s.debugger
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.paused {
+		return nil, fmt.Errorf("program is not paused")
+	}
+	return append([]*frame(nil), d.frames...), nil
+}
+
+func (s *Server) stackTrace(json.RawMessage) (interface{}, error) {
+	frames, err := s.paused()
+	if err != nil {
+		return nil, err
+	}
+	stack := []StackFrame{}
+	for i := len(frames) - 1; i >= 0; i-- {
+		f := frames[i]
+		stack = append(stack, StackFrame{
+			ID:     i + 1,
+			Name:   f.name,
+			Source: s.source,
+			Line:   f.pos.Line - 1 + s.lineBase,
+			Column: f.pos.Column - 1 + s.columnBase,
+		})
+	}
+	return map[string]interface{}{"stackFrames": stack, "totalFrames": len(stack)}, nil
+}
+
+func (s *Server) frame(id int) (*frame, error) {
+	frames, err := s.paused()
+	if err != nil {
+		return nil, err
+	}
+	if id < 1 || id > len(frames) {
+		return nil, fmt.Errorf("unknown frame %d", id)
+	}
+	return frames[id-1], nil
+}
+
+// 调用帧的环境链，由内向外依次为 Locals、Closure 与 Globals
+func (s *Server) scopes(args json.RawMessage) (interface{}, error) {
+	var a ScopesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	f, err := s.frame(a.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	scopes := []Scope{}
+	for env := f.env; env != nil; env = env.Outer() {
+		name := "Closure"
+		switch {
+		case env.Outer() == nil:
+			name = "Globals"
+		case env == f.env:
+			name = "Locals"
+		}
+		scopes = append(scopes, Scope{Name: name, VariablesReference: s.ref(env)})
+	}
+	return map[string][]Scope{"scopes": scopes}, nil
+}
+
+func (s *Server) ref(v interface{}) int {
+	if s.refs == nil {
+		s.refs = map[int]interface{}{}
+	}
+	id := len(s.refs) + 1
+	s.refs[id] = v
+	return id
+}
+
+func (s *Server) variables(args json.RawMessage) (interface{}, error) {
+	var a VariablesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if _, err := s.paused(); err != nil {
+		return nil, err
+	}
+	variables := []Variable{}
+	switch v := s.refs[a.VariablesReference].(type) {
+	case *object.Environment:
+		for _, name := range v.Names() {
+			value, _ := v.Get(name)
+			variables = append(variables, s.variable(name, value))
+		}
+	case *object.Array:
+		for i, el := range v.Elements {
+			variables = append(variables, s.variable(fmt.Sprint(i), el))
+		}
+	case *object.Hash:
+		for _, pair := range v.Pairs() {
+			variables = append(variables, s.variable(pair.Key.Inspect(), pair.Value))
+		}
+	default:
+		return nil, fmt.Errorf("unknown variables reference %d", a.VariablesReference)
+	}
+	return map[string][]Variable{"variables": variables}, nil
+}
+
+// 非空的数组与哈希可以展开
+func (s *Server) variable(name string, value object.Object) Variable {
+	v := Variable{Name: name, Value: value.Inspect(), Type: string(value.Type())}
+	switch value := value.(type) {
+	case *object.Array:
+		if len(value.Elements) > 0 {
+			v.VariablesReference = s.ref(value)
+		}
+	case *object.Hash:
+		if value.Len() > 0 {
+			v.VariablesReference = s.ref(value)
+		}
+	}
+	return v
+}
+
+// 在暂停的调用帧中求值表达式，没有指定调用帧时使用最内层的
+func (s *Server) evaluate(args json.RawMessage) (interface{}, error) {
+	var a EvaluateArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	frames, err := s.paused()
+	if err != nil {
+		return nil, err
+	}
+	id := len(frames)
+	if a.FrameID != nil {
+		id = *a.FrameID
+	}
+	f, err := s.frame(id)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(a.Expression))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(p.Errors(), "\n"))
+	}
+	// 不经过调试器求值，表达式中的调用不会停在断点上
+	ctx := &evaluator.Context{Output: outputWriter{s, "stdout"}}
+	result := ctx.Eval(program, f.env)
+
+	if result == nil {
+		result = evaluator.NULL
+	}
+	if err, ok := result.(*object.Error); ok {
+		return nil, fmt.Errorf("%s", err.Message)
+	}
+	v := s.variable("", result)
+	return EvaluateResponse{Result: v.Value, Type: v.Type, VariablesReference: v.VariablesReference}, nil
+}
+
+func (s *Server) pause(json.RawMessage) (interface{}, error) {
+	if s.debugger == nil {
+		return nil, fmt.Errorf("no program launched")
+	}
+	s.debugger.pause()
+	return nil, nil
+}
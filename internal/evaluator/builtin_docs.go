@@ -0,0 +1,51 @@
+package evaluator
+
+import (
+	"monkey/internal/object"
+	"sort"
+)
+
+// import 与 quote 是由求值器处理的特殊形式，这里只记录文档与参数个数
+var specialForms = map[string]*object.Builtin{
+	"import": {MinArgs: 1, MaxArgs: 1, Doc: "import(name)\n\nLoads a standard library module and returns its bindings as a hash."},
+	"quote":  {MinArgs: 1, MaxArgs: 1, Doc: "quote(expr)\n\nReturns expr unevaluated as a QUOTE value."},
+}
+
+func lookupBuiltin(name string) (*object.Builtin, bool) {
+	if builtin, ok := builtins[name]; ok {
+		return builtin, true
+	}
+	builtin, ok := specialForms[name]
+	return builtin, ok
+}
+
+// 返回内置函数的文档
+func BuiltinDoc(name string) (string, bool) {
+	builtin, ok := lookupBuiltin(name)
+	if !ok {
+		return "", false
+	}
+	return builtin.Doc, true
+}
+
+// 返回所有内置函数的名字（包括 import 与 quote），按字母排序
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins)+len(specialForms))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name := range specialForms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 返回内置函数接受的最少与最多参数个数，max 为 -1 表示不限
+func BuiltinArity(name string) (min, max int, ok bool) {
+	builtin, ok := lookupBuiltin(name)
+	if !ok {
+		return 0, 0, false
+	}
+	return builtin.MinArgs, builtin.MaxArgs, true
+}
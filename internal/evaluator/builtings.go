@@ -1,28 +1,68 @@
 package evaluator
 
 import (
+	"fmt"
 	"monkey/internal/object"
 	"time"
+	"unicode/utf8"
 )
 
+// 内置函数，Doc 为签名与说明，供编辑器悬停提示与补全使用
 var builtins = map[string]*object.Builtin{
-	"len":       {Fn: lenObject},
-	"timestamp": {Fn: timestamp},
-	"first":     {Fn: first},
-	"last":      {Fn: last},
-	"rest":      {Fn: rest},
-	"push":      {Fn: push},
-	"puts":      {Fn: puts},
+	"len":       {Fn: lenObject, MinArgs: 1, MaxArgs: 1, Doc: "len(x)\n\nReturns the number of characters in a string or elements in an array."},
+	"timestamp": {Fn: timestamp, MinArgs: 0, MaxArgs: 0, Doc: "timestamp()\n\nReturns the current Unix time in seconds."},
+	"first":     {Fn: first, MinArgs: 1, MaxArgs: 1, Doc: "first(arr)\n\nReturns the first element of an array, or null if it is empty."},
+	"last":      {Fn: last, MinArgs: 1, MaxArgs: 1, Doc: "last(arr)\n\nReturns the last element of an array, or null if it is empty."},
+	"rest":      {Fn: rest, MinArgs: 1, MaxArgs: 1, Doc: "rest(arr)\n\nReturns a new array without the first element, or null if it is empty."},
+	"push":      {Fn: push, MinArgs: 2, MaxArgs: 2, Doc: "push(arr, value)\n\nReturns a new array with value appended."},
+	"puts":      {Fn: puts, MinArgs: 0, MaxArgs: -1, Doc: "puts(args...)\n\nPrints each argument on its own line and returns null."},
+	"type":      {Fn: typeOf, MinArgs: 1, MaxArgs: 1, Doc: "type(x)\n\nReturns the type name of x, such as \"INTEGER\"."},
+	"map":       {Fn: mapArray, MinArgs: 2, MaxArgs: 2, Doc: "map(arr, f)\n\nReturns a new array with f(x) for every element x."},
+	"filter":    {Fn: filter, MinArgs: 2, MaxArgs: 2, Doc: "filter(arr, f)\n\nReturns the elements x for which f(x) is truthy."},
+	"reduce":    {Fn: reduce, MinArgs: 2, MaxArgs: 3, Doc: "reduce(arr, f)\nreduce(arr, f, initial)\n\nFolds the array with f(acc, x), starting from initial or the first element."},
+	"each":      {Fn: each, MinArgs: 2, MaxArgs: 2, Doc: "each(arr, f)\n\nCalls f(x) for every element and returns null."},
+	"sort":      {Fn: sortArray, MinArgs: 1, MaxArgs: 2, Doc: "sort(arr)\nsort(arr, less)\n\nReturns a sorted copy. less(a, b) returns true when a goes before b."},
+	"sort_by":   {Fn: sortBy, MinArgs: 2, MaxArgs: 2, Doc: "sort_by(arr, key)\n\nReturns a copy sorted by the natural order of key(x)."},
+	"zip":       {Fn: zip, MinArgs: 1, MaxArgs: -1, Doc: "zip(arrs...)\n\nReturns an array of [a, b, ...] tuples, as long as the shortest input."},
+	"flatten":   {Fn: flatten, MinArgs: 1, MaxArgs: 2, Doc: "flatten(arr)\nflatten(arr, depth)\n\nFlattens nested arrays, at most depth levels deep."},
+	"range":     {Fn: rangeArray, MinArgs: 1, MaxArgs: 3, Doc: "range(end)\nrange(start, end)\nrange(start, end, step)\n\nReturns the integers from start up to, but not including, end."},
+	"find":      {Fn: find, MinArgs: 2, MaxArgs: 2, Doc: "find(arr, f)\n\nReturns the first element x for which f(x) is truthy, or null."},
+	"any":       {Fn: anyOf, MinArgs: 2, MaxArgs: 2, Doc: "any(arr, f)\n\nReports whether f(x) is truthy for some element."},
+	"all":       {Fn: allOf, MinArgs: 2, MaxArgs: 2, Doc: "all(arr, f)\n\nReports whether f(x) is truthy for every element."},
+	"group_by":  {Fn: groupBy, MinArgs: 2, MaxArgs: 2, Doc: "group_by(arr, key)\n\nReturns a hash mapping key(x) to the array of elements with that key."},
+
+	"split":       {Fn: split, MinArgs: 2, MaxArgs: 2, Doc: "split(s, sep)\n\nSplits s around sep. An empty sep splits into characters."},
+	"join":        {Fn: join, MinArgs: 2, MaxArgs: 2, Doc: "join(arr, sep)\n\nConcatenates the elements with sep between them."},
+	"trim":        {Fn: trim, MinArgs: 1, MaxArgs: 2, Doc: "trim(s)\ntrim(s, cutset)\n\nRemoves leading and trailing whitespace, or characters in cutset."},
+	"upper":       {Fn: upper, MinArgs: 1, MaxArgs: 1, Doc: "upper(s)\n\nReturns s in upper case."},
+	"lower":       {Fn: lower, MinArgs: 1, MaxArgs: 1, Doc: "lower(s)\n\nReturns s in lower case."},
+	"replace":     {Fn: replace, MinArgs: 3, MaxArgs: 4, Doc: "replace(s, old, new)\nreplace(s, old, new, n)\n\nReplaces all, or the first n, occurrences of old with new."},
+	"contains":    {Fn: contains, MinArgs: 2, MaxArgs: 2, Doc: "contains(s, sub)\ncontains(arr, value)\n\nReports whether s contains sub, or arr contains value."},
+	"starts_with": {Fn: startsWith, MinArgs: 2, MaxArgs: 2, Doc: "starts_with(s, prefix)\n\nReports whether s begins with prefix."},
+	"ends_with":   {Fn: endsWith, MinArgs: 2, MaxArgs: 2, Doc: "ends_with(s, suffix)\n\nReports whether s ends with suffix."},
+	"index_of":    {Fn: indexOf, MinArgs: 2, MaxArgs: 2, Doc: "index_of(s, sub)\nindex_of(arr, value)\n\nReturns the position of the first match, or -1."},
+	"format":      {Fn: format, MinArgs: 1, MaxArgs: -1, Doc: "format(f, args...)\n\nFormats args printf-style. Supports %s %v %q %d %x %o %b %c %t and %%."},
+	"chars":       {Fn: chars, MinArgs: 1, MaxArgs: 1, Doc: "chars(s)\n\nReturns the characters of s as an array of strings."},
+	"ord":         {Fn: ord, MinArgs: 1, MaxArgs: 1, Doc: "ord(c)\n\nReturns the code point of a single-character string."},
+	"chr":         {Fn: chr, MinArgs: 1, MaxArgs: 1, Doc: "chr(n)\n\nReturns the character for code point n."},
+	"slice":       {Fn: slice, MinArgs: 2, MaxArgs: 3, Doc: "slice(x, start)\nslice(x, start, end)\n\nSame as x[start:end] for strings and arrays."},
+
+	"keys":    {Fn: keys, MinArgs: 1, MaxArgs: 1, Doc: "keys(h)\n\nReturns the keys of a hash in insertion order."},
+	"values":  {Fn: values, MinArgs: 1, MaxArgs: 1, Doc: "values(h)\n\nReturns the values of a hash in insertion order."},
+	"items":   {Fn: items, MinArgs: 1, MaxArgs: 1, Doc: "items(h)\n\nReturns the [key, value] pairs of a hash in insertion order."},
+	"has_key": {Fn: hasKey, MinArgs: 2, MaxArgs: 2, Doc: "has_key(h, key)\n\nReports whether h contains key."},
+	"delete":  {Fn: deleteKey, MinArgs: 2, MaxArgs: 2, Doc: "delete(h, key)\n\nReturns a copy of h without key."},
+	"merge":   {Fn: merge, MinArgs: 1, MaxArgs: -1, Doc: "merge(hashes...)\n\nReturns a new hash with the pairs of all arguments; later keys win."},
+
+	"assert_eq":    {Fn: assertEq, MinArgs: 2, MaxArgs: 3, Doc: "assert_eq(got, want)\nassert_eq(got, want, message)\n\nFails the current test unless got and want are structurally equal."},
+	"assert_true":  {Fn: assertTrue, MinArgs: 1, MaxArgs: 2, Doc: "assert_true(value)\nassert_true(value, message)\n\nFails the current test unless value is true."},
+	"assert_error": {Fn: assertError, MinArgs: 1, MaxArgs: 2, Doc: "assert_error(f)\nassert_error(f, sub)\n\nCalls f() and fails the current test unless it returns an error, containing sub if given. Returns the error message."},
 }
 
-func lenObject(args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1", len(args))
-	}
-
+func lenObject(_ object.Runtime, args ...object.Object) object.Object {
 	switch arg := args[0].(type) {
 	case *object.String:
-		return &object.Integer{Value: int64(len(arg.Value))}
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 	case *object.Array:
 		return &object.Integer{Value: int64(len(arg.Elements))}
 	default:
@@ -30,11 +70,7 @@ func lenObject(args ...object.Object) object.Object {
 	}
 }
 
-func first(args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1", len(args))
-	}
-
+func first(_ object.Runtime, args ...object.Object) object.Object {
 	switch arg := args[0].(type) {
 	case *object.Array:
 		if len(arg.Elements) > 0 {
@@ -46,11 +82,7 @@ func first(args ...object.Object) object.Object {
 	}
 }
 
-func last(args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1", len(args))
-	}
-
+func last(_ object.Runtime, args ...object.Object) object.Object {
 	switch arg := args[0].(type) {
 	case *object.Array:
 		if len(arg.Elements) > 0 {
@@ -62,11 +94,7 @@ func last(args ...object.Object) object.Object {
 	}
 }
 
-func rest(args ...object.Object) object.Object {
-
-	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1", len(args))
-	}
+func rest(_ object.Runtime, args ...object.Object) object.Object {
 
 	switch arg := args[0].(type) {
 	case *object.Array:
@@ -83,10 +111,7 @@ func rest(args ...object.Object) object.Object {
 
 }
 
-func push(args ...object.Object) object.Object{
-	if len(args) != 2 {
-		return newError("wrong number of arguments. got=%d, want=2", len(args))
-	}
+func push(_ object.Runtime, args ...object.Object) object.Object {
 	if args[0].Type() != object.ARRAY_OBJ {
 		return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
 	}
@@ -99,16 +124,17 @@ func push(args ...object.Object) object.Object{
 	return &object.Array{Elements: newElements}
 }
 
-func timestamp(args ...object.Object) object.Object {
-	if len(args) != 0 {
-		return newError("wrong number of arguments. got=%d, want=0", len(args))
-	}
+func timestamp(_ object.Runtime, args ...object.Object) object.Object {
 	return &object.Integer{Value: time.Now().Unix()}
 }
 
-func puts(args ...object.Object) object.Object {
+func puts(rt object.Runtime, args ...object.Object) object.Object {
 	for _, arg := range args {
-		println(arg.Inspect())
+		fmt.Fprintln(rt.Writer(), arg.Inspect())
 	}
 	return NULL
-}
\ No newline at end of file
+}
+
+func typeOf(_ object.Runtime, args ...object.Object) object.Object {
+	return &object.String{Value: string(args[0].Type())}
+}
@@ -0,0 +1,214 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/internal/object"
+	"strconv"
+	"strings"
+)
+
+// 断言失败的错误信息以 "<name> failed" 开头，monkey test 据此区分失败与运行时错误
+var assertions = []string{"assert_eq", "assert_true", "assert_error"}
+
+// 报告错误是否由断言失败产生
+func IsAssertion(err *object.Error) bool {
+	for _, name := range assertions {
+		if strings.HasPrefix(err.Message, name+" failed") {
+			return true
+		}
+	}
+	return false
+}
+
+// 断言失败的错误，可选的最后一个参数是用户给出的说明
+func assertionFailed(name string, message object.Object, details ...string) *object.Error {
+	var out strings.Builder
+	out.WriteString(name + " failed")
+	if message != nil {
+		out.WriteString(": " + message.Inspect())
+	}
+	for _, line := range details {
+		out.WriteString("\n" + line)
+	}
+	return &object.Error{Message: out.String()}
+}
+
+// assert_eq(got, want) 要求两个值结构相等
+func assertEq(_ object.Runtime, args ...object.Object) object.Object {
+	got, want := args[0], args[1]
+	if object.Equal(got, want) {
+		return NULL
+	}
+	return assertionFailed("assert_eq", optional(args, 2), valueDiff(got, want)...)
+}
+
+// assert_true(value) 要求值为 true
+func assertTrue(_ object.Runtime, args ...object.Object) object.Object {
+	if args[0] == TRUE {
+		return NULL
+	}
+	return assertionFailed("assert_true", optional(args, 1), "got:  "+show(args[0], true))
+}
+
+// assert_error(f) 要求调用 f() 返回错误，给出 sub 时错误信息还须包含 sub；返回错误信息
+func assertError(rt object.Runtime, args ...object.Object) object.Object {
+	if !isCallable(args[0]) {
+		return newError("argument to `assert_error` must be FUNCTION, got %s", args[0].Type())
+	}
+	var sub *object.String
+	if len(args) == 2 {
+		s, ok := args[1].(*object.String)
+		if !ok {
+			return newError("second argument to `assert_error` must be STRING, got %s", args[1].Type())
+		}
+		sub = s
+	}
+
+	result := rt.ApplyFunction(args[0])
+	err, ok := result.(*object.Error)
+	if !ok {
+		return assertionFailed("assert_error", nil, "got:  "+show(result, true), "want: an error")
+	}
+	if sub != nil && !strings.Contains(err.Message, sub.Value) {
+		return assertionFailed("assert_error", nil,
+			"got:  "+strconv.Quote(err.Message),
+			"want: an error containing "+strconv.Quote(sub.Value))
+	}
+	return &object.String{Value: err.Message}
+}
+
+func optional(args []object.Object, i int) object.Object {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Builtin:
+		return true
+	}
+	return false
+}
+
+// 描述两个不相等的值：单行值给出 got/want 与首个不同之处，多行字符串给出逐行差异
+func valueDiff(got, want object.Object) []string {
+	g, gok := got.(*object.String)
+	w, wok := want.(*object.String)
+	if gok && wok && (strings.Contains(g.Value, "\n") || strings.Contains(w.Value, "\n")) {
+		return append([]string{"--- want", "+++ got"}, lineDiff(w.Value, g.Value)...)
+	}
+
+	typed := got.Type() != want.Type()
+	lines := []string{"got:  " + show(got, typed), "want: " + show(want, typed)}
+	if path, detail, ok := difference(got, want, ""); ok && path != "" {
+		lines = append(lines, fmt.Sprintf("at %s: %s", path, detail))
+	} else if !typed {
+		if col := firstDifference(show(got, false), show(want, false)); col >= 0 {
+			lines = append(lines, strings.Repeat(" ", len("want: ")+col)+"^")
+		}
+	}
+	return lines
+}
+
+// 值的显示形式，字符串带引号，typed 时附上类型
+func show(obj object.Object, typed bool) string {
+	s := obj.Inspect()
+	if str, ok := obj.(*object.String); ok {
+		s = strconv.Quote(str.Value)
+	}
+	if typed {
+		s += " (" + string(obj.Type()) + ")"
+	}
+	return s
+}
+
+// 找到数组与哈希中第一个不相等的元素，返回其路径（如 [2]["name"]）与不同之处
+func difference(got, want object.Object, path string) (string, string, bool) {
+	switch g := got.(type) {
+	case *object.Array:
+		w, ok := want.(*object.Array)
+		if !ok {
+			break
+		}
+		for i := 0; i < len(g.Elements) && i < len(w.Elements); i++ {
+			if !object.Equal(g.Elements[i], w.Elements[i]) {
+				return difference(g.Elements[i], w.Elements[i], fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+		if len(g.Elements) != len(w.Elements) {
+			return strings.TrimSpace(path + " length"), fmt.Sprintf("got %d, want %d", len(g.Elements), len(w.Elements)), true
+		}
+	case *object.Hash:
+		w, ok := want.(*object.Hash)
+		if !ok {
+			break
+		}
+		for _, pair := range w.Pairs() {
+			key := fmt.Sprintf("%s[%s]", path, show(pair.Key, false))
+			value, ok := g.Get(pair.Key)
+			if !ok {
+				return key, "missing, want " + show(pair.Value, false), true
+			}
+			if !object.Equal(value, pair.Value) {
+				return difference(value, pair.Value, key)
+			}
+		}
+		for _, pair := range g.Pairs() {
+			if _, ok := w.Get(pair.Key); !ok {
+				return fmt.Sprintf("%s[%s]", path, show(pair.Key, false)), "unexpected " + show(pair.Value, false), true
+			}
+		}
+	}
+	typed := got.Type() != want.Type()
+	return path, fmt.Sprintf("got %s, want %s", show(got, typed), show(want, typed)), !object.Equal(got, want)
+}
+
+// 第一个不同字符的位置（按字节），相同时返回 -1
+func firstDifference(a, b string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		if i >= len(a) || i >= len(b) || a[i] != b[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// 逐行比较，删除的行以 "-" 开头，新增的行以 "+" 开头
+func lineDiff(a, b string) []string {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	// lcs[i][j] 为 x[i:] 与 y[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var lines []string
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, " "+x[i])
+			i++
+			j++
+		case j == len(y) || i < len(x) && lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "-"+x[i])
+			i++
+		default:
+			lines = append(lines, "+"+y[j])
+			j++
+		}
+	}
+	return lines
+}
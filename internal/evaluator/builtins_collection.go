@@ -0,0 +1,350 @@
+package evaluator
+
+import (
+	"monkey/internal/object"
+	"sort"
+)
+
+// 检查第一个参数是否为数组，参数个数已由 ApplyFunction 检查
+func arrayArgument(name string, args []object.Object) (*object.Array, *object.Error) {
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, newError("argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+	return arr, nil
+}
+
+func mapArray(rt object.Runtime, args ...object.Object) object.Object {
+	arr, err := arrayArgument("map", args)
+	if err != nil {
+		return err
+	}
+	result := make([]object.Object, 0, len(arr.Elements))
+	for _, e := range arr.Elements {
+		value := rt.ApplyFunction(args[1], e)
+		if isError(value) {
+			return value
+		}
+		result = append(result, value)
+	}
+	return &object.Array{Elements: result}
+}
+
+func filter(rt object.Runtime, args ...object.Object) object.Object {
+	arr, err := arrayArgument("filter", args)
+	if err != nil {
+		return err
+	}
+	result := []object.Object{}
+	for _, e := range arr.Elements {
+		keep := rt.ApplyFunction(args[1], e)
+		if isError(keep) {
+			return keep
+		}
+		if isTruthy(keep) {
+			result = append(result, e)
+		}
+	}
+	return &object.Array{Elements: result}
+}
+
+// reduce(arr, f) 以第一个元素为初始值，reduce(arr, f, initial) 以 initial 为初始值
+func reduce(rt object.Runtime, args ...object.Object) object.Object {
+	arr, err := arrayArgument("reduce", args)
+	if err != nil {
+		return err
+	}
+	elements := arr.Elements
+	var acc object.Object
+	if len(args) == 3 {
+		acc = args[2]
+	} else {
+		if len(elements) == 0 {
+			return newError("reduce of empty array with no initial value")
+		}
+		acc = elements[0]
+		elements = elements[1:]
+	}
+	for _, e := range elements {
+		acc = rt.ApplyFunction(args[1], acc, e)
+		if isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+func each(rt object.Runtime, args ...object.Object) object.Object {
+	arr, err := arrayArgument("each", args)
+	if err != nil {
+		return err
+	}
+	for _, e := range arr.Elements {
+		if result := rt.ApplyFunction(args[1], e); isError(result) {
+			return result
+		}
+	}
+	return NULL
+}
+
+func find(rt object.Runtime, args ...object.Object) object.Object {
+	arr, err := arrayArgument("find", args)
+	if err != nil {
+		return err
+	}
+	for _, e := range arr.Elements {
+		found := rt.ApplyFunction(args[1], e)
+		if isError(found) {
+			return found
+		}
+		if isTruthy(found) {
+			return e
+		}
+	}
+	return NULL
+}
+
+func anyOf(rt object.Runtime, args ...object.Object) object.Object {
+	arr, err := arrayArgument("any", args)
+	if err != nil {
+		return err
+	}
+	for _, e := range arr.Elements {
+		result := rt.ApplyFunction(args[1], e)
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) {
+			return TRUE
+		}
+	}
+	return FALSE
+}
+
+func allOf(rt object.Runtime, args ...object.Object) object.Object {
+	arr, err := arrayArgument("all", args)
+	if err != nil {
+		return err
+	}
+	for _, e := range arr.Elements {
+		result := rt.ApplyFunction(args[1], e)
+		if isError(result) {
+			return result
+		}
+		if !isTruthy(result) {
+			return FALSE
+		}
+	}
+	return TRUE
+}
+
+// sort(arr) 按自然顺序排序，sort(arr, less) 使用比较函数，less(a, b) 为真时 a 排在 b 之前
+func sortArray(rt object.Runtime, args ...object.Object) object.Object {
+	arr, err := arrayArgument("sort", args)
+	if err != nil {
+		return err
+	}
+	result := make([]object.Object, len(arr.Elements))
+	copy(result, arr.Elements)
+
+	var failure object.Object
+	sort.SliceStable(result, func(i, j int) bool {
+		if failure != nil {
+			return false
+		}
+		if len(args) == 2 {
+			less := rt.ApplyFunction(args[1], result[i], result[j])
+			if isError(less) {
+				failure = less
+				return false
+			}
+			return isTruthy(less)
+		}
+		cmp, err := compareObjects(result[i], result[j])
+		if err != nil {
+			failure = err
+			return false
+		}
+		return cmp < 0
+	})
+	if failure != nil {
+		return failure
+	}
+	return &object.Array{Elements: result}
+}
+
+// sort_by(arr, key) 按 key(x) 的自然顺序排序
+func sortBy(rt object.Runtime, args ...object.Object) object.Object {
+	arr, err := arrayArgument("sort_by", args)
+	if err != nil {
+		return err
+	}
+	type keyed struct {
+		key   object.Object
+		value object.Object
+	}
+	items := make([]keyed, 0, len(arr.Elements))
+	for _, e := range arr.Elements {
+		key := rt.ApplyFunction(args[1], e)
+		if isError(key) {
+			return key
+		}
+		items = append(items, keyed{key: key, value: e})
+	}
+
+	var failure object.Object
+	sort.SliceStable(items, func(i, j int) bool {
+		if failure != nil {
+			return false
+		}
+		cmp, err := compareObjects(items[i].key, items[j].key)
+		if err != nil {
+			failure = err
+			return false
+		}
+		return cmp < 0
+	})
+	if failure != nil {
+		return failure
+	}
+
+	result := make([]object.Object, len(items))
+	for i, item := range items {
+		result[i] = item.value
+	}
+	return &object.Array{Elements: result}
+}
+
+// 比较两个值的自然顺序
+func compareObjects(left, right object.Object) (int, *object.Error) {
+	cmp, ok := object.Compare(left, right)
+	if !ok {
+		return 0, newError("cannot compare %s and %s", left.Type(), right.Type())
+	}
+	return cmp, nil
+}
+
+func zip(_ object.Runtime, args ...object.Object) object.Object {
+	arrays := make([]*object.Array, len(args))
+	length := -1
+	for i, arg := range args {
+		arr, ok := arg.(*object.Array)
+		if !ok {
+			return newError("argument to `zip` must be ARRAY, got %s", arg.Type())
+		}
+		arrays[i] = arr
+		if length < 0 || len(arr.Elements) < length {
+			length = len(arr.Elements)
+		}
+	}
+
+	result := make([]object.Object, length)
+	for i := 0; i < length; i++ {
+		tuple := make([]object.Object, len(arrays))
+		for j, arr := range arrays {
+			tuple[j] = arr.Elements[i]
+		}
+		result[i] = &object.Array{Elements: tuple}
+	}
+	return &object.Array{Elements: result}
+}
+
+// flatten(arr) 完全展开嵌套数组，flatten(arr, depth) 最多展开 depth 层
+func flatten(_ object.Runtime, args ...object.Object) object.Object {
+	arr, err := arrayArgument("flatten", args)
+	if err != nil {
+		return err
+	}
+	depth := int64(-1)
+	if len(args) == 2 {
+		d, ok := args[1].(*object.Integer)
+		if !ok {
+			return newError("depth of `flatten` must be INTEGER, got %s", args[1].Type())
+		}
+		depth = d.Value
+	}
+	return &object.Array{Elements: flattenElements(arr.Elements, depth, []object.Object{})}
+}
+
+func flattenElements(elements []object.Object, depth int64, result []object.Object) []object.Object {
+	for _, e := range elements {
+		if inner, ok := e.(*object.Array); ok && depth != 0 {
+			result = flattenElements(inner.Elements, depth-1, result)
+		} else {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// range 最多生成的元素个数
+const maxRangeLen = 1 << 24
+
+// range(end)、range(start, end)、range(start, end, step)，不包含 end
+func rangeArray(_ object.Runtime, args ...object.Object) object.Object {
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return newError("argument to `range` must be INTEGER, got %s", arg.Type())
+		}
+		bounds[i] = integer.Value
+	}
+
+	start, end, step := int64(0), bounds[0], int64(1)
+	if len(bounds) > 1 {
+		start, end = bounds[0], bounds[1]
+	}
+	if len(bounds) > 2 {
+		step = bounds[2]
+	}
+	if step == 0 {
+		return newError("step of `range` must not be zero")
+	}
+
+	// 先按无符号算出元素个数，避免 i += step 在 int64 边界处回绕
+	var span, stride uint64
+	switch {
+	case step > 0 && start < end:
+		span, stride = uint64(end)-uint64(start), uint64(step)
+	case step < 0 && start > end:
+		span, stride = uint64(start)-uint64(end), uint64(-(step+1))+1
+	}
+	count := uint64(0)
+	if span > 0 {
+		count = (span-1)/stride + 1
+	}
+	if count > maxRangeLen {
+		return newError("`range` too large: %d elements, max %d", count, maxRangeLen)
+	}
+
+	result := make([]object.Object, 0, count)
+	for i, n := start, uint64(0); n < count; i, n = i+step, n+1 {
+		result = append(result, &object.Integer{Value: i})
+	}
+	return &object.Array{Elements: result}
+}
+
+// group_by(arr, key) 返回以 key(x) 为键、元素数组为值的哈希
+func groupBy(rt object.Runtime, args ...object.Object) object.Object {
+	arr, err := arrayArgument("group_by", args)
+	if err != nil {
+		return err
+	}
+	groups := object.NewHash()
+	for _, e := range arr.Elements {
+		key := rt.ApplyFunction(args[1], e)
+		if isError(key) {
+			return key
+		}
+		if !object.IsHashable(key) {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+		members := []object.Object{}
+		if group, ok := groups.Get(key); ok {
+			members = group.(*object.Array).Elements
+		}
+		groups.Set(key, &object.Array{Elements: append(members, e)})
+	}
+	return groups
+}
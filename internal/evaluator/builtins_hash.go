@@ -0,0 +1,98 @@
+package evaluator
+
+import "monkey/internal/object"
+
+// 检查第一个参数是否为哈希，参数个数已由 ApplyFunction 检查
+func hashArgument(name string, args []object.Object) (*object.Hash, *object.Error) {
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return nil, newError("argument to `%s` must be HASH, got %s", name, args[0].Type())
+	}
+	return hash, nil
+}
+
+func keys(_ object.Runtime, args ...object.Object) object.Object {
+	hash, err := hashArgument("keys", args)
+	if err != nil {
+		return err
+	}
+	result := make([]object.Object, 0, hash.Len())
+	for _, pair := range hash.Pairs() {
+		result = append(result, pair.Key)
+	}
+	return &object.Array{Elements: result}
+}
+
+func values(_ object.Runtime, args ...object.Object) object.Object {
+	hash, err := hashArgument("values", args)
+	if err != nil {
+		return err
+	}
+	result := make([]object.Object, 0, hash.Len())
+	for _, pair := range hash.Pairs() {
+		result = append(result, pair.Value)
+	}
+	return &object.Array{Elements: result}
+}
+
+// items(h) 返回 [key, value] 数组
+func items(_ object.Runtime, args ...object.Object) object.Object {
+	hash, err := hashArgument("items", args)
+	if err != nil {
+		return err
+	}
+	result := make([]object.Object, 0, hash.Len())
+	for _, pair := range hash.Pairs() {
+		result = append(result, &object.Array{Elements: []object.Object{pair.Key, pair.Value}})
+	}
+	return &object.Array{Elements: result}
+}
+
+func hasKey(_ object.Runtime, args ...object.Object) object.Object {
+	hash, err := hashArgument("has_key", args)
+	if err != nil {
+		return err
+	}
+	if !object.IsHashable(args[1]) {
+		return newError("unusable as hash key: %s", args[1].Type())
+	}
+	_, ok := hash.Get(args[1])
+	return nativeBoolToBooleanObject(ok)
+}
+
+// delete(h, key) 返回删除 key 之后的新哈希，不修改原哈希
+func deleteKey(_ object.Runtime, args ...object.Object) object.Object {
+	hash, err := hashArgument("delete", args)
+	if err != nil {
+		return err
+	}
+	if !object.IsHashable(args[1]) {
+		return newError("unusable as hash key: %s", args[1].Type())
+	}
+	result := copyHash(hash)
+	result.Delete(args[1])
+	return result
+}
+
+// merge(a, b, ...) 返回合并后的新哈希，相同的键以后面的值为准
+func merge(_ object.Runtime, args ...object.Object) object.Object {
+	result := object.NewHash()
+	for _, arg := range args {
+		hash, ok := arg.(*object.Hash)
+		if !ok {
+			return newError("argument to `merge` must be HASH, got %s", arg.Type())
+		}
+		for _, pair := range hash.Pairs() {
+			result.Set(pair.Key, pair.Value)
+		}
+	}
+	return result
+}
+
+func copyHash(hash *object.Hash) *object.Hash {
+	result := object.NewHash()
+	for _, pair := range hash.Pairs() {
+		result.Set(pair.Key, pair.Value)
+	}
+	return result
+}
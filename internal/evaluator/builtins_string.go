@@ -0,0 +1,299 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/internal/object"
+	"strings"
+	"unicode/utf8"
+)
+
+// 检查参数均为字符串，参数个数已由 ApplyFunction 检查
+func stringArguments(name string, args []object.Object) ([]string, *object.Error) {
+	values := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, newError("argument to `%s` must be STRING, got %s", name, arg.Type())
+		}
+		values[i] = str.Value
+	}
+	return values, nil
+}
+
+func stringArray(values []string) *object.Array {
+	elements := make([]object.Object, len(values))
+	for i, v := range values {
+		elements[i] = &object.String{Value: v}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// split(s, sep)，sep 为空字符串时按字符拆分
+func split(_ object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("split", args)
+	if err != nil {
+		return err
+	}
+	return stringArray(strings.Split(values[0], values[1]))
+}
+
+// join(arr, sep)，非字符串元素使用其 Inspect 结果
+func join(_ object.Runtime, args ...object.Object) object.Object {
+	arr, err := arrayArgument("join", args)
+	if err != nil {
+		return err
+	}
+	sep, ok := args[1].(*object.String)
+	if !ok {
+		return newError("separator of `join` must be STRING, got %s", args[1].Type())
+	}
+	parts := make([]string, len(arr.Elements))
+	for i, e := range arr.Elements {
+		parts[i] = e.Inspect()
+	}
+	return &object.String{Value: strings.Join(parts, sep.Value)}
+}
+
+// trim(s) 去除首尾空白，trim(s, cutset) 去除首尾属于 cutset 的字符
+func trim(_ object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("trim", args)
+	if err != nil {
+		return err
+	}
+	if len(values) == 2 {
+		return &object.String{Value: strings.Trim(values[0], values[1])}
+	}
+	return &object.String{Value: strings.TrimSpace(values[0])}
+}
+
+func upper(_ object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("upper", args)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToUpper(values[0])}
+}
+
+func lower(_ object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("lower", args)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToLower(values[0])}
+}
+
+// replace(s, old, new) 替换全部，replace(s, old, new, n) 最多替换 n 处
+func replace(_ object.Runtime, args ...object.Object) object.Object {
+	if len(args) == 4 {
+		n, ok := args[3].(*object.Integer)
+		if !ok {
+			return newError("count of `replace` must be INTEGER, got %s", args[3].Type())
+		}
+		values, err := stringArguments("replace", args[:3])
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: strings.Replace(values[0], values[1], values[2], int(n.Value))}
+	}
+	values, err := stringArguments("replace", args)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ReplaceAll(values[0], values[1], values[2])}
+}
+
+// contains(s, sub) 或 contains(arr, value)
+func contains(_ object.Runtime, args ...object.Object) object.Object {
+	if arr, ok := args[0].(*object.Array); ok {
+		return nativeBoolToBooleanObject(arrayIndexOf(arr, args[1]) >= 0)
+	}
+	values, err := stringArguments("contains", args)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(strings.Contains(values[0], values[1]))
+}
+
+func startsWith(_ object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("starts_with", args)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(strings.HasPrefix(values[0], values[1]))
+}
+
+func endsWith(_ object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("ends_with", args)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(strings.HasSuffix(values[0], values[1]))
+}
+
+// index_of(s, sub) 返回字符位置，index_of(arr, value) 返回元素位置，找不到时返回 -1
+func indexOf(_ object.Runtime, args ...object.Object) object.Object {
+	if arr, ok := args[0].(*object.Array); ok {
+		return &object.Integer{Value: int64(arrayIndexOf(arr, args[1]))}
+	}
+	values, err := stringArguments("index_of", args)
+	if err != nil {
+		return err
+	}
+	i := strings.Index(values[0], values[1])
+	if i < 0 {
+		return &object.Integer{Value: -1}
+	}
+	return &object.Integer{Value: int64(utf8.RuneCountInString(values[0][:i]))}
+}
+
+func arrayIndexOf(arr *object.Array, value object.Object) int {
+	for i, e := range arr.Elements {
+		if object.Equal(e, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+func chars(_ object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("chars", args)
+	if err != nil {
+		return err
+	}
+	return stringArray(strings.Split(values[0], ""))
+}
+
+// ord(c) 返回单个字符的码点
+func ord(_ object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("ord", args)
+	if err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(values[0]) != 1 {
+		return newError("argument to `ord` must be a single character, got %q", values[0])
+	}
+	r, _ := utf8.DecodeRuneInString(values[0])
+	return &object.Integer{Value: int64(r)}
+}
+
+// chr(n) 返回码点对应的字符
+func chr(_ object.Runtime, args ...object.Object) object.Object {
+	n, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("argument to `chr` must be INTEGER, got %s", args[0].Type())
+	}
+	if n.Value < 0 || n.Value > utf8.MaxRune || !utf8.ValidRune(rune(n.Value)) {
+		return newError("invalid code point: %d", n.Value)
+	}
+	return &object.String{Value: string(rune(n.Value))}
+}
+
+// format(f, args...) 按 printf 风格格式化，支持 %s %v %q %d %x %o %b %c %t 与 %%
+func format(_ object.Runtime, args ...object.Object) object.Object {
+	f, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `format` must be STRING, got %s", args[0].Type())
+	}
+
+	var out strings.Builder
+	values := args[1:]
+	next := 0
+	spec := f.Value
+	for i := 0; i < len(spec); i++ {
+		if spec[i] != '%' {
+			out.WriteByte(spec[i])
+			continue
+		}
+		// 读取标志与宽度，直到格式动词
+		j := i + 1
+		for j < len(spec) && strings.IndexByte("+-# 0123456789.", spec[j]) >= 0 {
+			j++
+		}
+		if j >= len(spec) {
+			return newError("format: incomplete verb at end of %q", spec)
+		}
+		verb := spec[j]
+		directive := spec[i : j+1]
+		i = j
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if next >= len(values) {
+			return newError("format: missing argument for %s", directive)
+		}
+		value := values[next]
+		next++
+
+		var arg interface{}
+		switch verb {
+		case 's', 'q':
+			if str, ok := value.(*object.String); ok {
+				arg = str.Value
+			} else {
+				arg = value.Inspect()
+			}
+		case 'v':
+			arg = value.Inspect()
+		case 'd', 'x', 'X', 'o', 'b', 'c':
+			if bigInt, ok := value.(*object.BigInteger); ok && verb != 'c' {
+				out.WriteString(fmt.Sprintf(directive, bigInt.Value))
+				continue
+			}
+			integer, ok := value.(*object.Integer)
+			if !ok {
+				return newError("format: %s expects INTEGER, got %s", directive, value.Type())
+			}
+			arg = integer.Value
+			if verb == 'c' {
+				arg = rune(integer.Value)
+			}
+		case 't':
+			boolean, ok := value.(*object.Boolean)
+			if !ok {
+				return newError("format: %s expects BOOLEAN, got %s", directive, value.Type())
+			}
+			arg = boolean.Value
+		default:
+			return newError("format: unknown verb %s", directive)
+		}
+		out.WriteString(fmt.Sprintf(directive, arg))
+	}
+	if next < len(values) {
+		return newError("format: too many arguments. got=%d, want=%d", len(values), next)
+	}
+	return &object.String{Value: out.String()}
+}
+
+// slice(x, start) 或 slice(x, start, end)，按字符或元素截取，与切片语法 x[start:end] 相同
+func slice(_ object.Runtime, args ...object.Object) object.Object {
+	bounds := make([]int64, len(args)-1)
+	for i, arg := range args[1:] {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return newError("bounds of `slice` must be INTEGER, got %s", arg.Type())
+		}
+		bounds[i] = integer.Value
+	}
+
+	switch x := args[0].(type) {
+	case *object.String:
+		runes := []rune(x.Value)
+		start, end := sliceBounds(bounds[0], sliceEnd(bounds, len(runes)), int64(len(runes)))
+		return &object.String{Value: string(runes[start:end])}
+	case *object.Array:
+		start, end := sliceBounds(bounds[0], sliceEnd(bounds, len(x.Elements)), int64(len(x.Elements)))
+		elements := make([]object.Object, end-start)
+		copy(elements, x.Elements[start:end])
+		return &object.Array{Elements: elements}
+	default:
+		return newError("argument to `slice` not supported, got %s", args[0].Type())
+	}
+}
+
+func sliceEnd(bounds []int64, length int) int64 {
+	if len(bounds) > 1 {
+		return bounds[1]
+	}
+	return int64(length)
+}
@@ -2,8 +2,12 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"monkey/internal/ast"
 	"monkey/internal/object"
+	"strings"
+	"unicode/utf8"
 )
 
 var (
@@ -12,76 +16,89 @@ var (
 	NULL  = &object.Null{}
 )
 
-func Eval(node ast.Node, env *object.Environment) object.Object {
+func (c *Context) Eval(node ast.Node, env *object.Environment) object.Object {
 
 	switch node := node.(type) {
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return c.evalIfExpression(node, env)
+	case *ast.ForExpression:
+		return c.evalForExpression(node, env)
 	case *ast.CallExpression:
 		if node.Function.TokenLiteral() == "quote" {
 			return quote(node.Arguments[0])
 		}
-		function := Eval(node.Function, env)
+		if node.Function.TokenLiteral() == "import" {
+			return c.evalImport(node.Arguments, env)
+		}
+		function := c.Eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
+		args := c.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return c.ApplyFunction(function, args...)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return c.evalHashLiteral(node, env)
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := c.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := c.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
+		index := c.Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return c.evalSliceExpression(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Env: env, Body: body, Slots: node.Slots, Pos: node.Token.Pos}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := c.Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		// 用 let 绑定的函数字面量以绑定的名字作为函数名
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+				fn.Name = node.Name.Value
+			}
+		}
+		env.Bind(slotOf(node.Name), node.Name.Value, val)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := c.Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return c.evalBlockStatement(node, env)
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return c.evalProgram(node.Statements, env)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := c.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return c.Eval(node.Expression, env)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		left := c.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		right := c.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
@@ -89,11 +106,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.TemplateLiteral:
+		return c.evalTemplateLiteral(node, env)
 	}
 
 	return nil
@@ -107,11 +129,11 @@ func isError(obj object.Object) bool {
 	return false
 }
 
-func evalExpressions(expression []ast.Expression, env *object.Environment) []object.Object {
+func (c *Context) evalExpressions(expression []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
 	for _, exp := range expression {
-		evaluated := Eval(exp, env)
+		evaluated := c.Eval(exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -120,25 +142,52 @@ func evalExpressions(expression []ast.Expression, env *object.Environment) []obj
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// 在求值器之外调用函数或内置函数，如 monkey test 调用测试函数；内置函数也由此回调 Monkey 函数
+func (c *Context) ApplyFunction(fn object.Object, args ...object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) < len(fn.Parameters) {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+		}
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if c.Tracer != nil {
+			c.Tracer.Call(fn, args, extendedEnv)
+		}
+		result := unwrapReturnValue(c.Eval(fn.Body, extendedEnv))
+		if c.Tracer != nil {
+			c.Tracer.Return(fn, result)
+		}
+		return result
 	case *object.Builtin:
-		return fn.Fn(args...)
+		if err := checkArity(fn, len(args)); err != nil {
+			return err
+		}
+		return fn.Fn(c, args...)
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 
 }
 
+// 按内置函数表中声明的 MinArgs 与 MaxArgs 检查参数个数，内置函数本身不再检查
+func checkArity(fn *object.Builtin, n int) *object.Error {
+	switch {
+	case n >= fn.MinArgs && (fn.MaxArgs < 0 || n <= fn.MaxArgs):
+		return nil
+	case fn.MaxArgs < 0:
+		return newError("wrong number of arguments. got=%d, want=%d+", n, fn.MinArgs)
+	case fn.MinArgs == fn.MaxArgs:
+		return newError("wrong number of arguments. got=%d, want=%d", n, fn.MinArgs)
+	default:
+		return newError("wrong number of arguments. got=%d, want=%d..%d", n, fn.MinArgs, fn.MaxArgs)
+	}
+}
+
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+	env := object.NewScopedEnvironment(fn.Env, fn.Slots)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		env.Bind(slotOf(param), param.Value, args[paramIdx])
 	}
 	return env
 }
@@ -150,11 +199,14 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
+func (c *Context) evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		if c.Tracer != nil {
+			c.Tracer.Statement(statement, env)
+		}
+		result = c.Eval(statement, env)
 
 		// 如果是返回值，直接返回
 		switch result := result.(type) {
@@ -168,11 +220,14 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (c *Context) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		if c.Tracer != nil {
+			c.Tracer.Statement(statement, env)
+		}
+		result = c.Eval(statement, env)
 		if result != nil {
 			rt := result.Type()
 			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
@@ -195,28 +250,26 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	}
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+func (c *Context) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 
-	pairs := make(map[object.HashKey]object.HashPair)
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	hash := object.NewHash()
+	for _, pair := range node.Pairs {
+		key := c.Eval(pair.Key, env)
 		if isError(key) {
 			return key
 		}
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
+		if !object.IsHashable(key) {
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := c.Eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(key, value)
 	}
-	return &object.Hash{Pairs: pairs}
+	return hash
 
 }
 
@@ -224,6 +277,8 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -233,24 +288,31 @@ func evalIndexExpression(left, index object.Object) object.Object {
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
-	key, ok := index.(object.Hashable)
-	if !ok {
+	if !object.IsHashable(index) {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(index)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
 
 }
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return NULL
+	}
+	idx := integer.Value
 	max := int64(len(arrayObject.Elements) - 1)
+	// 负数索引从末尾开始计数
+	if idx < 0 {
+		idx += max + 1
+	}
 
 	if idx < 0 || idx > max {
 		return NULL
@@ -258,20 +320,160 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+// 按字符（码点）索引字符串
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return NULL
+	}
+	idx := integer.Value
+	max := int64(len(runes) - 1)
+	if idx < 0 {
+		idx += max + 1
+	}
+
+	if idx < 0 || idx > max {
+		return NULL
+	}
+	return &object.String{Value: string(runes[idx])}
+}
+
+func (c *Context) evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := c.Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case *object.String:
+		length = int64(utf8.RuneCountInString(left.Value))
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	start, end := int64(0), length
+	for _, bound := range []struct {
+		node  ast.Expression
+		value *int64
+	}{{node.Start, &start}, {node.End, &end}} {
+		if bound.node == nil {
+			continue
+		}
+		value := c.Eval(bound.node, env)
+		if isError(value) {
+			return value
+		}
+		integer, ok := value.(*object.Integer)
+		if !ok {
+			return newError("slice bounds must be INTEGER, got %s", value.Type())
+		}
+		*bound.value = integer.Value
+	}
+
+	start, end = sliceBounds(start, end, length)
+	switch left := left.(type) {
+	case *object.String:
+		return &object.String{Value: string([]rune(left.Value)[start:end])}
+	default:
+		arr := left.(*object.Array)
+		elements := make([]object.Object, end-start)
+		copy(elements, arr.Elements[start:end])
+		return &object.Array{Elements: elements}
+	}
+}
+
+// 规范化切片边界：负数从末尾计数，越界时截断到边界
+func sliceBounds(start, end, length int64) (int64, int64) {
+	clamp := func(i int64) int64 {
+		if i < 0 {
+			i += length
+		}
+		if i < 0 {
+			return 0
+		}
+		if i > length {
+			return length
+		}
+		return i
+	}
+	start, end = clamp(start), clamp(end)
+	if end < start {
+		end = start
+	}
+	return start, end
+}
+
+func (c *Context) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := c.Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
-	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+	taken := isTruthy(condition)
+	if b, ok := c.Tracer.(BranchTracer); ok {
+		b.Branch(ie, taken)
+	}
+	if taken {
+		return c.Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return c.Eval(ie.Alternative, env)
 	} else {
 		return NULL
 	}
 }
 
+// 依次对数组元素、字符串字符或哈希键值执行循环体
+// 一个循环变量时绑定元素（哈希为键），两个循环变量时绑定索引与元素（哈希为键与值）
+func (c *Context) evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+	iterable := c.Eval(fe.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	var pairs [][2]object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		for i, e := range iterable.Elements {
+			pairs = append(pairs, [2]object.Object{&object.Integer{Value: int64(i)}, e})
+		}
+	case *object.String:
+		for i, r := range []rune(iterable.Value) {
+			pairs = append(pairs, [2]object.Object{&object.Integer{Value: int64(i)}, &object.String{Value: string(r)}})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.Pairs() {
+			if len(fe.Names) == 1 {
+				pairs = append(pairs, [2]object.Object{nil, pair.Key})
+			} else {
+				pairs = append(pairs, [2]object.Object{pair.Key, pair.Value})
+			}
+		}
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	for _, pair := range pairs {
+		loopEnv := object.NewScopedEnvironment(env, fe.Slots)
+		if len(fe.Names) == 1 {
+			loopEnv.Bind(slotOf(fe.Names[0]), fe.Names[0].Value, pair[1])
+		} else {
+			loopEnv.Bind(slotOf(fe.Names[0]), fe.Names[0].Value, pair[0])
+			loopEnv.Bind(slotOf(fe.Names[1]), fe.Names[1].Value, pair[1])
+		}
+		result := c.Eval(fe.Body, loopEnv)
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+	return NULL
+}
+
 func isTruthy(input object.Object) bool {
 	switch input {
 	case NULL:
@@ -284,6 +486,8 @@ func isTruthy(input object.Object) bool {
 		switch node := input.(type) {
 		case *object.Integer:
 			return node.Value > 0
+		case *object.BigInteger:
+			return node.Value.Sign() > 0
 		}
 		return false
 	}
@@ -291,39 +495,100 @@ func isTruthy(input object.Object) bool {
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
+	case operator == "==":
+		return nativeBoolToBooleanObject(object.Equal(left, right))
+	case operator == "!=":
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case operator == "*" && left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalStringRepetition(left.(*object.String), right)
+	case operator == "*" && left.Type() == object.INTEGER_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringRepetition(right.(*object.String), left)
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
-	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
-	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+	case operator == "<" || operator == ">":
+		return evalComparisonExpression(operator, left, right)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 }
 
+// 按 object.Compare 定义的顺序比较同类型的值
+func evalComparisonExpression(operator string, left, right object.Object) object.Object {
+	cmp, ok := object.Compare(left, right)
+	if !ok {
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+	if operator == "<" {
+		return nativeBoolToBooleanObject(cmp < 0)
+	}
+	return nativeBoolToBooleanObject(cmp > 0)
+}
+
+// 拼接模板字符串，字符串直接拼接，其他值使用 Inspect 结果
+func (c *Context) evalTemplateLiteral(tl *ast.TemplateLiteral, env *object.Environment) object.Object {
+	var out strings.Builder
+	for _, part := range tl.Parts {
+		value := c.Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		if str, ok := value.(*object.String); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString(value.Inspect())
+		}
+	}
+	return &object.String{Value: out.String()}
+}
+
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
 	switch {
 	case operator == "+":
 		return &object.String{Value: leftVal + rightVal}
-	case operator == "==":
-		return nativeBoolToBooleanObject(leftVal == rightVal)
-	case operator == "!=":
-		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case operator == "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case operator == ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 }
 
+// 字符串重复结果的最大字节数
+const maxRepeatLen = 1 << 28
+
+// 字符串重复，如 "ab" * 3
+func evalStringRepetition(str *object.String, count object.Object) object.Object {
+	n, ok := count.(*object.Integer)
+	if !ok {
+		return newError("repeat count too large: %s", count.Inspect())
+	}
+	if n.Value < 0 {
+		return newError("negative repeat count: %d", n.Value)
+	}
+	// 先用除法判断，避免 len*n 本身溢出
+	if size := int64(len(str.Value)); size > 0 && n.Value > maxRepeatLen/size {
+		return newError("repeat result too large: %d bytes * %d, max %d bytes", size, n.Value, maxRepeatLen)
+	}
+	return &object.String{Value: strings.Repeat(str.Value, int(n.Value))}
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	// 静态解析过的标识符直接定位到绑定所在的环境，
+	// 绑定尚未赋值时（如 if 中的 let 没有执行）退回按名字查找
+	if node.Resolved {
+		if val, ok := env.Lookup(node.Depth, node.Slot, node.Value); ok {
+			return val
+		}
+	}
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
@@ -335,30 +600,12 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("identifier not found: " + node.Value)
 }
 
-func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
-
-	switch operator {
-	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
-	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
-	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
-	case "<":
-		return nativeBoolToBooleanObject(leftVal < rightVal)
-	case ">":
-		return nativeBoolToBooleanObject(leftVal > rightVal)
-	case "==":
-		return nativeBoolToBooleanObject(leftVal == rightVal)
-	case "!=":
-		return nativeBoolToBooleanObject(leftVal != rightVal)
-	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+// 声明处的标识符在环境中的槽位，未经 resolver 解析或在全局作用域中时为 -1
+func slotOf(ident *ast.Identifier) int {
+	if !ident.Resolved {
+		return -1
 	}
+	return ident.Slot
 }
 
 func evalBangOperatorExpression(right object.Object) object.Object {
@@ -374,6 +621,8 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 		switch node := right.(type) {
 		case *object.Integer:
 			return nativeBoolToBooleanObject(node.Value <= 0)
+		case *object.BigInteger:
+			return nativeBoolToBooleanObject(node.Value.Sign() <= 0)
 		}
 		return FALSE
 	}
@@ -383,7 +632,12 @@ func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 
 	switch node := right.(type) {
 	case *object.Integer:
+		if node.Value == math.MinInt64 {
+			return object.NewInteger(new(big.Int).Neg(big.NewInt(node.Value)))
+		}
 		return &object.Integer{Value: -node.Value}
+	case *object.BigInteger:
+		return object.NewInteger(new(big.Int).Neg(node.Value))
 	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
@@ -1,9 +1,14 @@
 package evaluator
 
 import (
+	"bytes"
+	"fmt"
+	"monkey/internal/ast"
 	"monkey/internal/lexer"
 	"monkey/internal/object"
 	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"strings"
 	"testing"
 )
 
@@ -112,26 +117,34 @@ func TestHashLiterals(t *testing.T) {
 		t.Fatalf("Eval didn't return Hash, got=%T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := []struct {
+		key   object.Object
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong number of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong number of pairs. got=%d", result.Len())
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for i, pair := range result.Pairs() {
+		if pair.Key.Inspect() != expected[i].key.Inspect() {
+			t.Errorf("pair %d has wrong key. want=%s, got=%s", i, expected[i].key.Inspect(), pair.Key.Inspect())
+		}
+
+		value, ok := result.Get(expected[i].key)
 		if !ok {
 			t.Errorf("No pair for given key in Pairs")
+			continue
 		}
 
-		testIntegerObject(t, pair.Value, expectedValue)
+		testIntegerObject(t, value, expected[i].value)
 	}
 
 }
@@ -176,6 +189,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -218,6 +239,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("hello world")`, 11},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`range(1, 2, 3, 4)`, "wrong number of arguments. got=4, want=1..3"},
+		{`zip()`, "wrong number of arguments. got=0, want=1+"},
 	}
 
 	for _, tt := range tests {
@@ -281,6 +304,32 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestTemplateStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Monkey"; "Hello, ${name}!"`, "Hello, Monkey!"},
+		{`let x = 2; "${x} * ${x} = ${x * x}"`, "2 * 2 = 4"},
+		{`"list: ${[1, "a"]}, ok: ${true}"`, "list: [1, a], ok: true"},
+		{`let h = {"k": "v"}; "${h["k"]}${"-${len("abc")}-"}"`, "v-3-"},
+		{"`raw ${x}\\n`", `raw ${x}\n`},
+		{`"\u{1F600} \x41"`, "😀 A"},
+		{`"${1 + true}"`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if str, ok := evaluated.(*object.String); ok {
+			if str.Value != tt.expected {
+				t.Errorf("%s: got=%q, want=%q", tt.input, str.Value, tt.expected)
+			}
+		} else if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
     let newAdder = fn(x) {
@@ -550,6 +599,7 @@ func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
+	resolver.Resolve(program, nil)
 	env := object.NewEnvironment()
 	return Eval(program, env)
 }
@@ -592,3 +642,502 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 
 	return true
 }
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let math = import("math"); math["max"](3, 7)`, 7},
+		{`let list = import("list"); list["sum"]([1, 2, 3])`, 6},
+		{`import("list") == import("list")`, true},
+		{`import("nope")`, "module not found: nope"},
+		{`import(1)`, "argument to `import` must be STRING, got INTEGER"},
+		{`type(import("math"))`, "HASH"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			switch result := evaluated.(type) {
+			case *object.Error:
+				if result.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, result.Message)
+				}
+			case *object.String:
+				if result.Value != expected {
+					t.Errorf("String has wrong value. expected=%q, got=%q", expected, result.Value)
+				}
+			default:
+				t.Errorf("unexpected object. got=%T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestImportConcurrent(t *testing.T) {
+	modulesMu.Lock()
+	delete(modules, "string")
+	modulesMu.Unlock()
+
+	results := make(chan object.Object, 8)
+	for i := 0; i < cap(results); i++ {
+		go func() { results <- testEval(`import("string")`) }()
+	}
+	first := <-results
+	if _, ok := first.(*object.Hash); !ok {
+		t.Fatalf("import did not return a HASH. got=%T (%+v)", first, first)
+	}
+	for i := 1; i < cap(results); i++ {
+		if got := <-results; got != first {
+			t.Errorf("concurrent imports returned different modules")
+		}
+	}
+}
+
+type callCounter struct{ calls int }
+
+func (c *callCounter) Statement(ast.Statement, *object.Environment)                {}
+func (c *callCounter) Call(*object.Function, []object.Object, *object.Environment) { c.calls++ }
+func (c *callCounter) Return(*object.Function, object.Object)                      {}
+
+// 同时进行的求值各自使用自己的观察者与输出
+func TestConcurrentContexts(t *testing.T) {
+	const n = 8
+	done := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			counter := &callCounter{}
+			var out bytes.Buffer
+			ctx := &Context{Tracer: counter, Output: &out}
+			input := fmt.Sprintf(`each(range(50), fn(x) { puts(%d) }); import("list")["sum"]([1, 2])`, i)
+			p := parser.New(lexer.New(input))
+			program := p.ParseProgram()
+			resolver.Resolve(program, nil)
+			ctx.Eval(program, object.NewEnvironment())
+			switch {
+			case out.String() != strings.Repeat(fmt.Sprintf("%d\n", i), 50):
+				done <- fmt.Errorf("%d: wrong output %q", i, out.String())
+			case counter.calls < 51:
+				done <- fmt.Errorf("%d: wrong number of calls %d", i, counter.calls)
+			default:
+				done <- nil
+			}
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`map([], fn(x) { x })`, "[]"},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3, 4]"},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, "10"},
+		{`reduce([1, 2, 3], fn(acc, x) { push(acc, x * x) }, [])`, "[1, 4, 9]"},
+		{`each([1, 2], fn(x) { x })`, "null"},
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, "[3, 2, 1]"},
+		{`sort_by(["ccc", "a", "bb"], fn(s) { len(s) })`, "[a, bb, ccc]"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`flatten([1, [2, [3, [4]]]])`, "[1, 2, 3, 4]"},
+		{`flatten([1, [2, [3, [4]]]], 1)`, "[1, 2, [3, [4]]]"},
+		{`range(4)`, "[0, 1, 2, 3]"},
+		{`range(1, 4)`, "[1, 2, 3]"},
+		{`range(10, 0, -3)`, "[10, 7, 4, 1]"},
+		{`range(9223372036854775800, 9223372036854775807, 5)`, "[9223372036854775800, 9223372036854775805]"},
+		{`range(-9223372036854775800, -9223372036854775807, -5)`, "[-9223372036854775800, -9223372036854775805]"},
+		{`range(4, 1)`, "[]"},
+		{`find([1, 2, 3], fn(x) { x > 1 })`, "2"},
+		{`find([1, 2, 3], fn(x) { x > 5 })`, "null"},
+		{`any([1, 2, 3], fn(x) { x == 2 })`, "true"},
+		{`any([], fn(x) { true })`, "false"},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, "true"},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, "false"},
+		{`group_by([1, 2, 3, 4], fn(x) { x > 2 })[true]`, "[3, 4]"},
+		{`let add = fn(a, b) { a + b }; reduce(map(range(5), fn(x) { add(x, 1) }), add)`, "15"},
+		{`map(1, fn(x) { x })`, "ERROR: argument to `map` must be ARRAY, got INTEGER"},
+		{`map([1], fn(x) { x + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`map([1], fn(x, y) { x })`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`reduce([], fn(a, b) { a })`, "ERROR: reduce of empty array with no initial value"},
+		{`sort([1, "a"])`, "ERROR: cannot compare STRING and INTEGER"},
+		{`range(1, 2, 0)`, "ERROR: step of `range` must not be zero"},
+		{`range(-9223372036854775807, 9223372036854775807)`, "ERROR: `range` too large: 18446744073709551614 elements, max 16777216"},
+		{`group_by([1, 2, 1], fn(x) { [x] })`, "{[1]: [1, 1], [2]: [2]}"},
+		{`group_by([1], fn(x) { fn() { x } })`, "ERROR: unusable as hash key: FUNCTION"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("%s: Eval returned nil", tt.input)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("héllo")`, "5"},
+		{`"héllo"[1]`, "é"},
+		{`"abc"[3]`, "null"},
+		{`"ab" * 3`, "ababab"},
+		{`2 * "ab"`, "abab"},
+		{`"ab" * -1`, "ERROR: negative repeat count: -1"},
+		{`"ab" * 9223372036854775807`, "ERROR: repeat result too large: 2 bytes * 9223372036854775807, max 268435456 bytes"},
+		{`"" * 9223372036854775807`, ""},
+		{`"a" < "b"`, "true"},
+		{`"b" > "c"`, "false"},
+		{`split("a,b,c", ",")`, "[a, b, c]"},
+		{`join([1, "b", true], "-")`, "1-b-true"},
+		{`trim("  hi \n")`, "hi"},
+		{`trim("xxhixx", "x")`, "hi"},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("ÀB")`, "àb"},
+		{`replace("aaa", "a", "b")`, "bbb"},
+		{`replace("aaa", "a", "b", 2)`, "bba"},
+		{`contains("seafood", "foo")`, "true"},
+		{`contains([1, 2, 3], 4)`, "false"},
+		{`starts_with("monkey", "mon")`, "true"},
+		{`ends_with("monkey", "mon")`, "false"},
+		{`index_of("日本語", "語")`, "2"},
+		{`index_of("abc", "z")`, "-1"},
+		{`index_of(["a", "b"], "b")`, "1"},
+		{`chars("日本")`, "[日, 本]"},
+		{`ord("é")`, "233"},
+		{`chr(26085)`, "日"},
+		{`ord("ab")`, `ERROR: argument to ` + "`ord`" + ` must be a single character, got "ab"`},
+		{`chr(-1)`, "ERROR: invalid code point: -1"},
+		{`slice("héllo", 1, 3)`, "él"},
+		{`slice([1, 2, 3], 1)`, "[2, 3]"},
+		{`slice("abc", 2, 10)`, "c"},
+		{`format("%s is %d years", "Monkey", 5)`, "Monkey is 5 years"},
+		{`format("%5d|%-4s|%x|%q|%v|%t|100%%", 42, "ab", 255, "q", [1], true)`, `   42|ab  |ff|"q"|[1]|true|100%`},
+		{`format("%d", "a")`, "ERROR: format: %d expects INTEGER, got STRING"},
+		{`format("%s %s", "a")`, "ERROR: format: missing argument for %s"},
+		{`format("%s", "a", "b")`, "ERROR: format: too many arguments. got=2, want=1"},
+		{`upper(1)`, "ERROR: argument to `upper` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("%s: Eval returned nil", tt.input)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][3:1]", "[]"},
+		{"[1, 2, 3, 4][-10:10]", "[1, 2, 3, 4]"},
+		{"let n = 1; [1, 2, 3][n:n + 1]", "[2]"},
+		{`"héllo"[1:3]`, "él"},
+		{`"monkey"[3:]`, "key"},
+		{`"monkey"[:-3]`, "mon"},
+		{`"monkey"[-1]`, "y"},
+		{`{"a": 1}[0:1]`, "ERROR: slice operator not supported: HASH"},
+		{`[1, 2][true:]`, "ERROR: slice bounds must be INTEGER, got BOOLEAN"},
+		{`slice([1, 2, 3], -2)`, "[2, 3]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 2, "a": 1, 3: true}`, "{b: 2, a: 1, 3: true}"},
+		{`{"a": 1, "b": 2, "a": 3}`, "{a: 3, b: 2}"},
+		{`keys({"b": 2, "a": 1})`, "[b, a]"},
+		{`values({"b": 2, "a": 1})`, "[2, 1]"},
+		{`items({"b": 2, "a": 1})`, "[[b, 2], [a, 1]]"},
+		{`has_key({"a": 1}, "a")`, "true"},
+		{`has_key({"a": 1}, "b")`, "false"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b")`, "{a: 1, c: 3}"},
+		{`let h = {"a": 1, "b": 2}; delete(h, "b"); h`, "{a: 1, b: 2}"},
+		{`delete({"a": 1}, "z")`, "{a: 1}"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, "{a: 1, b: 3, c: 4}"},
+		{`{[1, 2]: "pair"}[[1, 2]]`, "pair"},
+		{`{[1, 2]: "pair"}[[2, 1]]`, "null"},
+		{`{{"a": 1, "b": 2}: "hash"}[{"b": 2, "a": 1}]`, "hash"},
+		{`has_key({[1, [2]]: 1}, [1, [2]])`, "true"},
+		{`{[fn(x) { x }]: 1}`, "ERROR: unusable as hash key: ARRAY"},
+		{`keys([1])`, "ERROR: argument to `keys` must be HASH, got ARRAY"},
+		{`has_key({}, fn(x) { x })`, "ERROR: unusable as hash key: FUNCTION"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestForExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`for (x in [1, 2]) { x }`, "null"},
+		{`let f = fn(xs) { for (x in xs) { if (x > 1) { return x } } }; f([1, 2, 3])`, "2"},
+		{`let f = fn(xs) { for (i, x in xs) { if (x == "c") { return i } } }; f(["a", "b", "c"])`, "2"},
+		{`let f = fn(h) { for (k in h) { return k } }; f({"b": 1, "a": 2})`, "b"},
+		{`let f = fn(h) { for (k, v in h) { if (v == 2) { return [k, v] } } }; f({"b": 1, "a": 2})`, "[a, 2]"},
+		{`let f = fn(s) { for (i, c in s) { if (c == "語") { return i } } }; f("日本語")`, "2"},
+		{`let x = 1; for (x in [5]) { let y = x }; x`, "1"},
+		{`for (x in 5) { x }`, "ERROR: cannot iterate over INTEGER"},
+		{`for (x in [1]) { x + true }`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`[1, 2] == [1, 2]`, true},
+		{`[1, 2] != [1, 2]`, false},
+		{`[1, [2, "a"]] == [1, [2, "a"]]`, true},
+		{`[1, 2] == [2, 1]`, false},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`let f = fn(x) { x }; f == f`, true},
+		{`fn(x) { x } == fn(x) { x }`, true},
+		{`fn(x) { x } == fn(y) { y }`, false},
+		{`let n = if (false) { 1 }; n == if (false) { 2 }`, true},
+		{`1 == "1"`, false},
+		{`1 != "1"`, true},
+		{`len == len`, true},
+		{`len == first`, false},
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`false < true`, true},
+		{`[1, 2] < [1, 3]`, true},
+		{`[1, 2] < [1, 2, 0]`, true},
+		{`[2] > [1, 9]`, true},
+		{`1 < "a"`, "type mismatch: INTEGER < STRING"},
+		{`{} < {}`, "unknown operator: HASH < HASH"},
+		{`[1] < ["a"]`, "unknown operator: ARRAY < ARRAY"},
+		{`sort([[2, 1], [1, 2], [1]])`, "[[1], [1, 2], [2, 1]]"},
+		{`sort([true, false])`, "[false, true]"},
+		{`index_of([[1], [2]], [2])`, "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			got := evaluated.Inspect()
+			if errObj, ok := evaluated.(*object.Error); ok {
+				got = errObj.Message
+			}
+			if got != expected {
+				t.Errorf("%s: got=%q, want=%q", tt.input, got, expected)
+			}
+		}
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"7 % 3", "1"},
+		{"-7 % 3", "-1"},
+		{"2 ** 10", "1024"},
+		{"2 ** 3 ** 2", "512"},
+		{"-2 ** 2", "-4"},
+		{"(-2) ** 3", "-8"},
+		{"2 * 3 ** 2", "18"},
+		{"5 ** 0", "1"},
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"9223372036854775807 * 2", "18446744073709551614"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"2 ** 64", "18446744073709551616"},
+		{"18446744073709551616 == 2 ** 64", "true"},
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"-9223372036854775808 - 1", "-9223372036854775809"},
+		{"1e400 / 1e399", "10"},
+		{"2 ** 100 / 2 ** 99", "2"},
+		{"(2 ** 64 + 5) % 2 ** 32", "5"},
+		{"2 ** 64 - 2 ** 64", "0"},
+		{"-(2 ** 70) / 3", "-393530540239137101141"},
+		{"2 ** 64 > 2 ** 63", "true"},
+		{"2 ** 64 > 5", "true"},
+		{"-(2 ** 64) < 5", "true"},
+		{"2 ** 64 == 2 ** 64", "true"},
+		{"2 ** 64 == 2 ** 64 + 1", "false"},
+		{"{2 ** 64: 1}[2 ** 63 * 2]", "1"},
+		{"if (2 ** 64) { 1 } else { 2 }", "1"},
+		{"!(2 ** 64)", "false"},
+		{"sort([2 ** 64, 1, -(2 ** 64)])", "[-18446744073709551616, 1, 18446744073709551616]"},
+		{`format("%d", 2 ** 64)`, "18446744073709551616"},
+		{"[1, 2][2 ** 64]", "null"},
+		{"1 / 0", "ERROR: division by zero"},
+		{"2 ** 64 / 0", "ERROR: division by zero"},
+		{"1 % 0", "ERROR: division by zero"},
+		{"2 ** -1", "ERROR: negative exponent: -1"},
+		{"2 ** 99999999999", "ERROR: power result too large: 2 bits * 99999999999, max 16777216 bits"},
+		{"(2 ** 64) ** 300000", "ERROR: power result too large: 65 bits * 300000, max 16777216 bits"},
+		{"2 ** 8388608 / 2 ** 8388607", "2"},
+		{"1 ** 99999999999", "1"},
+		{"(-1) ** 99999999999", "-1"},
+		{"0 ** 99999999999", "0"},
+		{`"a" * 2 ** 64`, "ERROR: repeat count too large: 18446744073709551616"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+// 每个内置函数都要有文档，参数个数按声明的范围检查
+func TestBuiltinDocs(t *testing.T) {
+	for _, name := range BuiltinNames() {
+		if doc, ok := BuiltinDoc(name); !ok || !strings.HasPrefix(doc, name+"(") {
+			t.Errorf("builtin %q has no documentation", name)
+		}
+		if min, max, ok := BuiltinArity(name); !ok || min < 0 || max < -1 || (max >= 0 && max < min) {
+			t.Errorf("builtin %q has an invalid arity %d..%d", name, min, max)
+		}
+	}
+
+	// 参数个数超出范围时调用内置函数应当报错
+	for name, builtin := range builtins {
+		min, max := builtin.MinArgs, builtin.MaxArgs
+		var counts []int
+		if min > 0 {
+			counts = append(counts, min-1)
+		}
+		if max >= 0 {
+			counts = append(counts, max+1)
+		}
+		for _, n := range counts {
+			args := make([]object.Object, n)
+			for i := range args {
+				args[i] = NULL
+			}
+			err, ok := (&Context{}).ApplyFunction(builtin, args...).(*object.Error)
+			if !ok || !strings.Contains(err.Message, "wrong number of arguments") {
+				t.Errorf("%s with %d arguments: expected arity error, got=%v", name, n, err)
+			}
+		}
+	}
+}
+
+// 静态解析后按槽位查找，结果必须与按名字逐层查找相同
+func TestResolvedIdentifiers(t *testing.T) {
+	tests := []string{
+		"let x = 1; let f = fn() { x }; let x = 2; f()",
+		"let f = fn() { g() }; let g = fn() { 42 }; f()",
+		"let x = 1; let f = fn(x) { let x = x * 10; x }; [f(2), x]",
+		"let x = 1; let f = fn() { if (false) { let x = 2; }; x }; f()",
+		"let f = fn() { let r = g(); let x = 5; r }; let g = fn() { 1 }; f()",
+		"let x = 0; for (i in [1, 2, 3]) { let x = i; x }; x",
+		"let sum = 0; let add = fn(n) { let sum = sum + n; sum }; [add(1), add(2), sum]",
+		"let counter = fn() { let n = 0; fn() { let n = n + 1; n } }; let c = counter(); [c(), c()]",
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)",
+		"let xs = []; for (k, v in {\"a\": 1}) { let xs = push(xs, [k, v]); xs }",
+		"let f = fn() { y }; f()",
+	}
+
+	for _, input := range tests {
+		plain := parser.New(lexer.New(input)).ParseProgram()
+		expected := Eval(plain, object.NewEnvironment())
+		if got := testEval(input); got.Inspect() != expected.Inspect() {
+			t.Errorf("%q: resolved result differs. want=%s, got=%s", input, expected.Inspect(), got.Inspect())
+		}
+	}
+}
+
+func TestAssertBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // 断言通过时为结果，失败时为错误信息
+	}{
+		{`assert_eq([1, 2], [1, 2])`, "null"},
+		{`assert_true(1 < 2)`, "null"},
+		{`assert_error(fn() { 1 + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`assert_eq(1, 2)`, "assert_eq failed\ngot:  1\nwant: 2\n      ^"},
+		{`assert_eq("1", 1, "types")`, "assert_eq failed: types\ngot:  \"1\" (STRING)\nwant: 1 (INTEGER)"},
+		{`assert_eq([1, [2, 3]], [1, [2, 4]])`, "assert_eq failed\ngot:  [1, [2, 3]]\nwant: [1, [2, 4]]\nat [1][1]: got 3, want 4"},
+		{`assert_eq([1], [1, 2])`, "assert_eq failed\ngot:  [1]\nwant: [1, 2]\nat length: got 1, want 2"},
+		{`assert_eq({"a": 1}, {"a": 1, "b": 2})`, "assert_eq failed\ngot:  {a: 1}\nwant: {a: 1, b: 2}\nat [\"b\"]: missing, want 2"},
+		{`assert_eq({"a": 1, "b": 2}, {"a": 1})`, "assert_eq failed\ngot:  {a: 1, b: 2}\nwant: {a: 1}\nat [\"b\"]: unexpected 2"},
+		{`assert_eq("a\nb", "a\nc")`, "assert_eq failed\n--- want\n+++ got\n a\n-c\n+b"},
+		{`assert_true(1)`, "assert_true failed\ngot:  1 (INTEGER)"},
+		{`assert_error(fn() { 1 })`, "assert_error failed\ngot:  1 (INTEGER)\nwant: an error"},
+		{`assert_error(fn() { -true }, "mismatch")`, "assert_error failed\ngot:  \"unknown operator: -BOOLEAN\"\nwant: an error containing \"mismatch\""},
+		{`assert_error(1)`, "argument to `assert_error` must be FUNCTION, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		result := testEval(tt.input)
+		got := result.Inspect()
+		if err, ok := result.(*object.Error); ok {
+			got = err.Message
+			if IsAssertion(err) != strings.HasPrefix(tt.expected, "assert_") {
+				t.Errorf("%s: IsAssertion=%t", tt.input, IsAssertion(err))
+			}
+		}
+		if got != tt.expected {
+			t.Errorf("%s: wrong result.\nwant=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
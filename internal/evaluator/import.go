@@ -0,0 +1,79 @@
+package evaluator
+
+import (
+	"monkey/internal/ast"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"monkey/internal/stdlib"
+	"strings"
+	"sync"
+)
+
+// 已加载的模块，首次 import 时才求值。playground、调试器和测试运行器
+// 可能并发求值，读写都要持锁；求值本身不持锁，模块之间可以互相 import
+var (
+	modulesMu sync.Mutex
+	modules   = map[string]*object.Hash{}
+)
+
+func (c *Context) evalImport(args []ast.Expression, env *object.Environment) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	name := c.Eval(args[0], env)
+	if isError(name) {
+		return name
+	}
+	str, ok := name.(*object.String)
+	if !ok {
+		return newError("argument to `import` must be STRING, got %s", name.Type())
+	}
+	return c.importModule(str.Value)
+}
+
+func (c *Context) importModule(name string) object.Object {
+	modulesMu.Lock()
+	module, ok := modules[name]
+	modulesMu.Unlock()
+	if ok {
+		return module
+	}
+
+	source, ok := stdlib.Source(name)
+	if !ok {
+		return newError("module not found: %s", name)
+	}
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return newError("module %s: %s", name, strings.Join(p.Errors(), "; "))
+	}
+	resolver.Resolve(program, nil)
+
+	env := object.NewEnvironment()
+	if result := c.Eval(program, env); isError(result) {
+		return result
+	}
+
+	// 导出模块顶层绑定，以下划线开头的名字视为私有
+	module = object.NewHash()
+	for _, n := range env.Names() {
+		if strings.HasPrefix(n, "_") {
+			continue
+		}
+		value, _ := env.Get(n)
+		module.Set(&object.String{Value: n}, value)
+	}
+
+	// 并发加载同一模块时以先存入的为准，保证各处拿到同一个对象
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	if loaded, ok := modules[name]; ok {
+		return loaded
+	}
+	modules[name] = module
+	return module
+}
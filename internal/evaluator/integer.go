@@ -0,0 +1,155 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+	"monkey/internal/object"
+)
+
+// 整数运算，int64 溢出时自动提升为 BigInteger
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if !lok || !rok {
+		return evalBigIntegerInfixExpression(operator, left, right)
+	}
+	leftVal, rightVal := l.Value, r.Value
+
+	switch operator {
+	case "+":
+		if (rightVal > 0 && leftVal > math.MaxInt64-rightVal) || (rightVal < 0 && leftVal < math.MinInt64-rightVal) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: leftVal + rightVal}
+	case "-":
+		if (rightVal < 0 && leftVal > math.MaxInt64+rightVal) || (rightVal > 0 && leftVal < math.MinInt64+rightVal) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: leftVal - rightVal}
+	case "*":
+		if leftVal != 0 && rightVal != 0 {
+			product := leftVal * rightVal
+			if product/rightVal != leftVal || (leftVal == -1 && rightVal == math.MinInt64) || (rightVal == -1 && leftVal == math.MinInt64) {
+				return evalBigIntegerInfixExpression(operator, left, right)
+			}
+			return &object.Integer{Value: product}
+		}
+		return &object.Integer{Value: 0}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		return evalPower(left, right)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// 至少一个操作数是 BigInteger 时的整数运算，结果在 int64 范围内时降回 Integer
+func evalBigIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal, _ := object.ToBigInt(left)
+	rightVal, _ := object.ToBigInt(right)
+
+	switch operator {
+	case "+":
+		return object.NewInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return object.NewInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return object.NewInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		// 与 int64 一致，向零取整
+		return object.NewInteger(new(big.Int).Quo(leftVal, rightVal))
+	case "%":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		return object.NewInteger(new(big.Int).Rem(leftVal, rightVal))
+	case "**":
+		return evalPower(left, right)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// 乘方结果的最大位数
+const maxPowerBits = 1 << 24
+
+// 乘方，指数必须是非负整数
+func evalPower(base, exponent object.Object) object.Object {
+	exp, ok := exponent.(*object.Integer)
+	if !ok {
+		if bigExp, _ := object.ToBigInt(exponent); bigExp.Sign() < 0 {
+			return newError("negative exponent: %s", exponent.Inspect())
+		}
+		return newError("exponent too large: %s", exponent.Inspect())
+	}
+	if exp.Value < 0 {
+		return newError("negative exponent: %d", exp.Value)
+	}
+
+	// 先尝试在 int64 范围内用快速幂计算
+	if b, ok := base.(*object.Integer); ok {
+		if result, ok := powInt64(b.Value, exp.Value); ok {
+			return &object.Integer{Value: result}
+		}
+	}
+	b, _ := object.ToBigInt(base)
+	// 结果约有 exp * b.BitLen() 位，先用除法判断，避免乘积本身溢出。底数为 0、1、-1 时结果不会变大
+	if bits := int64(b.BitLen()); b.CmpAbs(big.NewInt(1)) > 0 && exp.Value > maxPowerBits/bits {
+		return newError("power result too large: %d bits * %d, max %d bits", bits, exp.Value, maxPowerBits)
+	}
+	return object.NewInteger(new(big.Int).Exp(b, big.NewInt(exp.Value), nil))
+}
+
+// 计算 base 的 exp 次方，溢出时返回 false
+func powInt64(base, exp int64) (int64, bool) {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			if !mulInt64(&result, base) {
+				return 0, false
+			}
+		}
+		exp >>= 1
+		if exp > 0 && !mulInt64(&base, base) {
+			return 0, false
+		}
+	}
+	return result, true
+}
+
+func mulInt64(acc *int64, n int64) bool {
+	a := *acc
+	if a == 0 || n == 0 {
+		*acc = 0
+		return true
+	}
+	product := a * n
+	if product/n != a || (a == -1 && n == math.MinInt64) || (n == -1 && a == math.MinInt64) {
+		return false
+	}
+	*acc = product
+	return true
+}
@@ -0,0 +1,77 @@
+package evaluator
+
+import (
+	"io"
+	"monkey/internal/ast"
+	"monkey/internal/object"
+	"os"
+)
+
+// 一次求值的观察者与输出，随求值过程逐层传递，同时进行的多次求值互不影响。
+// 零值不观察，puts 输出到标准错误
+type Context struct {
+	Tracer Tracer    // 求值的观察者，需要多个观察者时用 Tracers 组合
+	Output io.Writer // puts 等内置函数的输出目标，nil 表示标准错误
+}
+
+// 不观察、输出到标准错误的求值
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	return (&Context{}).Eval(node, env)
+}
+
+// 内置函数通过 Writer 输出，实现 object.Runtime
+func (c *Context) Writer() io.Writer {
+	if c.Output == nil {
+		return os.Stderr
+	}
+	return c.Output
+}
+
+// 求值过程的观察者，供调试器、覆盖率与性能分析使用
+type Tracer interface {
+	// 每条语句求值之前调用，env 为语句所在的环境
+	Statement(stmt ast.Statement, env *object.Environment)
+	// 调用用户函数时调用，env 为已经绑定参数的函数环境
+	Call(fn *object.Function, args []object.Object, env *object.Environment)
+	// 用户函数返回（包括出错）时调用
+	Return(fn *object.Function, result object.Object)
+}
+
+// 还需要观察 if 分支的 Tracer 可以实现此接口
+type BranchTracer interface {
+	// if 表达式的条件求值之后调用，taken 为 true 表示执行 consequence，否则为 alternative（可能不存在）
+	Branch(ie *ast.IfExpression, taken bool)
+}
+
+// 把多个观察者组合为一个，按顺序通知
+func Tracers(ts ...Tracer) Tracer {
+	return multiTracer(ts)
+}
+
+type multiTracer []Tracer
+
+func (m multiTracer) Statement(stmt ast.Statement, env *object.Environment) {
+	for _, t := range m {
+		t.Statement(stmt, env)
+	}
+}
+
+func (m multiTracer) Call(fn *object.Function, args []object.Object, env *object.Environment) {
+	for _, t := range m {
+		t.Call(fn, args, env)
+	}
+}
+
+func (m multiTracer) Return(fn *object.Function, result object.Object) {
+	for _, t := range m {
+		t.Return(fn, result)
+	}
+}
+
+func (m multiTracer) Branch(ie *ast.IfExpression, taken bool) {
+	for _, t := range m {
+		if b, ok := t.(BranchTracer); ok {
+			b.Branch(ie, taken)
+		}
+	}
+}
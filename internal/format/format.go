@@ -0,0 +1,666 @@
+// 源码格式化：从语法树重新输出源码，缩进、空格与换行使用统一的风格，并保留注释与字面量的原始写法
+package format
+
+import (
+	"errors"
+	"monkey/internal/ast"
+	"monkey/internal/cst"
+	"monkey/internal/parser"
+	"monkey/internal/token"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxWidth = 80 // 超过这个宽度时拆分调用参数、数组与哈希
+	tabWidth = 4  // 计算宽度时制表符占用的列数
+)
+
+// 格式化源码，源码存在语法错误时返回错误
+func Source(src string) (string, error) {
+	tree := cst.Parse(src)
+	if len(tree.Errors) > 0 {
+		return "", errors.New(strings.Join(tree.Errors, "\n"))
+	}
+
+	p := &printer{
+		tree:        tree,
+		comments:    collectComments(tree),
+		measures:    make(map[ast.Node]measure),
+		atLineStart: true,
+	}
+	p.statements(tree.Root.AST.(*ast.Program).Statements, len(src))
+	if p.newline || !p.atLineStart {
+		p.linebreak()
+	}
+	return string(p.out), nil
+}
+
+type comment struct {
+	text     string
+	offset   int
+	line     int
+	endLine  int
+	trailing bool // 与之前的代码在同一行
+}
+
+func collectComments(tree *cst.Tree) []comment {
+	var comments []comment
+	add := func(trivia []cst.Trivia, trailing bool) {
+		for _, t := range trivia {
+			if t.Kind != cst.LineComment && t.Kind != cst.BlockComment {
+				continue
+			}
+			comments = append(comments, comment{
+				text:     t.Text,
+				offset:   t.Pos.Offset,
+				line:     t.Pos.Line,
+				endLine:  t.Pos.Line + strings.Count(t.Text, "\n"),
+				trailing: trailing,
+			})
+		}
+	}
+	for _, tok := range tree.Tokens {
+		add(tok.Leading, false)
+		add(tok.Trailing, true)
+	}
+	return comments
+}
+
+type printer struct {
+	tree     *cst.Tree
+	out      []byte
+	indent   int
+	col      int // 当前列
+	comments []comment
+	next     int // 下一个尚未输出的注释
+	lastLine int // 最后输出的内容在源码中的行号，为 0 时不保留空行
+
+	broken      bool // 当前代码块中有列表被拆分成多行
+	atLineStart bool // 当前行尚未写入内容
+	newline     bool // 行注释之后必须换行
+	space       bool // 行尾块注释之后需要一个空格
+
+	measures map[ast.Node]measure // 已测量过的节点，fork 出的 printer 共用
+	flat     bool                 // 正在测量：列表与代码块不做选择，直接写在一行内
+	head     int                  // 测量时第一个非空代码块的 { 之后的列，尚未遇到时为 -1
+}
+
+// 节点写在一行内时的宽度
+type measure struct {
+	width int // 整体的宽度，不能写在一行内时为 -1
+	head  int // 到第一处可能换行的位置为止的宽度，是实际排版时第一行宽度的下限
+}
+
+// 复制当前状态，用于尝试一种排版后再决定是否采用
+func (p *printer) fork() *printer {
+	q := *p
+	q.out = nil
+	return &q
+}
+
+// 采用 fork 出的排版结果
+func (p *printer) commit(q *printer) {
+	out := append(p.out, q.out...)
+	*p = *q
+	p.out = out
+}
+
+// 单行排版是否放得下：不含换行，或者第一行不超过最大宽度
+func (p *printer) fits(q *printer, multiline bool) bool {
+	text := string(q.out)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		if !multiline {
+			return false
+		}
+		text = text[:i]
+	}
+	return p.col+width(text) <= maxWidth
+}
+
+// 测量 render 写在一行内的宽度。结果按节点缓存，每层列表与代码块不必为了
+// 尝试单行排版而重新排版整棵子树；只在节点内没有注释时使用，此时结果与位置无关
+func (p *printer) measure(node ast.Node, render func(q *printer)) measure {
+	if m, ok := p.measures[node]; ok {
+		return m
+	}
+	q := &printer{
+		tree:     p.tree,
+		comments: p.comments,
+		next:     p.next,
+		measures: p.measures,
+		flat:     true,
+		head:     -1,
+	}
+	render(q)
+	text := string(q.out)
+	m := measure{width: width(text), head: q.head}
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		m.width = -1
+		if w := width(text[:i]); m.head < 0 || w < m.head {
+			m.head = w
+		}
+	}
+	if m.head < 0 {
+		m.head = m.width
+	}
+	p.measures[node] = m
+	return m
+}
+
+// 下一次 write 开始写入的列
+func (p *printer) startCol() int {
+	switch {
+	case p.newline || p.atLineStart:
+		return p.indent * tabWidth
+	case p.space:
+		return p.col + 1
+	}
+	return p.col
+}
+
+func width(text string) int {
+	return utf8.RuneCountInString(text) + strings.Count(text, "\t")*(tabWidth-1)
+}
+
+func (p *printer) write(s string) {
+	if p.newline {
+		p.linebreak()
+	}
+	if p.atLineStart {
+		p.out = append(p.out, strings.Repeat("\t", p.indent)...)
+		p.col = p.indent * tabWidth
+		p.atLineStart = false
+	} else if p.space {
+		p.out = append(p.out, ' ')
+		p.col++
+	}
+	p.space = false
+	p.out = append(p.out, s...)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.col = width(s[i+1:])
+	} else {
+		p.col += width(s)
+	}
+}
+
+func (p *printer) linebreak() {
+	p.out = append(p.out, '\n')
+	p.col = 0
+	p.atLineStart = true
+	p.newline = false
+	p.space = false
+}
+
+// 开始新的一行，source 为接下来内容在源码中的行号，与上一行之间有空行时保留一个空行
+func (p *printer) startLine(source int) {
+	if !p.atLineStart || p.newline {
+		p.linebreak()
+	}
+	if p.lastLine > 0 && source > p.lastLine+1 && len(p.out) > 0 {
+		p.linebreak()
+	}
+}
+
+// 输出 offset 之前的注释
+func (p *printer) flush(offset int) {
+	for p.next < len(p.comments) && p.comments[p.next].offset < offset {
+		c := p.comments[p.next]
+		p.next++
+		if c.trailing && !p.atLineStart && !p.newline {
+			if p.out[len(p.out)-1] != ' ' {
+				p.out = append(p.out, ' ')
+				p.col++
+			}
+			p.space = false
+			p.write(c.text)
+		} else {
+			p.startLine(c.line)
+			p.write(c.text)
+			p.newline = true
+		}
+		p.lastLine = c.endLine
+		if strings.HasPrefix(c.text, "//") {
+			p.newline = true
+		} else if !p.newline {
+			p.space = true
+		}
+	}
+}
+
+// 输出与 line 行代码同一行、位于 limit 之前的行尾注释
+func (p *printer) trailingComments(line, limit int) {
+	for p.next < len(p.comments) {
+		c := p.comments[p.next]
+		if c.offset >= limit || !c.trailing || c.line != line {
+			return
+		}
+		p.flush(c.offset + 1)
+	}
+}
+
+// 在 from 与 to 之间是否有注释
+func (p *printer) hasComments(from, to int) bool {
+	for _, c := range p.comments[p.next:] {
+		if c.offset >= to {
+			return false
+		}
+		if c.offset >= from {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) node(node ast.Node) *cst.Node {
+	n, _ := p.tree.Node(node)
+	return n
+}
+
+// 节点的第一个与最后一个词法单元
+func (p *printer) bounds(node ast.Node) (first, last *cst.Token) {
+	n := p.node(node)
+	if n == nil {
+		return nil, nil
+	}
+	return n.First(), n.Last()
+}
+
+func (p *printer) statements(stmts []ast.Statement, end int) {
+	for i, stmt := range stmts {
+		first, last := p.bounds(stmt)
+		p.flush(first.Pos.Offset)
+		p.startLine(first.Pos.Line)
+
+		var next ast.Statement
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+		}
+		p.statement(stmt, next)
+
+		p.lastLine = last.End.Line
+		limit := end
+		if next != nil {
+			nextFirst, _ := p.bounds(next)
+			limit = nextFirst.Pos.Offset
+		}
+		p.trailingComments(last.End.Line, limit)
+	}
+	p.flush(end)
+}
+
+// 输出语句，next 为之后的语句，用于决定表达式语句之后是否需要分号
+func (p *printer) statement(stmt ast.Statement, next ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let ")
+		p.write(stmt.Name.Value)
+		p.write(" = ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(stmt.ReturnValue, parser.LOWEST)
+		p.write(";")
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, parser.LOWEST)
+		if p.needsSemicolon(stmt, next) {
+			p.write(";")
+		}
+	}
+}
+
+// 最后一个语句不需要分号；以代码块结尾的 if 与 for 只在下一个语句可能被当作延续时需要分号
+func (p *printer) needsSemicolon(stmt *ast.ExpressionStatement, next ast.Statement) bool {
+	if next == nil {
+		return false
+	}
+	switch stmt.Expression.(type) {
+	case *ast.IfExpression, *ast.ForExpression:
+		first, _ := p.bounds(next)
+		switch first.Type {
+		case token.LPAREN, token.LBRACKET, token.MINUS:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// 表达式的优先级，用于决定是否需要括号
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	}
+	return parser.INDEX + 1
+}
+
+// 源码中是否用括号包围了整个表达式
+func (p *printer) parenthesized(e ast.Expression) bool {
+	n := p.node(e)
+	if n == nil || len(n.Children) == 0 {
+		return false
+	}
+	if first, ok := n.Children[0].(*cst.Token); !ok || first.Type != token.LPAREN {
+		return false
+	}
+	// 子节点内的括号总是成对的，只需检查直接属于该节点的词法单元
+	depth := 0
+	for i, child := range n.Children {
+		tok, ok := child.(*cst.Token)
+		if !ok {
+			continue
+		}
+		switch tok.Type {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			depth--
+			if depth == 0 {
+				return i == len(n.Children)-1
+			}
+		}
+	}
+	return false
+}
+
+// 输出表达式，优先级低于 min 或源码中带括号时加上括号
+func (p *printer) expression(e ast.Expression, min int) {
+	if first, _ := p.bounds(e); first != nil {
+		p.flush(first.Pos.Offset)
+	}
+	if precedence(e) < min || p.parenthesized(e) {
+		p.write("(")
+		p.bare(e)
+		p.write(")")
+		return
+	}
+	p.bare(e)
+}
+
+func (p *printer) bare(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.Boolean:
+		p.write(e.Token.Literal)
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+		p.literal(e)
+	case *ast.TemplateLiteral:
+		p.template(e)
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.expression(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := parser.Precedence(e.Token.Type)
+		left, right := prec, prec+1
+		// ** 右结合
+		if e.Token.Type == token.POWER {
+			left, right = prec+1, prec
+		}
+		p.expression(e.Left, left)
+		p.write(" " + e.Operator + " ")
+		p.expression(e.Right, right)
+	case *ast.IfExpression:
+		p.compound(e, func(q *printer, inline bool) {
+			q.write("if (")
+			q.expression(e.Condition, parser.LOWEST)
+			q.write(") ")
+			q.block(e.Consequence, inline)
+			if e.Alternative != nil {
+				q.write(" else ")
+				q.block(e.Alternative, inline)
+			}
+		}, e.Consequence, e.Alternative)
+	case *ast.ForExpression:
+		p.compound(e, func(q *printer, inline bool) {
+			q.write("for (")
+			for i, name := range e.Names {
+				if i > 0 {
+					q.write(", ")
+				}
+				q.write(name.Value)
+			}
+			q.write(" in ")
+			q.expression(e.Iterable, parser.LOWEST)
+			q.write(") ")
+			q.block(e.Body, inline)
+		}, e.Body)
+	case *ast.FunctionLiteral:
+		p.compound(e, func(q *printer, inline bool) {
+			q.write("fn(")
+			for i, param := range e.Parameters {
+				if i > 0 {
+					q.write(", ")
+				}
+				q.write(param.Value)
+			}
+			q.write(") ")
+			q.block(e.Body, inline)
+		}, e.Body)
+	case *ast.CallExpression:
+		p.expression(e.Function, parser.CALL)
+		p.list(e, "(", ")", len(e.Arguments), func(q *printer, i int) {
+			q.expression(e.Arguments[i], parser.LOWEST)
+		})
+	case *ast.ArrayLiteral:
+		p.list(e, "[", "]", len(e.Elements), func(q *printer, i int) {
+			q.expression(e.Elements[i], parser.LOWEST)
+		})
+	case *ast.HashLiteral:
+		p.list(e, "{", "}", len(e.Pairs), func(q *printer, i int) {
+			q.expression(e.Pairs[i].Key, parser.LOWEST)
+			q.write(": ")
+			q.expression(e.Pairs[i].Value, parser.LOWEST)
+		})
+	case *ast.IndexExpression:
+		p.expression(e.Left, parser.INDEX)
+		p.write("[")
+		p.expression(e.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.SliceExpression:
+		p.expression(e.Left, parser.INDEX)
+		p.write("[")
+		if e.Start != nil {
+			p.expression(e.Start, parser.LOWEST)
+		}
+		p.write(":")
+		if e.End != nil {
+			p.expression(e.End, parser.LOWEST)
+		}
+		p.write("]")
+	default:
+		p.write(e.String())
+	}
+}
+
+// 字面量保留源码中的写法，如 0xFF、1_000 与原始字符串
+func (p *printer) literal(e ast.Expression) {
+	if n := p.node(e); n != nil {
+		p.write(n.Text())
+		return
+	}
+	p.write(e.TokenLiteral())
+}
+
+// 模板字符串的文本部分保留原样，插值表达式重新排版
+func (p *printer) template(e *ast.TemplateLiteral) {
+	n := p.node(e)
+	for _, child := range n.Children {
+		switch child := child.(type) {
+		case *cst.Token:
+			p.write(child.Text)
+		case *cst.Node:
+			if _, ok := child.AST.(*ast.StringLiteral); ok {
+				p.write(child.Text())
+			} else {
+				p.expression(child.AST.(ast.Expression), parser.LOWEST)
+			}
+		}
+	}
+}
+
+// 输出以 open、close 包围、逗号分隔的列表，放不下一行或包含注释时每个元素占一行
+func (p *printer) list(node ast.Node, open, close string, n int, element func(q *printer, i int)) {
+	_, last := p.bounds(node)
+	start := p.openOffset(node, open)
+	if !p.hasComments(start, last.Pos.Offset) {
+		inline := func(q *printer) {
+			q.write(open)
+			for i := 0; i < n; i++ {
+				if i > 0 {
+					q.write(", ")
+				}
+				element(q, i)
+			}
+			q.write(close)
+		}
+		if p.flat {
+			inline(p)
+			return
+		}
+		// 整体放得下一行时内层也都放得下；第一处可能换行之前就放不下时一定要拆分；
+		// 其余情况（如以多行函数结尾的调用）才需要实际尝试
+		m := p.measure(node, inline)
+		if m.width >= 0 && p.startCol()+m.width <= maxWidth {
+			inline(p)
+			return
+		}
+		if p.startCol()+m.head <= maxWidth {
+			q := p.fork()
+			q.broken = false
+			inline(q)
+			// 内层列表已被拆分时，优先拆分外层
+			if !q.broken && p.fits(q, true) {
+				broken := p.broken
+				p.commit(q)
+				p.broken = broken
+				return
+			}
+		}
+	}
+
+	p.broken = true
+	p.write(open)
+	p.indent++
+	// 与代码块一样，第一个元素前的注释不因之前的语句空行
+	lastLine := p.lastLine
+	p.lastLine = 0
+	for i := 0; i < n; i++ {
+		first, last := p.elementBounds(node, i)
+		p.flush(first.Pos.Offset)
+		p.startLine(0)
+		element(p, i)
+		if i < n-1 {
+			p.write(",")
+		}
+		p.lastLine = last.End.Line
+	}
+	p.flush(last.Pos.Offset)
+	p.indent--
+	p.lastLine = lastLine
+	p.startLine(0)
+	p.write(close)
+}
+
+// 列表左括号的位置，左括号直接属于列表节点
+func (p *printer) openOffset(node ast.Node, open string) int {
+	for _, child := range p.node(node).Children {
+		tok, ok := child.(*cst.Token)
+		if !ok {
+			continue
+		}
+		if tok.Text == open {
+			if call, ok := node.(*ast.CallExpression); ok {
+				// 调用的左括号在被调用的表达式之后
+				if _, last := p.bounds(call.Function); tok.Pos.Offset < last.End.Offset {
+					continue
+				}
+			}
+			return tok.Pos.Offset
+		}
+	}
+	return 0
+}
+
+// 列表中第 i 个元素的第一个与最后一个词法单元，键值对从键开始到值结束
+func (p *printer) elementBounds(node ast.Node, i int) (first, last *cst.Token) {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		return p.bounds(node.Arguments[i])
+	case *ast.ArrayLiteral:
+		return p.bounds(node.Elements[i])
+	case *ast.HashLiteral:
+		first, _ = p.bounds(node.Pairs[i].Key)
+		_, last = p.bounds(node.Pairs[i].Value)
+	}
+	return first, last
+}
+
+// 输出带代码块的表达式，所有代码块在源码中都写在一行内、没有注释且整体放得下时保持在一行
+func (p *printer) compound(node ast.Node, render func(q *printer, inline bool), blocks ...*ast.BlockStatement) {
+	inline := true
+	for _, b := range blocks {
+		if b == nil {
+			continue
+		}
+		open, close := p.bounds(b)
+		if open.Pos.Line != close.Pos.Line || p.hasComments(open.Pos.Offset, close.Pos.Offset) {
+			inline = false
+		}
+	}
+	if p.flat || !inline {
+		render(p, inline)
+		return
+	}
+	if first, last := p.bounds(node); !p.hasComments(first.Pos.Offset, last.End.Offset) {
+		m := p.measure(node, func(q *printer) { render(q, true) })
+		render(p, m.width >= 0 && p.startCol()+m.width <= maxWidth)
+		return
+	}
+	q := p.fork()
+	render(q, true)
+	if p.fits(q, false) {
+		p.commit(q)
+		return
+	}
+	render(p, false)
+}
+
+// 输出代码块，inline 为真时写在一行内
+func (p *printer) block(b *ast.BlockStatement, inline bool) {
+	open, close := p.bounds(b)
+	if len(b.Statements) == 0 && !p.hasComments(open.Pos.Offset, close.Pos.Offset) {
+		p.write("{}")
+		return
+	}
+	if inline {
+		p.write("{ ")
+		if p.flat && p.head < 0 {
+			p.head = p.col - 1
+		}
+		for i, stmt := range b.Statements {
+			if i+1 < len(b.Statements) {
+				p.statement(stmt, b.Statements[i+1])
+				p.write(" ")
+			} else {
+				p.statement(stmt, nil)
+			}
+		}
+		p.write(" }")
+		return
+	}
+
+	p.write("{")
+	p.indent++
+	lastLine, broken := p.lastLine, p.broken
+	p.lastLine = 0
+	p.statements(b.Statements, close.Pos.Offset)
+	p.indent--
+	p.lastLine, p.broken = lastLine, broken
+	p.startLine(0)
+	p.write("}")
+}
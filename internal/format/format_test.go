@@ -0,0 +1,149 @@
+package format
+
+import (
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1", "let x = 1;\n"},
+		{"let  add=fn(a,b){a+b};add(1,2);", "let add = fn(a, b) { a + b };\nadd(1, 2)\n"},
+		{"puts(1)\nputs(2)", "puts(1);\nputs(2)\n"},
+		{"-x**2+(-x)**2", "-x ** 2 + (-x) ** 2\n"},
+		{"(a-b)-c-(d-e)", "(a - b) - c - (d - e)\n"},
+		{"a ** (b ** c)", "a ** (b ** c)\n"},
+		{"(a ** b) ** c", "(a ** b) ** c\n"},
+		{"0x1F+1_000+1e3", "0x1F + 1_000 + 1e3\n"},
+		{"`raw\n  ${x}`", "`raw\n  ${x}`\n"},
+		{`"a ${ x+1 } \u{1F600}"`, `"a ${x + 1} \u{1F600}"` + "\n"},
+		{"xs[1:];xs[:2];xs[ 1 : 2 ]", "xs[1:];\nxs[:2];\nxs[1:2]\n"},
+		{"{}", "{}\n"},
+		{"if(a){b}else{c}", "if (a) { b } else { c }\n"},
+		{"if (a) {\nb } else { c }", "if (a) {\n\tb\n} else {\n\tc\n}\n"},
+		{"for(k,v in h){\nputs(k)\n}", "for (k, v in h) {\n\tputs(k)\n}\n"},
+		{"if (a) { b };\n(c)", "if (a) { b };\n(c)\n"},
+		{"if (a) { b }\nc", "if (a) { b }\nc\n"},
+		{"fn() {\n}", "fn() {}\n"},
+		{"let x = 1;\n\n\n\nlet y = 2;", "let x = 1;\n\nlet y = 2;\n"},
+		{"let f = fn() {\n\n  a\n\n};", "let f = fn() {\n\ta\n};\n"},
+		{
+			"// 开头\n\nlet x = 1; // 行尾\n/* 块 */\nx",
+			"// 开头\n\nlet x = 1; // 行尾\n/* 块 */\nx\n",
+		},
+		{"let f = fn() {\n// 只有注释\n};", "let f = fn() {\n\t// 只有注释\n};\n"},
+		{"f(1, // 一\n2)", "f(\n\t1, // 一\n\t2\n)\n"},
+		{
+			"let x = 1;\nlet h = {\n// 一\n\"a\": 1,\n// 二\n\"b\": 2\n};",
+			"let x = 1;\nlet h = {\n\t// 一\n\t\"a\": 1,\n\t// 二\n\t\"b\": 2\n};\n",
+		},
+		{"let x = 1;\n\nf(\n// 一\n1, 2)", "let x = 1;\n\nf(\n\t// 一\n\t1,\n\t2\n)\n"},
+		{"1 + /* 中间 */ 2", "1 + /* 中间 */ 2\n"},
+		{
+			"let result = some_function(first_argument, second_argument, third_argument, fourth);",
+			"let result = some_function(\n\tfirst_argument,\n\tsecond_argument,\n\tthird_argument,\n\tfourth\n);\n",
+		},
+		{
+			`let h = {"name": "monkey", "tags": ["interpreted", "tree-walking"], "nested": {"a": 1}};`,
+			"let h = {\n\t\"name\": \"monkey\",\n\t\"tags\": [\"interpreted\", \"tree-walking\"],\n\t\"nested\": {\"a\": 1}\n};\n",
+		},
+		{
+			"map(xs, fn(x) {\nx * 2\n})",
+			"map(xs, fn(x) {\n\tx * 2\n})\n",
+		},
+		{"", ""},
+		{"// 只有注释", "// 只有注释\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := Source(tt.input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", tt.input, err)
+		}
+		if got != tt.expected {
+			t.Errorf("%q: wrong output.\nwant=%q\ngot= %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let = 1;", "expected next token to be IDENT, got = instead\nno prefix parse function for = found"},
+		{"if (x) {", "expected next token to be }, got EOF instead"},
+		{"for (x in y) {", "expected next token to be }, got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		_, err := Source(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. got=%v", tt.input, err)
+		}
+	}
+}
+
+// 格式化结果应当与源码语义相同，并且再次格式化时不变
+func TestIdempotent(t *testing.T) {
+	files, _ := filepath.Glob("../stdlib/lib/*.mk")
+	tests, _ := filepath.Glob("../stdlib/testdata/*.mk")
+	files = append(files, tests...)
+	if len(files) == 0 {
+		t.Fatal("no source files found")
+	}
+
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		once, err := Source(string(src))
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+		twice, err := Source(once)
+		if err != nil {
+			t.Fatalf("%s: formatted output does not parse: %s", file, err)
+		}
+		if once != twice {
+			t.Errorf("%s: not idempotent.\nonce=%q\ntwice=%q", file, once, twice)
+		}
+		if parse(string(src)) != parse(once) {
+			t.Errorf("%s: formatting changed the program", file)
+		}
+	}
+}
+
+func parse(src string) string {
+	p := parser.New(lexer.New(src))
+	return p.ParseProgram().String()
+}
+
+// 每层都放不下一行的嵌套调用与函数，排版时间不应随嵌套层数指数增长
+func TestDeepNesting(t *testing.T) {
+	calls, fns := "1", "1"
+	for i := 0; i < 40; i++ {
+		calls = "function_name(" + calls + ", argument)"
+		fns = "fn(x) { if (x) { " + fns + " } else { [x, x] } }"
+	}
+
+	for _, src := range []string{calls, fns} {
+		once, err := Source(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if twice, _ := Source(once); once != twice {
+			t.Errorf("not idempotent.\nonce=%q\ntwice=%q", once, twice)
+		}
+		if parse(src) != parse(once) {
+			t.Errorf("formatting changed the program")
+		}
+	}
+}
@@ -1,21 +1,52 @@
 package lexer
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"monkey/internal/token"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// 带位置的词法错误
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
 type Lexer struct {
-	input        string
-	position     int  // 当前字符的位置
+	reader       *bufio.Reader
+	position     int  // 当前字符的位置（字节偏移）
 	readPosition int  // 当前读取字符的位置
-	ch           byte // 当前字符
+	ch           rune // 当前字符
+	invalid      byte // 当前字符为非法 UTF-8 时的原始字节，否则为 0
+	line         int  // 当前字符所在行
+	column       int  // 当前字符所在列
 
+	recording bool            // 是否记录读过的源码文本
+	text      strings.Builder // 记录的源码文本
+
+	keepComments bool  // 是否以 COMMENT 词法单元返回注释
+	templates    []int // 未结束的模板插值，记录每层插值中未闭合的 { 数量
+	errors       []*Error
+	readFailed   bool // 读取源码时发生过错误
+	eof          bool // 已读到输入末尾
 }
 
 func New(input string) *Lexer {
+	return NewReader(strings.NewReader(input))
+}
+
+// 从 io.Reader 增量读取源码，只缓冲有限的内容，词法单元与 New 完全相同
+func NewReader(r io.Reader) *Lexer {
 
-	l := &Lexer{input: input}
+	l := &Lexer{reader: bufio.NewReader(r), line: 1}
 
 	l.readChar()
 
@@ -23,27 +54,114 @@ func New(input string) *Lexer {
 
 }
 
+// 按 UTF-8 解码读取下一个字符
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+	if l.eof {
+		return
+	}
+	if l.recording {
+		l.writeChar(&l.text)
+	}
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.column++
+
+	b := l.peekBytes()
+	if len(b) == 0 {
+		l.ch = 0
+		l.invalid = 0
+		l.eof = true
+		return
+	}
+	r, size := utf8.DecodeRune(b)
+	l.ch = r
+	l.invalid = 0
+	if r == utf8.RuneError && size == 1 {
+		l.invalid = b[0]
+		l.errorf(l.pos(), "invalid UTF-8 encoding %#x", b[0])
+	}
+	l.reader.Discard(size)
+	l.readPosition += size
+}
+
+// 查看之后最多一个字符的字节，读取失败时记录错误并视为输入结束
+func (l *Lexer) peekBytes() []byte {
+	b, err := l.reader.Peek(utf8.UTFMax)
+	if len(b) == 0 && err != nil && err != io.EOF && !l.readFailed {
+		l.readFailed = true
+		l.errorf(l.pos(), "read error: %s", err)
+	}
+	return b
+}
+
+// 从当前字符开始记录源码文本
+func (l *Lexer) startText() {
+	l.text.Reset()
+	l.recording = true
+}
+
+// 停止记录，返回从 startText 到当前字符之前的源码文本
+func (l *Lexer) endText() string {
+	l.recording = false
+	return l.text.String()
+}
+
+// 当前字符的位置
+func (l *Lexer) pos() token.Position {
+	return token.Position{Offset: l.position, Line: l.line, Column: l.column}
+}
+
+func (l *Lexer) errorf(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
 }
 
-// 读取下一个字符
+// 返回词法错误
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+// 设置是否保留注释，保留时注释作为 COMMENT 词法单元返回，供格式化等工具使用
+func (l *Lexer) KeepComments(keep bool) {
+	l.keepComments = keep
+}
+
+// 读取下一个词法单元
 func (l *Lexer) NextToken() token.Token {
+	tok := l.nextToken()
+	tok.End = l.pos()
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
 
+	// 跳过注释，支持 // 行注释与可嵌套的 /* */ 块注释
+	for l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*') {
+		tok = l.readComment()
+		if l.keepComments || tok.Type == token.ILLEGAL {
+			return tok
+		}
+		l.skipWhitespace()
+	}
+
+	tok.Pos = l.pos()
 	switch l.ch {
 	case '"':
-		// 读取字符串
+		// 读取字符串，遇到 ${ 时作为模板字符串的开头返回
+		text, interpolated := l.readString(tok.Pos)
+		tok.Type, tok.Literal = token.STRING, text
+		if interpolated {
+			tok.Type = token.TEMPLATE_HEAD
+		}
+	case '`':
+		// 原始字符串，可以跨行，不处理转义
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readRawString(tok.Pos)
 	case '=':
 		if l.peekChar() == '=' {
 			// 读取下一个字符
@@ -51,32 +169,49 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			// 读取下一个字符
 			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.EQ, Literal: literal}
+			tok = token.Token{Type: token.EQ, Literal: literal, Pos: tok.Pos}
 		} else {
-			tok = newToken(token.ASSIGN, l.ch)
+			tok = newToken(token.ASSIGN, l.ch, tok.Pos)
 		}
 	case ';':
-		tok = newToken(token.SEMICOLON, l.ch)
+		tok = newToken(token.SEMICOLON, l.ch, tok.Pos)
 	case '[':
-		tok = newToken(token.LBRACKET, l.ch)
+		tok = newToken(token.LBRACKET, l.ch, tok.Pos)
 	case ']':
-		tok = newToken(token.RBRACKET, l.ch)
+		tok = newToken(token.RBRACKET, l.ch, tok.Pos)
 	case '(':
-		tok = newToken(token.LPAREN, l.ch)
+		tok = newToken(token.LPAREN, l.ch, tok.Pos)
 	case ')':
-		tok = newToken(token.RPAREN, l.ch)
+		tok = newToken(token.RPAREN, l.ch, tok.Pos)
 	case ',':
-		tok = newToken(token.COMMA, l.ch)
+		tok = newToken(token.COMMA, l.ch, tok.Pos)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = newToken(token.PLUS, l.ch, tok.Pos)
 	case '{':
-		tok = newToken(token.LBRACE, l.ch)
+		if n := len(l.templates); n > 0 {
+			l.templates[n-1]++
+		}
+		tok = newToken(token.LBRACE, l.ch, tok.Pos)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		n := len(l.templates)
+		if n > 0 && l.templates[n-1] == 0 {
+			// 插值结束，继续读取模板字符串的剩余部分
+			l.templates = l.templates[:n-1]
+			text, interpolated := l.readString(tok.Pos)
+			tok.Type, tok.Literal = token.TEMPLATE_TAIL, text
+			if interpolated {
+				tok.Type = token.TEMPLATE_MIDDLE
+			}
+			break
+		}
+		if n > 0 {
+			l.templates[n-1]--
+		}
+		tok = newToken(token.RBRACE, l.ch, tok.Pos)
 	case ':':
-		tok = newToken(token.COLON, l.ch)
+		tok = newToken(token.COLON, l.ch, tok.Pos)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = newToken(token.MINUS, l.ch, tok.Pos)
 	case '!':
 		if l.peekChar() == '=' {
 			// 读取下一个字符
@@ -84,19 +219,31 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			// 读取下一个字符
 			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.NOT_EQ, Literal: literal}
+			tok = token.Token{Type: token.NOT_EQ, Literal: literal, Pos: tok.Pos}
 		} else {
-			tok = newToken(token.BANG, l.ch)
+			tok = newToken(token.BANG, l.ch, tok.Pos)
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = newToken(token.SLASH, l.ch, tok.Pos)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			l.readChar()
+			tok = token.Token{Type: token.POWER, Literal: "**", Pos: tok.Pos}
+		} else {
+			tok = newToken(token.ASTERISK, l.ch, tok.Pos)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch, tok.Pos)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		tok = newToken(token.LT, l.ch, tok.Pos)
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		tok = newToken(token.GT, l.ch, tok.Pos)
 	case 0:
+		// 输入中间的 NUL 是非法字符，不能当作输入结束
+		if !l.eof {
+			tok = newToken(token.ILLEGAL, l.ch, tok.Pos)
+			break
+		}
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
@@ -109,7 +256,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Literal = l.readNumber()
 			return tok
 		} else { // 未知字符
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = newToken(token.ILLEGAL, l.ch, tok.Pos)
 		}
 	}
 
@@ -118,76 +265,220 @@ func (l *Lexer) NextToken() token.Token {
 
 }
 
-func (l *Lexer) readString() string {
+// 读取字符串直到结束的 " 或插值开头的 ${，后者返回 interpolated 为真
+func (l *Lexer) readString(start token.Position) (text string, interpolated bool) {
 
 	buffer := strings.Builder{}
 
 	for {
 		l.readChar()
-		if l.ch == '\\' {
+		switch l.ch {
+		case '"':
+			return buffer.String(), false
+		case 0:
+			if !l.eof {
+				l.writeChar(&buffer)
+				break
+			}
+			l.errorf(start, "unterminated string")
+			return buffer.String(), false
+		case '$':
+			if l.peekChar() == '{' {
+				l.readChar()
+				l.templates = append(l.templates, 0)
+				return buffer.String(), true
+			}
+			buffer.WriteByte('$')
+		case '\\':
+			l.readEscape(&buffer)
+		default:
+			l.writeChar(&buffer)
+		}
+	}
+}
+
+// 读取转义序列，当前字符为反斜杠
+func (l *Lexer) readEscape(buffer *strings.Builder) {
+	pos := l.pos()
+	l.readChar()
+	switch l.ch {
+	case '0':
+		buffer.WriteByte('\000')
+	case 'a':
+		buffer.WriteByte('\a')
+	case 'b':
+		buffer.WriteByte('\b')
+	case 'f':
+		buffer.WriteByte('\f')
+	case 'v':
+		buffer.WriteByte('\v')
+	case 'n':
+		buffer.WriteByte('\n')
+	case 't':
+		buffer.WriteByte('\t')
+	case 'r':
+		buffer.WriteByte('\r')
+	case '"', '\\', '$':
+		buffer.WriteRune(l.ch)
+	case 'x':
+		// \xNN 写入一个字节
+		if !isHexDigit(l.peekChar()) {
+			l.errorf(pos, "invalid escape: \\x must be followed by 2 hex digits")
+			return
+		}
+		l.readChar()
+		value := hexValue(l.ch)
+		if !isHexDigit(l.peekChar()) {
+			l.errorf(pos, "invalid escape: \\x must be followed by 2 hex digits")
+			return
+		}
+		l.readChar()
+		buffer.WriteByte(byte(value<<4 | hexValue(l.ch)))
+	case 'u':
+		// \u{1F600} 写入一个码点
+		if l.peekChar() != '{' {
+			l.errorf(pos, "invalid escape: \\u must be followed by {hex digits}")
+			return
+		}
+		l.readChar()
+		var value rune
+		digits := 0
+		for isHexDigit(l.peekChar()) {
 			l.readChar()
-			switch l.ch {
-			case '0':
-				buffer.WriteString(string('\000'))
-			case 'a':
-				buffer.WriteString(string('\a'))
-			case 'b':
-				buffer.WriteString(string('\b'))
-			case 'f':
-				buffer.WriteString(string('\f'))
-			case 'v':
-				buffer.WriteString(string('\v'))
-			case '"':
-				buffer.WriteString(string('"'))
-			case 'n':
-				buffer.WriteString(string('\n'))
-			case 't':
-				buffer.WriteString(string('\t'))
-			case 'r':
-				buffer.WriteString(string('\r'))
-			case '\\':
-				buffer.WriteString(string('\\'))
-			default:
-				buffer.WriteString(string('\\'))
-				buffer.WriteString(string(l.ch))
+			value = value<<4 | hexValue(l.ch)
+			digits++
+			if digits > 6 {
+				break
 			}
-		} else if l.ch == '"' || l.ch == 0 {
+		}
+		if digits == 0 || digits > 6 || l.peekChar() != '}' {
+			l.errorf(pos, "invalid escape: \\u must be followed by {hex digits}")
+			return
+		}
+		l.readChar()
+		if !utf8.ValidRune(value) {
+			l.errorf(pos, "invalid escape: code point %#x out of range", value)
+			return
+		}
+		buffer.WriteRune(value)
+	case 0:
+		// 未结束的字符串由 readString 报告
+		if !l.eof {
+			buffer.WriteByte('\\')
+			l.writeChar(buffer)
+		}
+	default:
+		buffer.WriteByte('\\')
+		l.writeChar(buffer)
+	}
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func hexValue(ch rune) rune {
+	switch {
+	case isDigit(ch):
+		return ch - '0'
+	case 'a' <= ch && ch <= 'f':
+		return ch - 'a' + 10
+	default:
+		return ch - 'A' + 10
+	}
+}
+
+// 读取反引号包围的原始字符串
+func (l *Lexer) readRawString(start token.Position) string {
+	l.readChar()
+	l.startText()
+	for l.ch != '`' {
+		if l.eof {
+			l.errorf(start, "unterminated raw string")
 			break
-		} else {
-			buffer.WriteString(string(l.ch))
 		}
+		l.readChar()
 	}
-	return buffer.String()
+	return l.endText()
+}
+
+// 读取注释，块注释未闭合时返回 ILLEGAL
+func (l *Lexer) readComment() token.Token {
+	pos := l.pos()
+	l.startText()
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && !l.eof {
+			l.readChar()
+		}
+		return token.Token{Type: token.COMMENT, Literal: l.endText(), Pos: pos}
+	}
+
+	// 跳过 /*
+	l.readChar()
+	l.readChar()
+	depth := 1
+	for depth > 0 {
+		switch {
+		case l.eof:
+			l.errorf(pos, "unterminated block comment")
+			return token.Token{Type: token.ILLEGAL, Literal: l.endText(), Pos: pos}
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+		}
+		l.readChar()
+	}
+	return token.Token{Type: token.COMMENT, Literal: l.endText(), Pos: pos}
+}
+
+// 写入当前字符，非法的 UTF-8 字节原样保留
+func (l *Lexer) writeChar(buffer *strings.Builder) {
+	if l.invalid != 0 {
+		buffer.WriteByte(l.invalid)
+		return
+	}
+	buffer.WriteRune(l.ch)
 }
 
 func (l *Lexer) readIdentifier() string {
-	position := l.position
-	for isLetter(l.ch) {
+	l.startText()
+	// 首字符之后可以包含数字
+	for isLetter(l.ch) || isDigit(l.ch) || l.ch >= utf8.RuneSelf && unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	return l.endText()
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// 标识符以 Unicode 字母或下划线开头
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
+// 读取数字字面量，包括 0x、0o、0b 前缀、下划线分隔符与指数，合法性由语法分析器检查
 func (l *Lexer) readNumber() string {
-	position := l.position
-	for isDigit(l.ch) {
+	l.startText()
+	hex := l.ch == '0' && (l.peekChar() == 'x' || l.peekChar() == 'X')
+	for isDigit(l.ch) || 'a' <= l.ch && l.ch <= 'z' || 'A' <= l.ch && l.ch <= 'Z' || l.ch == '_' {
+		exponent := !hex && (l.ch == 'e' || l.ch == 'E')
 		l.readChar()
+		// 十进制指数可以带符号，如 1e+3
+		if exponent && (l.ch == '+' || l.ch == '-') && isDigit(l.peekChar()) {
+			l.readChar()
+		}
 	}
-	return l.input[position:l.position]
-
+	return l.endText()
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
 // 生成token
-func newToken(tokenType token.TokenType, ch byte) token.Token {
-	return token.Token{Type: tokenType, Literal: string(ch)}
+func newToken(tokenType token.TokenType, ch rune, pos token.Position) token.Token {
+	return token.Token{Type: tokenType, Literal: string(ch), Pos: pos}
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -196,11 +487,11 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+func (l *Lexer) peekChar() rune {
+	b := l.peekBytes()
+	if len(b) == 0 {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
-
+	r, _ := utf8.DecodeRune(b)
+	return r
 }
@@ -1,8 +1,13 @@
 package lexer
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"monkey/internal/token"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestNextToken(t *testing.T) {
@@ -13,7 +18,7 @@ func TestNextToken(t *testing.T) {
 		x + y;
 	};
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -29,6 +34,7 @@ func TestNextToken(t *testing.T) {
 	"Hello, World!\n\""
 	[1, 2];
 	{"foo": "bar"}
+	2 ** 3 % 4
 
 	`
 
@@ -123,6 +129,11 @@ func TestNextToken(t *testing.T) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.INT, "2"},
+		{token.POWER, "**"},
+		{token.INT, "3"},
+		{token.PERCENT, "%"},
+		{token.INT, "4"},
 		{token.EOF, ""},
 	}
 
@@ -149,4 +160,337 @@ func TestNextToken(t *testing.T) {
 	}
 
 
-}
\ No newline at end of file
+}
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 10 / 2; // trailing comment
+/* block
+   comment */ x /* inner /* nested */ still comment */ + 1;
+// last`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// leading comment"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing comment"},
+		{token.COMMENT, "/* block\n   comment */"},
+		{token.IDENT, "x"},
+		{token.COMMENT, "/* inner /* nested */ still comment */"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// last"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	l.KeepComments(true)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	// 默认跳过注释
+	l = New(input)
+	for _, tt := range tests {
+		if tt.expectedType == token.COMMENT {
+			continue
+		}
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("wrong token. expected=%q %q, got=%q %q",
+				tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("x /* never /* closed */")
+	if tok := l.NextToken(); tok.Type != token.IDENT {
+		t.Fatalf("expected IDENT, got=%q", tok.Type)
+	}
+	tok := l.NextToken()
+	if tok.Type != token.ILLEGAL {
+		t.Fatalf("expected ILLEGAL, got=%q %q", tok.Type, tok.Literal)
+	}
+	if tok := l.NextToken(); tok.Type != token.EOF {
+		t.Fatalf("expected EOF, got=%q", tok.Type)
+	}
+	if errors := l.Errors(); len(errors) != 1 || errors[0].Error() != "1:3: unterminated block comment" {
+		t.Fatalf("wrong errors. got=%v", errors)
+	}
+}
+
+func TestEmbeddedNUL(t *testing.T) {
+	l := New("a\x00b \"c\x00d\"")
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.ILLEGAL, "\x00"},
+		{token.IDENT, "b"},
+		{token.STRING, "c\x00d"},
+		{token.EOF, ""},
+	}
+	for _, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("wrong token. expected=%q %q, got=%q %q",
+				tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+	if errors := l.Errors(); len(errors) != 0 {
+		t.Fatalf("unexpected errors. got=%v", errors)
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := "let 变量 = x2;\n\"héllo 世界\" _a1 ñ"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedPos     string
+	}{
+		{token.LET, "let", "1:1"},
+		{token.IDENT, "变量", "1:5"},
+		{token.ASSIGN, "=", "1:8"},
+		{token.IDENT, "x2", "1:10"},
+		{token.SEMICOLON, ";", "1:12"},
+		{token.STRING, "héllo 世界", "2:1"},
+		{token.IDENT, "_a1", "2:12"},
+		{token.IDENT, "ñ", "2:16"},
+		{token.EOF, "", "2:17"},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.String() != tt.expectedPos {
+			t.Fatalf("tests[%d] - wrong position. expected=%s, got=%s", i, tt.expectedPos, tok.Pos)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", l.Errors())
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	l := New("x\n  \"a\xffb\"")
+	l.NextToken()
+	tok := l.NextToken()
+	if tok.Type != token.STRING || tok.Literal != "a\xffb" {
+		t.Fatalf("wrong token. got=%q %q", tok.Type, tok.Literal)
+	}
+	errors := l.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got=%d", len(errors))
+	}
+	if errors[0].Error() != "2:5: invalid UTF-8 encoding 0xff" {
+		t.Fatalf("wrong error. got=%q", errors[0].Error())
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	input := "0xFF 0b1_0 1e+3 2e-1 0xE-1 7e x-1"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "0xFF"},
+		{token.INT, "0b1_0"},
+		{token.INT, "1e+3"},
+		{token.INT, "2e-1"},
+		{token.INT, "0xE"},
+		{token.MINUS, "-"},
+		{token.INT, "1"},
+		{token.INT, "7e"},
+		{token.IDENT, "x"},
+		{token.MINUS, "-"},
+		{token.INT, "1"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestTemplateStrings(t *testing.T) {
+	input := "\"a ${x + 1} b ${ {\"k\": \"${y}\"}[\"k\"] } c\" \"$5 \\${no}\""
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.TEMPLATE_HEAD, "a "},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.TEMPLATE_MIDDLE, " b "},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.TEMPLATE_HEAD, ""},
+		{token.IDENT, "y"},
+		{token.TEMPLATE_TAIL, ""},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.TEMPLATE_TAIL, " c"},
+		{token.STRING, "$5 ${no}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", l.Errors())
+	}
+}
+
+func TestStringEscapesAndRawStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"\x41\x62"`, "Ab"},
+		{`"\u{1F600}!"`, "😀!"},
+		{`"\u{4e16}\t\\"`, "世\t\\"},
+		{`"\q"`, `\q`},
+		{"`a\\n${b}\n\"c\"`", "a\\n${b}\n\"c\""},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING || tok.Literal != tt.expected {
+			t.Fatalf("tests[%d] - wrong token. expected=%q, got=%q %q", i, tt.expected, tok.Type, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Fatalf("tests[%d] - unexpected errors: %v", i, l.Errors())
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`x = "abc`, "1:5: unterminated string"},
+		{`"abc\`, "1:1: unterminated string"},
+		{"\n `abc", "2:2: unterminated raw string"},
+		{`"\x4"`, `1:2: invalid escape: \x must be followed by 2 hex digits`},
+		{`"\u41"`, `1:2: invalid escape: \u must be followed by {hex digits}`},
+		{`"\u{}"`, `1:2: invalid escape: \u must be followed by {hex digits}`},
+		{`"\u{110000}"`, "1:2: invalid escape: code point 0x110000 out of range"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+		errors := l.Errors()
+		if len(errors) == 0 || errors[0].Error() != tt.expected {
+			t.Fatalf("tests[%d] - wrong errors. expected=%q, got=%v", i, tt.expected, errors)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	input := "let 变量 = 0x1F + 1e3; // 注释\n\"a ${b} \\u{1F600}\" `raw\n` /* 块 /* 嵌套 */ */ \"\xff\" 'x \"open"
+
+	tests := []struct {
+		name   string
+		reader io.Reader
+	}{
+		{"whole", strings.NewReader(input)},
+		{"one byte", iotest.OneByteReader(strings.NewReader(input))},
+		{"half", iotest.HalfReader(strings.NewReader(input))},
+	}
+
+	for _, tt := range tests {
+		expected := New(input)
+		expected.KeepComments(true)
+		l := NewReader(tt.reader)
+		l.KeepComments(true)
+		for {
+			want, got := expected.NextToken(), l.NextToken()
+			if got != want {
+				t.Fatalf("%s: wrong token. expected=%+v, got=%+v", tt.name, want, got)
+			}
+			if got.Type == token.EOF {
+				break
+			}
+		}
+		if fmt.Sprint(l.Errors()) != fmt.Sprint(expected.Errors()) {
+			t.Fatalf("%s: wrong errors. expected=%v, got=%v", tt.name, expected.Errors(), l.Errors())
+		}
+	}
+}
+
+func TestNewReaderLargeInput(t *testing.T) {
+	const n = 100000
+	r, w := io.Pipe()
+	go func() {
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(w, "let x%d = %d;\n", i, i)
+		}
+		w.Close()
+	}()
+
+	l := NewReader(r)
+	count := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.LET {
+			count++
+		}
+	}
+	if count != n {
+		t.Fatalf("wrong number of let statements. want=%d, got=%d", n, count)
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("x"), iotest.ErrReader(errors.New("boom")))
+	l := NewReader(r)
+	if tok := l.NextToken(); tok.Type != token.IDENT || tok.Literal != "x" {
+		t.Fatalf("expected IDENT x, got=%q %q", tok.Type, tok.Literal)
+	}
+	if tok := l.NextToken(); tok.Type != token.EOF {
+		t.Fatalf("expected EOF, got=%q", tok.Type)
+	}
+	if errors := l.Errors(); len(errors) != 1 || errors[0].Error() != "1:2: read error: boom" {
+		t.Fatalf("wrong errors. got=%v", errors)
+	}
+}
@@ -0,0 +1,322 @@
+// 基于语法树的静态检查，每条规则可以单独启用或禁用
+package lint
+
+import (
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/evaluator"
+	"monkey/internal/resolver"
+	"monkey/internal/token"
+	"sort"
+	"strings"
+)
+
+type Diagnostic struct {
+	Rule    string         `json:"rule"`
+	Pos     token.Position `json:"pos"`
+	End     token.Position `json:"end"`
+	Message string         `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s (%s)", d.Pos, d.Message, d.Rule)
+}
+
+type Rule struct {
+	Name    string
+	Doc     string
+	Default bool // 没有指定规则时是否启用
+	run     func(p *pass)
+}
+
+// 所有规则，按名字排列
+var Rules = []*Rule{
+	{"bool-compare", "comparison with a boolean literal", true, boolCompare},
+	{"builtin-arity", "builtin called with the wrong number of arguments", true, builtinArity},
+	{"call-non-function", "calling a literal that is not a function", true, callNonFunction},
+	{"duplicate-key", "duplicate keys in a hash literal", true, duplicateKey},
+	{"if-value", "value of an if without else is used", true, ifValue},
+	{"self-assign", "let binding a name to itself", true, selfAssign},
+	{"shadow", "declaration shadows a binding in an enclosing scope", false, resolved(resolver.Shadowed)},
+	{"undefined", "use of an undefined name", true, resolved(resolver.Undefined)},
+	{"unreachable", "code after a return statement", true, unreachable},
+	{"unused", "local let or parameter that is never used", true, resolved(resolver.Unused)},
+}
+
+// 按名字查找规则
+func Lookup(name string) (*Rule, bool) {
+	for _, r := range Rules {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// 返回默认规则中去掉 disable、加上 enable 之后的规则集
+func Select(enable, disable []string) ([]*Rule, error) {
+	selected := map[*Rule]bool{}
+	for _, r := range Rules {
+		selected[r] = r.Default
+	}
+	for i, names := range [][]string{enable, disable} {
+		for _, name := range names {
+			r, ok := Lookup(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown rule %q", name)
+			}
+			selected[r] = i == 0
+		}
+	}
+	var rules []*Rule
+	for _, r := range Rules {
+		if selected[r] {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+type pass struct {
+	program  *ast.Program
+	resolved *resolver.Result
+	rule     *Rule
+	diags    []Diagnostic
+}
+
+// 用给定的规则检查程序，结果按位置排列。程序会被 resolver 就地标注
+func Program(program *ast.Program, rules []*Rule) []Diagnostic {
+	p := &pass{program: program, resolved: resolver.Resolve(program, evaluator.BuiltinNames())}
+	for _, r := range rules {
+		p.rule = r
+		r.run(p)
+	}
+	sort.SliceStable(p.diags, func(i, j int) bool {
+		return p.diags[i].Pos.Offset < p.diags[j].Pos.Offset
+	})
+	return p.diags
+}
+
+func (p *pass) report(tok token.Token, format string, args ...interface{}) {
+	p.diags = append(p.diags, Diagnostic{
+		Rule:    p.rule.Name,
+		Pos:     tok.Pos,
+		End:     tok.End,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// 深度优先遍历语法树，stack 为从根到 node 父节点的路径，f 返回 false 时不进入子节点
+func (p *pass) inspect(f func(node ast.Node, stack []ast.Node) bool) {
+	var stack []ast.Node
+	ast.Inspect(p.program, func(node ast.Node) bool {
+		if node == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		if !f(node, stack) {
+			return false
+		}
+		stack = append(stack, node)
+		return true
+	})
+}
+
+// return 之后的语句永远不会执行，只报告第一条
+func unreachable(p *pass) {
+	check := func(stmts []ast.Statement) {
+		for i := 0; i+1 < len(stmts); i++ {
+			if _, ok := stmts[i].(*ast.ReturnStatement); ok {
+				p.report(ast.StatementToken(stmts[i+1]), "unreachable code")
+				return
+			}
+		}
+	}
+	p.inspect(func(node ast.Node, _ []ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			check(node.Statements)
+		case *ast.BlockStatement:
+			check(node.Statements)
+		}
+		return true
+	})
+}
+
+// 没有 else 的 if 在条件为假时得到 null，它的值被使用时多半是遗漏了 else
+func ifValue(p *pass) {
+	p.inspect(func(node ast.Node, stack []ast.Node) bool {
+		expr, ok := node.(*ast.IfExpression)
+		if !ok || expr.Alternative != nil || len(stack) == 0 {
+			return true
+		}
+		switch parent := stack[len(stack)-1].(type) {
+		case *ast.ExpressionStatement, *ast.BlockStatement, *ast.Program:
+			return true
+		case *ast.IfExpression:
+			if parent.Condition != node {
+				return true
+			}
+		case *ast.ForExpression:
+			if parent.Iterable != node {
+				return true
+			}
+		}
+		p.report(expr.Token, "value of if without else is used; it is null when the condition is false")
+		return true
+	})
+}
+
+func boolCompare(p *pass) {
+	p.inspect(func(node ast.Node, _ []ast.Node) bool {
+		expr, ok := node.(*ast.InfixExpression)
+		if !ok || expr.Operator != "==" && expr.Operator != "!=" {
+			return true
+		}
+		for _, operand := range []ast.Expression{expr.Left, expr.Right} {
+			if b, ok := operand.(*ast.Boolean); ok {
+				p.report(expr.Token, "comparison with %t, use the operand directly", b.Value)
+				break
+			}
+		}
+		return true
+	})
+}
+
+func selfAssign(p *pass) {
+	p.inspect(func(node ast.Node, _ []ast.Node) bool {
+		let, ok := node.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			return true
+		}
+		if ident, ok := let.Value.(*ast.Identifier); ok && ident.Value == let.Name.Value {
+			p.report(let.Name.Token, "self-assignment of %s", let.Name.Value)
+		}
+		return true
+	})
+}
+
+func duplicateKey(p *pass) {
+	p.inspect(func(node ast.Node, _ []ast.Node) bool {
+		hash, ok := node.(*ast.HashLiteral)
+		if !ok {
+			return true
+		}
+		seen := map[string]bool{}
+		for _, pair := range hash.Pairs {
+			var key string
+			switch k := pair.Key.(type) {
+			case *ast.StringLiteral:
+				key = fmt.Sprintf("%q", k.Value)
+			case *ast.IntegerLiteral:
+				key = fmt.Sprint(k.Value)
+				if k.Big != nil {
+					key = k.Big.String()
+				}
+			case *ast.Boolean:
+				key = fmt.Sprint(k.Value)
+			default:
+				continue
+			}
+			if seen[key] {
+				p.report(keyToken(pair.Key), "duplicate key %s in hash literal", key)
+			}
+			seen[key] = true
+		}
+		return true
+	})
+}
+
+func keyToken(key ast.Expression) token.Token {
+	switch k := key.(type) {
+	case *ast.StringLiteral:
+		return k.Token
+	case *ast.IntegerLiteral:
+		return k.Token
+	case *ast.Boolean:
+		return k.Token
+	}
+	return token.Token{}
+}
+
+func callNonFunction(p *pass) {
+	p.inspect(func(node ast.Node, _ []ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		switch fn := call.Function.(type) {
+		case *ast.StringLiteral:
+			p.report(call.Token, "cannot call non-function %q", fn.Value)
+		case *ast.IntegerLiteral, *ast.TemplateLiteral, *ast.Boolean, *ast.ArrayLiteral, *ast.HashLiteral:
+			p.report(call.Token, "cannot call non-function %s", fn.String())
+		}
+		return true
+	})
+}
+
+// 只检查没有被用户绑定遮蔽的内置函数
+func builtinArity(p *pass) {
+	p.inspect(func(node ast.Node, _ []ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		ident, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return true
+		}
+		if _, bound := p.resolved.Bindings[ident]; bound {
+			return true
+		}
+		min, max, ok := evaluator.BuiltinArity(ident.Value)
+		n := len(call.Arguments)
+		if !ok || n >= min && (max < 0 || n <= max) {
+			return true
+		}
+		p.report(ident.Token, "%s expects %s, got %d", ident.Value, arguments(min, max), n)
+		return true
+	})
+}
+
+func arguments(min, max int) string {
+	switch {
+	case max < 0:
+		return fmt.Sprintf("at least %d %s", min, plural(min))
+	case min == max:
+		return fmt.Sprintf("%d %s", min, plural(min))
+	default:
+		return fmt.Sprintf("%d to %d arguments", min, max)
+	}
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return "argument"
+	}
+	return "arguments"
+}
+
+// 由 resolver 的诊断得到的规则
+func resolved(kind resolver.Kind) func(p *pass) {
+	return func(p *pass) {
+		for _, d := range p.resolved.Diagnostics {
+			if d.Kind == kind {
+				p.diags = append(p.diags, Diagnostic{Rule: p.rule.Name, Pos: d.Pos, End: d.End, Message: d.Msg})
+			}
+		}
+	}
+}
+
+// 规则列表的说明，供命令行帮助使用
+func Describe() string {
+	var out strings.Builder
+	for _, r := range Rules {
+		state := ""
+		if !r.Default {
+			state = " (disabled by default)"
+		}
+		fmt.Fprintf(&out, "  %-18s %s%s\n", r.Name, r.Doc, state)
+	}
+	return out.String()
+}
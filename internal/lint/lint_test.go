@@ -0,0 +1,95 @@
+package lint
+
+import (
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"reflect"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule     string
+		input    string
+		expected []string
+	}{
+		{"unreachable", "let f = fn() { return 1; puts(2); 3 };", []string{"1:26: unreachable code (unreachable)"}},
+		{"unreachable", "return 1; let x = 2;", []string{"1:11: unreachable code (unreachable)"}},
+		{"unreachable", "let f = fn() { if (true) { return 1 }; 2 };", nil},
+		{"if-value", "let x = if (true) { 1 };", []string{"1:9: value of if without else is used; it is null when the condition is false (if-value)"}},
+		{"if-value", "puts(if (true) { 1 })", []string{"1:6: value of if without else is used; it is null when the condition is false (if-value)"}},
+		{"if-value", "if (true) { 1 }; let x = if (true) { 1 } else { 2 };", nil},
+		{"if-value", "let f = fn() { if (true) { 1 } };", nil},
+		{"bool-compare", "let a = 1; a == true; false != a; a == 1", []string{
+			"1:14: comparison with true, use the operand directly (bool-compare)",
+			"1:29: comparison with false, use the operand directly (bool-compare)",
+		}},
+		{"self-assign", "let x = 1; let f = fn() { let x = x; x };", []string{"1:31: self-assignment of x (self-assign)"}},
+		{"duplicate-key", `{"a": 1, 1: 2, "a": 3, true: 4, 1: 5, "1": 6}`, []string{
+			`1:16: duplicate key "a" in hash literal (duplicate-key)`,
+			"1:33: duplicate key 1 in hash literal (duplicate-key)",
+		}},
+		{"duplicate-key", "{18446744073709551616: 1, 0: 2, 0x10000000000000000: 3}", []string{
+			"1:33: duplicate key 18446744073709551616 in hash literal (duplicate-key)",
+		}},
+		{"call-non-function", `1(); "s"(); [1](); fn() { 1 }();`, []string{
+			"1:2: cannot call non-function 1 (call-non-function)",
+			`1:9: cannot call non-function "s" (call-non-function)`,
+			"1:16: cannot call non-function [1] (call-non-function)",
+		}},
+		{"builtin-arity", "len(); push([], 1, 2); puts(); reduce([], fn(a, b) { a }, 0, 1); zip()", []string{
+			"1:1: len expects 1 argument, got 0 (builtin-arity)",
+			"1:8: push expects 2 arguments, got 3 (builtin-arity)",
+			"1:32: reduce expects 2 to 3 arguments, got 4 (builtin-arity)",
+			"1:66: zip expects at least 1 argument, got 0 (builtin-arity)",
+		}},
+		{"builtin-arity", "let len = fn() { 0 }; len()", nil},
+		{"undefined", "let f = fn() { g() }; let g = fn() { nope };", []string{"1:38: undefined: nope (undefined)"}},
+		{"unused", "let f = fn(a) { let b = 1; 2 };", []string{
+			"1:12: unused parameter a (unused)",
+			"1:21: b declared and not used (unused)",
+		}},
+		{"shadow", "let x = 1; let f = fn(x) { x };", []string{"1:23: x shadows declaration at 1:5 (shadow)"}},
+	}
+
+	for _, tt := range tests {
+		rule, ok := Lookup(tt.rule)
+		if !ok {
+			t.Fatalf("rule %q not found", tt.rule)
+		}
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%q: parser errors: %v", tt.input, p.Errors())
+		}
+		var got []string
+		for _, d := range Program(program, []*Rule{rule}) {
+			got = append(got, d.String())
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: %q wrong diagnostics.\nwant=%q\ngot= %q", tt.rule, tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	names := func(rules []*Rule) map[string]bool {
+		m := map[string]bool{}
+		for _, r := range rules {
+			m[r.Name] = true
+		}
+		return m
+	}
+
+	rules, err := Select(nil, nil)
+	if err != nil || names(rules)["shadow"] || !names(rules)["unused"] || len(rules) != len(Rules)-1 {
+		t.Errorf("wrong default rules. got=%v, err=%v", names(rules), err)
+	}
+	rules, _ = Select([]string{"shadow"}, []string{"unused", "undefined"})
+	if got := names(rules); !got["shadow"] || got["unused"] || got["undefined"] || len(got) != len(Rules)-2 {
+		t.Errorf("wrong selected rules. got=%v", got)
+	}
+	if _, err := Select(nil, []string{"nope"}); err == nil || err.Error() != `unknown rule "nope"` {
+		t.Errorf("expected unknown rule error, got=%v", err)
+	}
+}
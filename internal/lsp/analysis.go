@@ -0,0 +1,93 @@
+package lsp
+
+import (
+	"monkey/internal/ast"
+	"monkey/internal/evaluator"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"sort"
+)
+
+// 文档的作用域解析结果
+type analysis struct {
+	*resolver.Result
+	parser      *parser.Parser
+	identifiers []*ast.Identifier // 所有标识符，按位置排列
+}
+
+func analyze(program *ast.Program, p *parser.Parser) *analysis {
+	a := &analysis{
+		Result: resolver.Resolve(program, evaluator.BuiltinNames()),
+		parser: p,
+	}
+	for _, node := range p.Nodes() {
+		if ident, ok := node.(*ast.Identifier); ok {
+			a.identifiers = append(a.identifiers, ident)
+		}
+	}
+	sort.Slice(a.identifiers, func(i, j int) bool {
+		return a.start(a.identifiers[i]) < a.start(a.identifiers[j])
+	})
+	return a
+}
+
+func (a *analysis) start(node ast.Node) int {
+	span, _ := a.parser.Span(node)
+	return span.Start.Offset
+}
+
+// 声明在这里之后才可见，let 在语句结束之后才可见
+func (a *analysis) visibleFrom(decl resolver.Declaration) int {
+	if decl.Let != nil {
+		span, _ := a.parser.Span(decl.Let)
+		return span.End.Offset
+	}
+	return a.start(decl.Name)
+}
+
+// 返回 ident 所指的声明：之前最后一个可见的声明，没有时（如函数引用之后定义的绑定）取第一个
+func (a *analysis) declaration(ident *ast.Identifier) (resolver.Declaration, *resolver.Binding, bool) {
+	b, ok := a.Bindings[ident]
+	if !ok {
+		return resolver.Declaration{}, nil, false
+	}
+	offset := a.start(ident)
+	decl := b.Decls[0]
+	for _, d := range b.Decls {
+		if d.Name == ident {
+			return d, b, true
+		}
+		if a.visibleFrom(d) <= offset {
+			decl = d
+		}
+	}
+	return decl, b, true
+}
+
+// 返回 offset 处可以使用的绑定，内层的同名绑定遮蔽外层
+func (a *analysis) visibleAt(offset int) []*resolver.Binding {
+	var inner *resolver.Scope
+	for _, s := range a.Scopes {
+		span, ok := a.parser.Span(s.Node)
+		if s.Parent == nil || ok && span.Start.Offset <= offset && offset <= span.End.Offset {
+			inner = s
+		}
+	}
+	seen := map[string]bool{}
+	var result []*resolver.Binding
+	crossed := false
+	for s := inner; s != nil; s = s.Parent {
+		for i := len(s.Bindings) - 1; i >= 0; i-- {
+			b := s.Bindings[i]
+			if seen[b.Name] || !crossed && a.visibleFrom(b.Decls[0]) > offset {
+				continue
+			}
+			seen[b.Name] = true
+			result = append(result, b)
+		}
+		if _, ok := s.Node.(*ast.FunctionLiteral); ok {
+			crossed = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,128 @@
+package lsp
+
+import (
+	"monkey/internal/ast"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"sort"
+	"unicode/utf8"
+)
+
+// 打开的文档及其解析结果，每次修改后整体重新分析
+type document struct {
+	uri     string
+	text    string
+	lines   []int // 每行起始的字节偏移
+	program *ast.Program
+	parser  *parser.Parser
+	*analysis
+}
+
+func newDocument(uri, text string) *document {
+	doc := &document{uri: uri, text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			doc.lines = append(doc.lines, i+1)
+		}
+	}
+	doc.parser = parser.New(lexer.New(text))
+	doc.program = doc.parser.ParseProgram()
+	doc.analysis = analyze(doc.program, doc.parser)
+	return doc
+}
+
+func (d *document) diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, err := range d.parser.DetailedErrors() {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    Range{d.position(err.Pos.Offset), d.position(err.End.Offset)},
+			Severity: severityError,
+			Source:   "monkey",
+			Message:  err.Msg,
+		})
+	}
+	// 语法错误时语法树不完整，作用域诊断没有意义
+	if len(diagnostics) > 0 {
+		return diagnostics
+	}
+	for _, diag := range d.Diagnostics {
+		severity := severityWarning
+		if diag.Kind == resolver.Undefined {
+			severity = severityError
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    Range{d.position(diag.Pos.Offset), d.position(diag.End.Offset)},
+			Severity: severity,
+			Source:   "monkey",
+			Message:  diag.Msg,
+		})
+	}
+	return diagnostics
+}
+
+// 字节偏移转换为 LSP 位置
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+	character := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		character += utf16Len(r)
+	}
+	return Position{Line: line, Character: character}
+}
+
+// LSP 位置转换为字节偏移，超出行尾时取行尾
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	offset := d.lines[pos.Line]
+	for character := 0; offset < len(d.text) && character < pos.Character; {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func (d *document) rangeOf(span parser.Span) Range {
+	return Range{d.position(span.Start.Offset), d.position(span.End.Offset)}
+}
+
+func (d *document) location(span parser.Span) Location {
+	return Location{URI: d.uri, Range: d.rangeOf(span)}
+}
+
+// 返回光标所在的标识符，光标紧跟在标识符之后也算
+func (d *document) identifierAt(pos Position) (*ast.Identifier, bool) {
+	offset := d.offset(pos)
+	for _, ident := range d.identifiers {
+		span := d.span(ident)
+		if span.Start.Offset <= offset && offset <= span.End.Offset {
+			return ident, true
+		}
+	}
+	return nil, false
+}
+
+// 分析只使用解析器记录过范围的节点，所以总能找到
+func (d *document) span(node ast.Node) parser.Span {
+	span, _ := d.parser.Span(node)
+	return span
+}
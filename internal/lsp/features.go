@@ -0,0 +1,256 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/evaluator"
+	"monkey/internal/lexer"
+	"monkey/internal/resolver"
+	"monkey/internal/token"
+	"sort"
+	"strings"
+)
+
+var keywords = []string{"fn", "let", "true", "false", "if", "else", "return", "for", "in"}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ident, err := s.identifierAt(p)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+	decl, _, ok := doc.declaration(ident)
+	if !ok {
+		return nil, nil
+	}
+	return doc.location(doc.span(decl.Name)), nil
+}
+
+func (s *Server) references(params json.RawMessage) (interface{}, error) {
+	var p ReferenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ident, err := s.identifierAt(p.TextDocumentPositionParams)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+	b, ok := doc.Bindings[ident]
+	if !ok {
+		return nil, nil
+	}
+	locations := []Location{}
+	for _, ref := range occurrences(doc, b, p.Context.IncludeDeclaration) {
+		locations = append(locations, doc.location(doc.span(ref)))
+	}
+	return locations, nil
+}
+
+// 返回绑定的所有引用，按位置排列，同一作用域中重复的 let 属于同一个绑定
+func occurrences(doc *document, b *resolver.Binding, declaration bool) []*ast.Identifier {
+	var idents []*ast.Identifier
+	if declaration {
+		for _, decl := range b.Decls {
+			idents = append(idents, decl.Name)
+		}
+	}
+	idents = append(idents, b.Refs...)
+	sort.Slice(idents, func(i, j int) bool {
+		return doc.span(idents[i]).Start.Offset < doc.span(idents[j]).Start.Offset
+	})
+	return idents
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ident, err := s.identifierAt(p)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+	r := doc.rangeOf(doc.span(ident))
+	if decl, b, ok := doc.declaration(ident); ok {
+		return &Hover{Contents: markdown(describe(b, decl), ""), Range: &r}, nil
+	}
+	if builtin, ok := evaluator.BuiltinDoc(ident.Value); ok {
+		signature, text, _ := strings.Cut(builtin, "\n\n")
+		return &Hover{Contents: markdown(signature, text), Range: &r}, nil
+	}
+	return nil, nil
+}
+
+// 代码块形式的签名，后面跟说明
+func markdown(code, text string) MarkupContent {
+	value := "```monkey\n" + code + "\n```"
+	if text != "" {
+		value += "\n" + text
+	}
+	return MarkupContent{Kind: "markdown", Value: value}
+}
+
+func describe(b *resolver.Binding, decl resolver.Declaration) string {
+	switch b.Kind {
+	case resolver.Parameter:
+		return "(parameter) " + b.Name
+	case resolver.LoopVariable:
+		return "(loop variable) " + b.Name
+	}
+	if fn, ok := function(decl); ok {
+		return fmt.Sprintf("let %s = %s", b.Name, signature(fn))
+	}
+	return "let " + b.Name
+}
+
+// 值为函数字面量的 let
+func function(decl resolver.Declaration) (*ast.FunctionLiteral, bool) {
+	if decl.Let == nil || ast.IsNil(decl.Let.Value) {
+		return nil, false
+	}
+	fn, ok := decl.Let.Value.(*ast.FunctionLiteral)
+	return fn, ok
+}
+
+func signature(fn *ast.FunctionLiteral) string {
+	params := make([]string, 0, len(fn.Parameters))
+	for _, param := range fn.Parameters {
+		params = append(params, param.Value)
+	}
+	return "fn(" + strings.Join(params, ", ") + ")"
+}
+
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	for _, b := range doc.visibleAt(doc.offset(p.Position)) {
+		decl := b.Decls[len(b.Decls)-1]
+		kind := completionVariable
+		if _, ok := function(decl); ok {
+			kind = completionFunction
+		}
+		seen[b.Name] = true
+		items = append(items, CompletionItem{Label: b.Name, Kind: kind, Detail: describe(b, decl)})
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		if seen[name] {
+			continue
+		}
+		builtin, _ := evaluator.BuiltinDoc(name)
+		signature, text, _ := strings.Cut(builtin, "\n\n")
+		doc := MarkupContent{Kind: "markdown", Value: text}
+		items = append(items, CompletionItem{Label: name, Kind: completionFunction, Detail: signature, Documentation: &doc})
+	}
+	for _, keyword := range keywords {
+		items = append(items, CompletionItem{Label: keyword, Kind: completionKeyword})
+	}
+	return items, nil
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p DocumentSymbolParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	symbols := doc.symbolsIn(doc.program.Statements)
+	if symbols == nil {
+		symbols = []DocumentSymbol{}
+	}
+	return symbols, nil
+}
+
+// 收集语句中的 let 绑定，函数的子符号是函数体中的绑定
+func (d *document) symbolsIn(stmts []ast.Statement) []DocumentSymbol {
+	var symbols []DocumentSymbol
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if stmt.Name == nil {
+				continue
+			}
+			sym := DocumentSymbol{
+				Name:           stmt.Name.Value,
+				Kind:           symbolVariable,
+				Range:          d.rangeOf(d.span(stmt)),
+				SelectionRange: d.rangeOf(d.span(stmt.Name)),
+			}
+			if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && fn != nil {
+				sym.Kind = symbolFunction
+				sym.Detail = signature(fn)
+				if fn.Body != nil {
+					sym.Children = d.symbolsIn(fn.Body.Statements)
+				}
+			}
+			symbols = append(symbols, sym)
+		case *ast.ExpressionStatement:
+			// if 与 for 代码块中的绑定归入所在的层级
+			switch expr := stmt.Expression.(type) {
+			case *ast.IfExpression:
+				for _, block := range []*ast.BlockStatement{expr.Consequence, expr.Alternative} {
+					if block != nil {
+						symbols = append(symbols, d.symbolsIn(block.Statements)...)
+					}
+				}
+			case *ast.ForExpression:
+				if expr.Body != nil {
+					symbols = append(symbols, d.symbolsIn(expr.Body.Statements)...)
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+func (s *Server) rename(params json.RawMessage) (interface{}, error) {
+	var p RenameParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ident, err := s.identifierAt(p.TextDocumentPositionParams)
+	if err != nil {
+		return nil, err
+	}
+	if ident == nil {
+		return nil, &ResponseError{codeRequestFailed, "no identifier at position"}
+	}
+	b, ok := doc.Bindings[ident]
+	if !ok {
+		if _, builtin := evaluator.BuiltinDoc(ident.Value); builtin {
+			return nil, &ResponseError{codeRequestFailed, fmt.Sprintf("cannot rename builtin %s", ident.Value)}
+		}
+		return nil, &ResponseError{codeRequestFailed, fmt.Sprintf("%s is not defined", ident.Value)}
+	}
+	if !isIdentifier(p.NewName) {
+		return nil, &ResponseError{codeInvalidParams, fmt.Sprintf("%q is not a valid identifier", p.NewName)}
+	}
+
+	edits := []TextEdit{}
+	for _, ref := range occurrences(doc, b, true) {
+		edits = append(edits, TextEdit{Range: doc.rangeOf(doc.span(ref)), NewText: p.NewName})
+	}
+	return WorkspaceEdit{Changes: map[string][]TextEdit{doc.uri: edits}}, nil
+}
+
+// 名字恰好是一个标识符词法单元，且不是关键字
+func isIdentifier(name string) bool {
+	l := lexer.New(name)
+	tok := l.NextToken()
+	return tok.Type == token.IDENT && tok.Literal == name && l.NextToken().Type == token.EOF
+}
@@ -0,0 +1,195 @@
+// 通过标准输入输出提供 Monkey 语言的 Language Server Protocol 服务
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/internal/ast"
+	"monkey/internal/transport"
+)
+
+// 客户端没有先发送 shutdown 就发送 exit 时 Run 返回的错误
+var ErrExitWithoutShutdown = errors.New("exit without shutdown")
+
+type Server struct {
+	conn        *transport.Conn
+	docs        map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{conn: transport.NewConn(r, w), docs: map[string]*document{}}
+}
+
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var requests = map[string]handler{
+	"initialize":                  (*Server).initialize,
+	"shutdown":                    (*Server).handleShutdown,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/references":     (*Server).references,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/completion":     (*Server).completion,
+	"textDocument/documentSymbol": (*Server).documentSymbol,
+	"textDocument/rename":         (*Server).rename,
+}
+
+var notifications = map[string]func(s *Server, params json.RawMessage) error{
+	"initialized":            func(*Server, json.RawMessage) error { return nil },
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+// 处理消息直到收到 exit 或输入结束
+func (s *Server) Run() error {
+	for {
+		body, err := s.conn.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.reply(json.RawMessage("null"), nil, &ResponseError{codeParseError, err.Error()})
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+		if msg.ID == nil {
+			s.notify(msg)
+		} else {
+			result, err := s.request(msg)
+			s.reply(*msg.ID, result, err)
+		}
+	}
+}
+
+func (s *Server) request(msg message) (interface{}, error) {
+	h, ok := requests[msg.Method]
+	switch {
+	case !ok:
+		return nil, &ResponseError{codeMethodNotFound, fmt.Sprintf("method not found: %s", msg.Method)}
+	case !s.initialized && msg.Method != "initialize":
+		return nil, &ResponseError{codeServerNotInitialized, "server not initialized"}
+	case s.shutdown:
+		return nil, &ResponseError{codeInvalidRequest, "server is shutting down"}
+	}
+	return h(s, msg.Params)
+}
+
+// 通知没有响应，出错时只能忽略
+func (s *Server) notify(msg message) {
+	if h, ok := notifications[msg.Method]; ok && s.initialized {
+		h(s, msg.Params)
+	}
+}
+
+func (s *Server) reply(id json.RawMessage, result interface{}, err error) error {
+	if err == nil {
+		return s.conn.Write(response{JSONRPC: "2.0", ID: id, Result: result})
+	}
+	respErr, ok := err.(*ResponseError)
+	if !ok {
+		respErr = &ResponseError{codeInternalError, err.Error()}
+	}
+	return s.conn.Write(errorResponse{JSONRPC: "2.0", ID: id, Error: respErr})
+}
+
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &ResponseError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	s.initialized = true
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":       1, // 全量同步
+			"definitionProvider":     true,
+			"referencesProvider":     true,
+			"hoverProvider":          true,
+			"completionProvider":     map[string]interface{}{},
+			"documentSymbolProvider": true,
+			"renameProvider":         true,
+		},
+		"serverInfo": map[string]string{"name": "monkey"},
+	}, nil
+}
+
+func (s *Server) handleShutdown(json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p DidOpenTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return err
+	}
+	return s.update(p.TextDocument.URI, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(params json.RawMessage) error {
+	var p DidChangeTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil
+	}
+	return s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p DidCloseTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	return s.publish(p.TextDocument.URI, []Diagnostic{})
+}
+
+// 重新分析文档并发布诊断
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	return s.publish(uri, doc.diagnostics())
+}
+
+func (s *Server) publish(uri string, diagnostics []Diagnostic) error {
+	return s.conn.Write(notification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics},
+	})
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &ResponseError{codeRequestFailed, fmt.Sprintf("unknown document: %s", uri)}
+	}
+	return doc, nil
+}
+
+// 解码带位置的请求参数，返回文档与光标处的标识符
+func (s *Server) identifierAt(params TextDocumentPositionParams) (*document, *ast.Identifier, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+	ident, _ := doc.identifierAt(params.Position)
+	return doc, ident, nil
+}
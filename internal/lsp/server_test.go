@@ -0,0 +1,399 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"monkey/internal/transport"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///test.mk"
+
+const source = `let add = fn(a, b) {
+	a + b
+};
+let x = add(1, 2);
+for (k in [x]) { puts(k) }
+"😀"; let 变量 = 1; 变量
+`
+
+type request struct {
+	method string
+	params interface{}
+}
+
+// 依次发送请求（id 从 1 开始），返回服务端写出的全部消息
+func session(t *testing.T, initialize bool, requests ...request) []map[string]json.RawMessage {
+	t.Helper()
+	var in, out bytes.Buffer
+	client := transport.NewConn(nil, &in)
+	if initialize {
+		client.Write(map[string]interface{}{"jsonrpc": "2.0", "id": 0, "method": "initialize", "params": map[string]interface{}{}})
+		client.Write(map[string]interface{}{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{URI: uri, LanguageID: "monkey", Version: 1, Text: source},
+		}})
+	}
+	for i, req := range requests {
+		msg := map[string]interface{}{"jsonrpc": "2.0", "method": req.method, "params": req.params}
+		if !strings.HasPrefix(req.method, "textDocument/did") && req.method != "exit" {
+			msg["id"] = i + 1
+		}
+		client.Write(msg)
+	}
+	if err := NewServer(&in, &out).Run(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var messages []map[string]json.RawMessage
+	server := transport.NewConn(&out, nil)
+	for {
+		body, err := server.Read()
+		if err != nil {
+			break
+		}
+		var msg map[string]json.RawMessage
+		json.Unmarshal(body, &msg)
+		messages = append(messages, msg)
+	}
+	if initialize {
+		// 去掉 initialize 的响应与打开文档时的诊断
+		messages = messages[2:]
+	}
+	return messages
+}
+
+// 返回 id 对应请求的 result，出错时测试失败
+func result(t *testing.T, messages []map[string]json.RawMessage, id int, v interface{}) {
+	t.Helper()
+	for _, msg := range messages {
+		if string(msg["id"]) == fmt.Sprint(id) {
+			if msg["error"] != nil {
+				t.Fatalf("request %d failed: %s", id, msg["error"])
+			}
+			if err := json.Unmarshal(msg["result"], v); err != nil {
+				t.Fatalf("request %d: %s", id, err)
+			}
+			return
+		}
+	}
+	t.Fatalf("no response for request %d", id)
+}
+
+func responseError(t *testing.T, messages []map[string]json.RawMessage, id int) ResponseError {
+	t.Helper()
+	for _, msg := range messages {
+		if string(msg["id"]) == fmt.Sprint(id) {
+			var err ResponseError
+			if json.Unmarshal(msg["error"], &err) != nil {
+				t.Fatalf("request %d did not fail: %s", id, msg["result"])
+			}
+			return err
+		}
+	}
+	t.Fatalf("no response for request %d", id)
+	return ResponseError{}
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{line, character}}
+}
+
+func span(l1, c1, l2, c2 int) Range {
+	return Range{Position{l1, c1}, Position{l2, c2}}
+}
+
+func TestLifecycle(t *testing.T) {
+	messages := session(t, false,
+		request{"textDocument/hover", at(0, 0)},
+		request{"initialize", map[string]interface{}{}},
+		request{"nope", nil},
+		request{"shutdown", nil},
+		request{"textDocument/hover", at(0, 0)},
+		request{"exit", nil},
+	)
+	if err := responseError(t, messages, 1); err.Code != codeServerNotInitialized {
+		t.Errorf("expected not initialized error, got=%+v", err)
+	}
+	var init struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	result(t, messages, 2, &init)
+	if init.Capabilities["textDocumentSync"] != 1.0 || init.Capabilities["renameProvider"] != true {
+		t.Errorf("wrong capabilities. got=%v", init.Capabilities)
+	}
+	if err := responseError(t, messages, 3); err.Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got=%+v", err)
+	}
+	if err := responseError(t, messages, 5); err.Code != codeInvalidRequest {
+		t.Errorf("expected invalid request after shutdown, got=%+v", err)
+	}
+
+	var in, out bytes.Buffer
+	transport.NewConn(nil, &in).Write(map[string]string{"jsonrpc": "2.0", "method": "exit"})
+	if err := NewServer(&in, &out).Run(); err != ErrExitWithoutShutdown {
+		t.Errorf("expected ErrExitWithoutShutdown, got=%v", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	change := func(text string) request {
+		return request{"textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument:   TextDocumentIdentifier{URI: uri},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: text}},
+		}}
+	}
+	messages := session(t, true,
+		change("let x = 1;\n\"😀\" + ;"),
+		change("let x = 1;"),
+		change("let f = fn(a) { nope };"),
+		request{"textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}}},
+	)
+
+	expected := [][]Diagnostic{
+		{{Range: span(1, 7, 1, 8), Severity: severityError, Source: "monkey", Message: "no prefix parse function for ; found"}},
+		{},
+		{
+			{Range: span(0, 11, 0, 12), Severity: severityWarning, Source: "monkey", Message: "unused parameter a"},
+			{Range: span(0, 16, 0, 20), Severity: severityError, Source: "monkey", Message: "undefined: nope"},
+		},
+		{},
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("wrong number of messages. want=%d, got=%d", len(expected), len(messages))
+	}
+	for i, msg := range messages {
+		var params PublishDiagnosticsParams
+		json.Unmarshal(msg["params"], &params)
+		if string(msg["method"]) != `"textDocument/publishDiagnostics"` || params.URI != uri {
+			t.Fatalf("messages[%d] is not a diagnostic notification: %v", i, msg)
+		}
+		if fmt.Sprint(params.Diagnostics) != fmt.Sprint(expected[i]) {
+			t.Errorf("messages[%d] wrong diagnostics. want=%+v, got=%+v", i, expected[i], params.Diagnostics)
+		}
+	}
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	refs := func(line, character int, declaration bool) request {
+		p := ReferenceParams{TextDocumentPositionParams: at(line, character)}
+		p.Context.IncludeDeclaration = declaration
+		return request{"textDocument/references", p}
+	}
+	messages := session(t, true,
+		request{"textDocument/definition", at(1, 1)},
+		request{"textDocument/definition", at(3, 9)},
+		request{"textDocument/definition", at(4, 22)},
+		request{"textDocument/definition", at(4, 18)},
+		refs(0, 5, true),
+		refs(5, 18, false),
+	)
+
+	tests := []struct {
+		id       int
+		expected interface{}
+	}{
+		{1, &Location{uri, span(0, 13, 0, 14)}},
+		{2, &Location{uri, span(0, 4, 0, 7)}},
+		{3, &Location{uri, span(4, 5, 4, 6)}},
+		{4, (*Location)(nil)},
+		{5, []Location{{uri, span(0, 4, 0, 7)}, {uri, span(3, 8, 3, 11)}}},
+		{6, []Location{{uri, span(5, 18, 5, 20)}}},
+	}
+	for _, tt := range tests {
+		switch expected := tt.expected.(type) {
+		case *Location:
+			var got *Location
+			result(t, messages, tt.id, &got)
+			if fmt.Sprint(got) != fmt.Sprint(expected) {
+				t.Errorf("request %d: wrong location. want=%v, got=%v", tt.id, expected, got)
+			}
+		case []Location:
+			var got []Location
+			result(t, messages, tt.id, &got)
+			if fmt.Sprint(got) != fmt.Sprint(expected) {
+				t.Errorf("request %d: wrong locations. want=%v, got=%v", tt.id, expected, got)
+			}
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	messages := session(t, true,
+		request{"textDocument/hover", at(4, 18)},
+		request{"textDocument/hover", at(3, 9)},
+		request{"textDocument/hover", at(1, 5)},
+		request{"textDocument/hover", at(0, 0)},
+	)
+
+	tests := []string{
+		"```monkey\nputs(args...)\n```\nPrints each argument on its own line and returns null.",
+		"```monkey\nlet add = fn(a, b)\n```",
+		"```monkey\n(parameter) b\n```",
+		"",
+	}
+	for i, expected := range tests {
+		var hover *Hover
+		result(t, messages, i+1, &hover)
+		got := ""
+		if hover != nil {
+			got = hover.Contents.Value
+		}
+		if got != expected {
+			t.Errorf("request %d: wrong hover. want=%q, got=%q", i+1, expected, got)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	messages := session(t, true,
+		request{"textDocument/completion", at(1, 1)},
+		request{"textDocument/completion", at(3, 0)},
+	)
+
+	labels := func(id int) map[string]int {
+		var items []CompletionItem
+		result(t, messages, id, &items)
+		kinds := map[string]int{}
+		for _, item := range items {
+			kinds[item.Label] = item.Kind
+		}
+		return kinds
+	}
+	inside := labels(1)
+	for label, kind := range map[string]int{
+		"a": completionVariable, "b": completionVariable, "add": completionFunction,
+		"x": completionVariable, "len": completionFunction, "let": completionKeyword,
+	} {
+		if inside[label] != kind {
+			t.Errorf("inside function: %q wrong kind. want=%d, got=%d", label, kind, inside[label])
+		}
+	}
+	top := labels(2)
+	for _, label := range []string{"a", "k", "x"} {
+		if _, ok := top[label]; ok {
+			t.Errorf("top level: %q should not be visible", label)
+		}
+	}
+	if top["add"] != completionFunction {
+		t.Errorf("top level: add should be visible")
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	messages := session(t, true, request{"textDocument/documentSymbol", DocumentSymbolParams{TextDocumentIdentifier{uri}}})
+
+	var symbols []DocumentSymbol
+	result(t, messages, 1, &symbols)
+	if len(symbols) != 3 {
+		t.Fatalf("wrong number of symbols. got=%+v", symbols)
+	}
+	add := symbols[0]
+	if add.Name != "add" || add.Kind != symbolFunction || add.Detail != "fn(a, b)" ||
+		add.Range != span(0, 0, 2, 2) || add.SelectionRange != span(0, 4, 0, 7) {
+		t.Errorf("wrong symbol. got=%+v", add)
+	}
+	if symbols[1].Name != "x" || symbols[1].Kind != symbolVariable || symbols[2].Name != "变量" {
+		t.Errorf("wrong symbols. got=%+v", symbols[1:])
+	}
+}
+
+func TestRename(t *testing.T) {
+	rename := func(line, character int, name string) request {
+		return request{"textDocument/rename", RenameParams{at(line, character), name}}
+	}
+	messages := session(t, true,
+		rename(1, 1, "first"),
+		rename(4, 18, "print"),
+		rename(0, 5, "let"),
+		rename(0, 5, "a b"),
+	)
+
+	var edit WorkspaceEdit
+	result(t, messages, 1, &edit)
+	expected := []TextEdit{{span(0, 13, 0, 14), "first"}, {span(1, 1, 1, 2), "first"}}
+	if fmt.Sprint(edit.Changes[uri]) != fmt.Sprint(expected) {
+		t.Errorf("wrong edits. want=%v, got=%v", expected, edit.Changes)
+	}
+	if err := responseError(t, messages, 2); err.Message != "cannot rename builtin puts" {
+		t.Errorf("wrong error. got=%+v", err)
+	}
+	for _, id := range []int{3, 4} {
+		if err := responseError(t, messages, id); err.Code != codeInvalidParams {
+			t.Errorf("request %d: expected invalid params, got=%+v", id, err)
+		}
+	}
+}
+
+// 同一函数内按执行顺序解析，跨过函数边界时可以引用之后的声明
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		use      int // 第几个同名标识符（从 0 开始）
+		name     string
+		expected int // 解析到的声明是第几个同名标识符，-1 表示未定义
+	}{
+		{"let x = 1; let x = x + 1; x", 2, "x", 0},
+		{"let x = 1; let x = x + 1; x", 3, "x", 1},
+		{"let f = fn() { g() }; let g = fn() { f() };", 0, "g", 1},
+		{"let f = fn() { f() };", 1, "f", 0},
+		{"y; let y = 1;", 0, "y", -1},
+		{"let x = 1; if (true) { let x = 2; } x", 2, "x", 1},
+		{"let x = 1; for (x in []) { x } x", 2, "x", 1},
+		{"let x = 1; for (x in []) { x } x", 3, "x", 0},
+		{"fn(a) { let a = a; a }", 2, "a", 0},
+		{"fn(a) { let a = a; a }", 3, "a", 1},
+		{"let 變量 = 1; \"${變量}\"", 1, "變量", 0},
+	}
+
+	for _, tt := range tests {
+		doc := newDocument(uri, tt.input)
+		var idents []int
+		for i, ident := range doc.identifiers {
+			if ident.Value == tt.name {
+				idents = append(idents, i)
+			}
+		}
+		use := doc.identifiers[idents[tt.use]]
+		decl, _, ok := doc.declaration(use)
+		got := -1
+		if ok {
+			for i, j := range idents {
+				if doc.identifiers[j] == decl.Name {
+					got = i
+				}
+			}
+		}
+		if got != tt.expected {
+			t.Errorf("%q: %s #%d wrong declaration. want=%d, got=%d", tt.input, tt.name, tt.use, tt.expected, got)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	doc := newDocument(uri, "a😀b\r\n\nxyz")
+	tests := []struct {
+		offset int
+		pos    Position
+	}{
+		{0, Position{0, 0}},
+		{1, Position{0, 1}},
+		{5, Position{0, 3}},
+		{6, Position{0, 4}},
+		{8, Position{1, 0}},
+		{9, Position{2, 0}},
+		{12, Position{2, 3}},
+	}
+	for _, tt := range tests {
+		if got := doc.position(tt.offset); got != tt.pos {
+			t.Errorf("position(%d) wrong. want=%v, got=%v", tt.offset, tt.pos, got)
+		}
+		if got := doc.offset(tt.pos); got != tt.offset {
+			t.Errorf("offset(%v) wrong. want=%d, got=%d", tt.pos, tt.offset, got)
+		}
+	}
+	if got := doc.offset(Position{1, 10}); got != 8 {
+		t.Errorf("offset past end of line should clamp. got=%d", got)
+	}
+}
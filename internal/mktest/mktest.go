@@ -0,0 +1,237 @@
+// 运行 Monkey 单元测试：*_test.mk 文件中每个顶层 test_* 函数是一个测试
+package mktest
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"monkey/internal/ast"
+	"monkey/internal/coverage"
+	"monkey/internal/evaluator"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Status int
+
+const (
+	Pass  Status = iota
+	Fail         // 断言失败
+	Error        // 运行时错误
+)
+
+func (s Status) String() string {
+	return [...]string{"PASS", "FAIL", "ERROR"}[s]
+}
+
+type Result struct {
+	Name     string
+	Status   Status
+	Line     int    // 失败时出错语句所在的行
+	Message  string // 失败或错误的信息
+	Output   string // 测试中 puts 的输出
+	Duration time.Duration
+}
+
+type FileResult struct {
+	Path     string
+	Err      error  // 文件无法解析或顶层代码求值出错
+	Output   string // 加载文件时顶层代码的输出
+	Tests    []*Result
+	Duration time.Duration
+}
+
+// 文件中是否有失败的测试
+func (f *FileResult) Failed() bool {
+	if f.Err != nil {
+		return true
+	}
+	for _, t := range f.Tests {
+		if t.Status != Pass {
+			return true
+		}
+	}
+	return false
+}
+
+// 找出 path 下所有 *_test.mk 文件，path 为文件时直接返回
+func Discover(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), "_test.mk") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+type Options struct {
+	Filter   *regexp.Regexp    // 只运行名字匹配的测试，nil 表示全部
+	Coverage *coverage.Profile // 不为 nil 时登记文件并统计覆盖率
+}
+
+// 运行文件中的测试。为了让测试互不影响，每个测试前都重新求值一遍顶层代码，
+// 运行 n 个测试时顶层代码共执行 n+1 次，见 monkey test 的帮助
+func RunFile(path string, opts Options) *FileResult {
+	start := time.Now()
+	result := &FileResult{Path: path}
+	defer func() { result.Duration = time.Since(start) }()
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		result.Err = fmt.Errorf("%s", strings.Join(p.Errors(), "\n"))
+		return result
+	}
+	resolver.Resolve(program, nil)
+
+	var extra evaluator.Tracer
+	if opts.Coverage != nil {
+		opts.Coverage.Add(path, string(src), program, p)
+		extra = opts.Coverage
+	}
+
+	var output bytes.Buffer
+	if _, err := load(program, &output, extra); err != nil {
+		result.Err = err
+		result.Output = output.String()
+		return result
+	}
+	result.Output = output.String()
+
+	for _, name := range testNames(program) {
+		if opts.Filter != nil && !opts.Filter.MatchString(name) {
+			continue
+		}
+		result.Tests = append(result.Tests, runTest(program, name, extra))
+	}
+	return result
+}
+
+// 求值顶层代码，返回得到的环境。tracer 可以为 nil
+func load(program *ast.Program, output *bytes.Buffer, tracer evaluator.Tracer) (*object.Environment, error) {
+	ctx := &evaluator.Context{Tracer: tracer, Output: output}
+	env := object.NewEnvironment()
+	if err, ok := ctx.Eval(program, env).(*object.Error); ok {
+		return nil, fmt.Errorf("%s", err.Message)
+	}
+	return env, nil
+}
+
+// 顶层 let 绑定的 test_* 函数，按定义顺序，重复定义的只算一次
+func testNames(program *ast.Program) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || !strings.HasPrefix(let.Name.Value, "test_") || seen[let.Name.Value] {
+			continue
+		}
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+			seen[let.Name.Value] = true
+			names = append(names, let.Name.Value)
+		}
+	}
+	return names
+}
+
+// 在重新求值顶层代码得到的新环境中运行一个测试。顶层代码的代价随测试个数成倍增加，
+// 这是有意的隔离：前一个测试无法通过顶层绑定影响后一个
+func runTest(program *ast.Program, name string, extra evaluator.Tracer) *Result {
+	result := &Result{Name: name}
+	// 重新求值时的输出与覆盖率已在加载文件时记录过，只跟踪测试函数本身
+	env, err := load(program, &bytes.Buffer{}, nil)
+	if err != nil {
+		result.Status, result.Message = Error, err.Error()
+		return result
+	}
+	fn, _ := env.Get(name)
+	test, ok := fn.(*object.Function)
+	if !ok {
+		result.Status, result.Message = Error, fmt.Sprintf("%s is not a function", name)
+		return result
+	}
+	if len(test.Parameters) != 0 {
+		result.Status, result.Message = Error, fmt.Sprintf("%s must not take parameters", name)
+		return result
+	}
+
+	var output bytes.Buffer
+	lines := &lineTracer{}
+	ctx := &evaluator.Context{Tracer: lines, Output: &output}
+	if extra != nil {
+		ctx.Tracer = evaluator.Tracers(lines, extra)
+	}
+	start := time.Now()
+	value := ctx.ApplyFunction(test)
+	result.Duration = time.Since(start)
+
+	result.Output = output.String()
+	if err, ok := value.(*object.Error); ok {
+		result.Status, result.Message, result.Line = Error, err.Message, lines.line()
+		if evaluator.IsAssertion(err) {
+			result.Status = Fail
+		}
+	}
+	return result
+}
+
+// 记录每个调用帧当前语句所在的行，出错时取最内层的
+type lineTracer struct {
+	frames []int
+	failed []int // 第一次返回错误时的调用栈
+}
+
+func (t *lineTracer) Statement(stmt ast.Statement, env *object.Environment) {
+	// 还在执行语句说明之前的错误已被处理，如 assert_error
+	t.failed = nil
+	t.frames[len(t.frames)-1] = ast.StatementToken(stmt).Pos.Line
+}
+
+func (t *lineTracer) Call(fn *object.Function, args []object.Object, env *object.Environment) {
+	t.frames = append(t.frames, fn.Pos.Line)
+}
+
+func (t *lineTracer) Return(fn *object.Function, result object.Object) {
+	// 错误从最内层向外传播，保留第一次出错时的位置
+	if _, ok := result.(*object.Error); ok && t.failed == nil {
+		t.failed = append([]int(nil), t.frames...)
+	}
+	t.frames = t.frames[:len(t.frames)-1]
+}
+
+func (t *lineTracer) line() int {
+	frames := t.failed
+	if frames == nil {
+		frames = t.frames
+	}
+	if len(frames) == 0 {
+		return 0
+	}
+	return frames[len(frames)-1]
+}
@@ -0,0 +1,243 @@
+package mktest
+
+import (
+	"bytes"
+	"errors"
+	"monkey/internal/coverage"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const source = `let square = fn(x) { x * x };
+puts("loading");
+
+let test_pass = fn() {
+	puts("hello");
+	assert_eq(square(3), 9);
+};
+
+let check = fn(x) {
+	assert_eq(x, 1)
+};
+
+let test_fail = fn() {
+	assert_error(fn() { 1 + true });
+	check(2);
+};
+
+let test_error = fn() { missing };
+let test_args = fn(x) { x };
+let not_a_test = fn() { assert_true(false) };
+`
+
+func writeFile(t *testing.T, dir, name, src string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunFile(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "math_test.mk", source)
+	result := RunFile(path, Options{})
+	if result.Err != nil {
+		t.Fatalf("unexpected error: %s", result.Err)
+	}
+	if result.Output != "loading\n" {
+		t.Errorf("wrong file output. got=%q", result.Output)
+	}
+
+	tests := []struct {
+		name    string
+		status  Status
+		line    int
+		message string
+		output  string
+	}{
+		{"test_pass", Pass, 0, "", "hello\n"},
+		{"test_fail", Fail, 10, "assert_eq failed\ngot:  2\nwant: 1\n      ^", ""},
+		{"test_error", Error, 18, "identifier not found: missing", ""},
+		{"test_args", Error, 0, "test_args must not take parameters", ""},
+	}
+	if len(result.Tests) != len(tests) {
+		t.Fatalf("wrong number of tests. want=%d, got=%d", len(tests), len(result.Tests))
+	}
+	for i, tt := range tests {
+		got := result.Tests[i]
+		if got.Name != tt.name || got.Status != tt.status || got.Line != tt.line || got.Message != tt.message || got.Output != tt.output {
+			t.Errorf("test %d: want=%+v, got=%+v", i, tt, *got)
+		}
+	}
+	if !result.Failed() {
+		t.Errorf("file should have failed")
+	}
+
+	filtered := RunFile(path, Options{Filter: regexp.MustCompile("pass")})
+	if len(filtered.Tests) != 1 || filtered.Failed() {
+		t.Errorf("-run pass: wrong result. got=%+v", filtered.Tests)
+	}
+
+	broken := RunFile(writeFile(t, t.TempDir(), "broken_test.mk", "let = 1;"), Options{})
+	if broken.Err == nil || !broken.Failed() {
+		t.Errorf("parse error not reported")
+	}
+	broken = RunFile(writeFile(t, t.TempDir(), "broken_test.mk", "puts(1); 1 + true;"), Options{})
+	if broken.Err == nil || broken.Err.Error() != "type mismatch: INTEGER + BOOLEAN" || broken.Output != "1\n" {
+		t.Errorf("top-level error not reported. got=%v, output=%q", broken.Err, broken.Output)
+	}
+}
+
+// 每个测试都会重新求值顶层代码，但顶层语句只应计数一次
+func TestRunFileCoverage(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "math_test.mk", source)
+	profile := coverage.New()
+	RunFile(path, Options{Coverage: profile})
+
+	lines := profile.Files[0].Lines()
+	if got := lines[2]; got != 1 {
+		t.Errorf("top-level statement counted %d times, want 1", got)
+	}
+	// square 在 test_pass 中调用一次
+	if got := lines[1]; got != 1 {
+		t.Errorf("line 1 counted %d times, want 1", got)
+	}
+	if got := lines[6]; got != 1 {
+		t.Errorf("statement in test_pass counted %d times, want 1", got)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b_test.mk", "a_test.mk", "sub/c_test.mk", "main.mk", "test.mk"} {
+		writeFile(t, dir, name, "")
+	}
+	files, err := Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		rel, _ := filepath.Rel(dir, f)
+		names = append(names, filepath.ToSlash(rel))
+	}
+	if strings.Join(names, " ") != "a_test.mk b_test.mk sub/c_test.mk" {
+		t.Errorf("wrong files. got=%v", names)
+	}
+
+	if files, _ := Discover(filepath.Join(dir, "main.mk")); len(files) != 1 {
+		t.Errorf("file argument should be returned as is. got=%v", files)
+	}
+	if _, err := Discover(filepath.Join(dir, "nope")); err == nil {
+		t.Errorf("expected error for missing path")
+	}
+}
+
+var results = []*FileResult{
+	{Path: "a_test.mk", Duration: 2 * time.Millisecond, Tests: []*Result{
+		{Name: "test_ok", Status: Pass, Output: "hi\n", Duration: time.Millisecond},
+		{Name: "test_bad", Status: Fail, Line: 3, Message: "assert_eq failed\ngot:  1\nwant: 2", Duration: time.Millisecond},
+	}},
+	{Path: "b_test.mk", Err: errors.New("identifier not found: x"), Output: "partial\n"},
+	{Path: "c_test.mk"},
+}
+
+func TestWriteText(t *testing.T) {
+	var out bytes.Buffer
+	WriteText(&out, results, false)
+	want := `--- FAIL: test_bad (0.001s)
+    a_test.mk:3: assert_eq failed
+        got:  1
+        want: 2
+FAIL	a_test.mk	0.002s
+--- ERROR: b_test.mk
+    partial
+    identifier not found: x
+FAIL	b_test.mk	0.000s
+?   	c_test.mk	[no tests]
+1 passed, 1 failed, 1 errored in 0.002s
+`
+	if out.String() != want {
+		t.Errorf("wrong report.\nwant=%s\ngot=%s", want, out.String())
+	}
+
+	out.Reset()
+	WriteText(&out, results[:1], true)
+	if !strings.HasPrefix(out.String(), "--- PASS: test_ok (0.001s)\n    hi\n--- FAIL") {
+		t.Errorf("verbose report should list passing tests.\ngot=%s", out.String())
+	}
+}
+
+func TestWriteTAP(t *testing.T) {
+	var out bytes.Buffer
+	WriteTAP(&out, results)
+	want := `TAP version 13
+1..3
+ok 1 - a_test.mk test_ok
+not ok 2 - a_test.mk test_bad
+  ---
+  message: |-
+    assert_eq failed
+    got:  1
+    want: 2
+  severity: fail
+  file: a_test.mk
+  line: 3
+  duration_ms: 1.000
+  ...
+not ok 3 - b_test.mk
+  ---
+  message: |-
+    identifier not found: x
+  severity: error
+  output: |-
+    partial
+  file: b_test.mk
+  duration_ms: 0.000
+  ...
+`
+	if out.String() != want {
+		t.Errorf("wrong report.\nwant=%s\ngot=%s", want, out.String())
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJUnit(&out, results); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" errors="1" time="0.002">
+  <testsuite name="a_test.mk" tests="2" failures="1" errors="0" time="0.002">
+    <testcase name="test_ok" classname="a_test.mk" time="0.001">
+      <system-out><![CDATA[hi
+]]></system-out>
+    </testcase>
+    <testcase name="test_bad" classname="a_test.mk" time="0.001">
+      <failure message="assert_eq failed" type="assertion"><![CDATA[a_test.mk:3: assert_eq failed
+got:  1
+want: 2]]></failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="b_test.mk" tests="1" failures="0" errors="1" time="0.000">
+    <testcase name="b_test.mk" classname="b_test.mk" time="0.000">
+      <error message="identifier not found: x" type="load"><![CDATA[identifier not found: x]]></error>
+    </testcase>
+    <system-out><![CDATA[partial
+]]></system-out>
+  </testsuite>
+  <testsuite name="c_test.mk" tests="0" failures="0" errors="0" time="0.000"></testsuite>
+</testsuites>
+`
+	if out.String() != want {
+		t.Errorf("wrong report.\nwant=%s\ngot=%s", want, out.String())
+	}
+}
@@ -0,0 +1,248 @@
+package mktest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// 统计通过、失败与出错的测试个数，无法加载的文件算一个错误
+func Count(files []*FileResult) (passed, failed, errored int) {
+	for _, f := range files {
+		if f.Err != nil {
+			errored++
+		}
+		for _, t := range f.Tests {
+			switch t.Status {
+			case Pass:
+				passed++
+			case Fail:
+				failed++
+			case Error:
+				errored++
+			}
+		}
+	}
+	return
+}
+
+// 与 go test 类似的文本报告，verbose 时列出通过的测试与它们的输出
+func WriteText(w io.Writer, files []*FileResult, verbose bool) {
+	var total time.Duration
+	for _, f := range files {
+		total += f.Duration
+		if f.Err != nil {
+			fmt.Fprintf(w, "--- ERROR: %s\n", f.Path)
+			writeIndented(w, f.Output)
+			writeIndented(w, f.Err.Error())
+			fmt.Fprintf(w, "FAIL\t%s\t%s\n", f.Path, seconds(f.Duration))
+			continue
+		}
+		if verbose {
+			writeIndented(w, f.Output)
+		}
+		for _, t := range f.Tests {
+			if t.Status == Pass && !verbose {
+				continue
+			}
+			fmt.Fprintf(w, "--- %s: %s (%s)\n", t.Status, t.Name, seconds(t.Duration))
+			writeIndented(w, t.Output)
+			if t.Status != Pass {
+				// 信息的后续行再缩进一层
+				message := strings.ReplaceAll(t.Message, "\n", "\n    ")
+				writeIndented(w, fmt.Sprintf("%s:%d: %s", f.Path, t.Line, message))
+			}
+		}
+		switch {
+		case len(f.Tests) == 0:
+			fmt.Fprintf(w, "?   \t%s\t[no tests]\n", f.Path)
+		case f.Failed():
+			fmt.Fprintf(w, "FAIL\t%s\t%s\n", f.Path, seconds(f.Duration))
+		default:
+			fmt.Fprintf(w, "ok  \t%s\t%s\n", f.Path, seconds(f.Duration))
+		}
+	}
+	passed, failed, errored := Count(files)
+	fmt.Fprintf(w, "%d passed, %d failed, %d errored in %s\n", passed, failed, errored, seconds(total))
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+// 每行缩进四个空格，去掉末尾的换行
+func writeIndented(w io.Writer, s string) {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return
+	}
+	for _, line := range strings.Split(s, "\n") {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+}
+
+// TAP version 13 报告，失败的详细信息写在 YAML 块中
+func WriteTAP(w io.Writer, files []*FileResult) {
+	n := 0
+	for _, f := range files {
+		if f.Err != nil {
+			n++
+		}
+		n += len(f.Tests)
+	}
+	fmt.Fprintln(w, "TAP version 13")
+	fmt.Fprintf(w, "1..%d\n", n)
+
+	i := 0
+	for _, f := range files {
+		if f.Err != nil {
+			i++
+			fmt.Fprintf(w, "not ok %d - %s\n", i, f.Path)
+			writeYAML(w, map[string]string{"severity": "error", "message": f.Err.Error(), "output": f.Output, "file": f.Path}, f.Duration)
+			continue
+		}
+		for _, t := range f.Tests {
+			i++
+			if t.Status == Pass {
+				fmt.Fprintf(w, "ok %d - %s %s\n", i, f.Path, t.Name)
+				continue
+			}
+			fmt.Fprintf(w, "not ok %d - %s %s\n", i, f.Path, t.Name)
+			writeYAML(w, map[string]string{
+				"severity": strings.ToLower(t.Status.String()),
+				"message":  t.Message,
+				"output":   t.Output,
+				"file":     f.Path,
+				"line":     fmt.Sprint(t.Line),
+			}, t.Duration)
+		}
+	}
+}
+
+func writeYAML(w io.Writer, fields map[string]string, d time.Duration) {
+	fmt.Fprintln(w, "  ---")
+	for _, key := range []string{"message", "severity", "output", "file", "line"} {
+		value, ok := fields[key]
+		if !ok || value == "" {
+			continue
+		}
+		if strings.ContainsAny(value, "\n:#\"'") {
+			fmt.Fprintf(w, "  %s: |-\n", key)
+			for _, line := range strings.Split(strings.TrimRight(value, "\n"), "\n") {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+		} else {
+			fmt.Fprintf(w, "  %s: %s\n", key, value)
+		}
+	}
+	fmt.Fprintf(w, "  duration_ms: %.3f\n", float64(d.Microseconds())/1000)
+	fmt.Fprintln(w, "  ...")
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Time      string      `xml:"time,attr"`
+	Cases     []junitCase `xml:"testcase"`
+	SystemOut *junitText  `xml:"system-out,omitempty"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut *junitText    `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// 多行文本放在 CDATA 中以保留换行
+type junitText struct {
+	Text string `xml:",cdata"`
+}
+
+func cdata(s string) *junitText {
+	if s == "" {
+		return nil
+	}
+	return &junitText{s}
+}
+
+// JUnit XML 报告，每个文件是一个 testsuite，无法加载的文件记为一个出错的 testcase
+func WriteJUnit(w io.Writer, files []*FileResult) error {
+	var total time.Duration
+	report := junitSuites{}
+	for _, f := range files {
+		total += f.Duration
+		suite := junitSuite{Name: f.Path, Time: xmlSeconds(f.Duration), SystemOut: cdata(f.Output)}
+		if f.Err != nil {
+			suite.Cases = append(suite.Cases, junitCase{
+				Name:      f.Path,
+				ClassName: f.Path,
+				Time:      xmlSeconds(f.Duration),
+				Error:     &junitProblem{Message: firstLine(f.Err.Error()), Type: "load", Text: f.Err.Error()},
+			})
+			suite.Errors++
+		}
+		for _, t := range f.Tests {
+			c := junitCase{Name: t.Name, ClassName: f.Path, Time: xmlSeconds(t.Duration), SystemOut: cdata(t.Output)}
+			problem := &junitProblem{Message: firstLine(t.Message), Text: fmt.Sprintf("%s:%d: %s", f.Path, t.Line, t.Message)}
+			switch t.Status {
+			case Fail:
+				problem.Type = "assertion"
+				c.Failure = problem
+				suite.Failures++
+			case Error:
+				problem.Type = "error"
+				c.Error = problem
+				suite.Errors++
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+		suite.Tests = len(suite.Cases)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Suites = append(report.Suites, suite)
+	}
+	report.Time = xmlSeconds(total)
+
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func xmlSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
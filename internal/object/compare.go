@@ -0,0 +1,135 @@
+package object
+
+import "strings"
+
+// 判断两个值是否结构相等
+// 数组与哈希逐个比较元素，函数比较参数、函数体与闭包环境
+func Equal(a, b Object) bool {
+	if a == b {
+		return true
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	if cmp, ok := compareIntegers(a, b); ok {
+		return cmp == 0
+	}
+	switch a := a.(type) {
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *Null:
+		return true
+	case *Array:
+		other := b.(*Array)
+		if len(a.Elements) != len(other.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !Equal(a.Elements[i], other.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		other := b.(*Hash)
+		if a.Len() != other.Len() {
+			return false
+		}
+		for _, pair := range a.entries {
+			value, ok := other.Get(pair.Key)
+			if !ok || !Equal(pair.Value, value) {
+				return false
+			}
+		}
+		return true
+	case *Function:
+		other := b.(*Function)
+		if a.Env != other.Env || len(a.Parameters) != len(other.Parameters) {
+			return false
+		}
+		for i := range a.Parameters {
+			if a.Parameters[i].Value != other.Parameters[i].Value {
+				return false
+			}
+		}
+		return a.Body == other.Body || a.Body.String() == other.Body.String()
+	case *ReturnValue:
+		return Equal(a.Value, b.(*ReturnValue).Value)
+	case *Error:
+		return a.Message == b.(*Error).Message
+	case *Quote:
+		return a.Node.String() == b.(*Quote).Node.String()
+	default:
+		return false
+	}
+}
+
+// 比较两个值的顺序，a 小于、等于、大于 b 时分别返回 -1、0、1
+// 整数、字符串、布尔值与 null 可以和同类型比较，数组按字典序比较；不可比较时返回 false
+func Compare(a, b Object) (int, bool) {
+	if a.Type() != b.Type() {
+		return 0, false
+	}
+	if cmp, ok := compareIntegers(a, b); ok {
+		return cmp, true
+	}
+	switch a := a.(type) {
+	case *String:
+		return strings.Compare(a.Value, b.(*String).Value), true
+	case *Boolean:
+		return compareInt64(boolToInt64(a.Value), boolToInt64(b.(*Boolean).Value)), true
+	case *Null:
+		return 0, true
+	case *Array:
+		other := b.(*Array)
+		for i := 0; i < len(a.Elements) && i < len(other.Elements); i++ {
+			cmp, ok := Compare(a.Elements[i], other.Elements[i])
+			if !ok {
+				return 0, false
+			}
+			if cmp != 0 {
+				return cmp, true
+			}
+		}
+		return compareInt64(int64(len(a.Elements)), int64(len(other.Elements))), true
+	default:
+		return 0, false
+	}
+}
+
+// 比较两个整数，Integer 与 BigInteger 可以混合比较
+func compareIntegers(a, b Object) (int, bool) {
+	if a, ok := a.(*Integer); ok {
+		if b, ok := b.(*Integer); ok {
+			return compareInt64(a.Value, b.Value), true
+		}
+	}
+	x, ok := ToBigInt(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := ToBigInt(b)
+	if !ok {
+		return 0, false
+	}
+	return x.Cmp(y), true
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt64(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
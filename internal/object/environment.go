@@ -1,23 +1,97 @@
 package object
 
+import "sort"
+
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+
+	// 静态解析过的作用域中的绑定按槽位存放，names 为各槽位的名字
+	slots []Object
+	names []string
 }
 
+// 按名字逐层查找，用于未经 resolver 解析的代码与槽位尚未赋值时的回退
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
+	if !ok {
+		obj, ok = e.slot(name)
+	}
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
 	return obj, ok
 }
 
+func (e *Environment) slot(name string) (Object, bool) {
+	for i, n := range e.names {
+		if n == name && e.slots[i] != nil {
+			return e.slots[i], true
+		}
+	}
+	return nil, false
+}
+
+// 向外跨过 depth 层环境后按槽位读取，slot 为负数时按名字读取该层的绑定；
+// 找不到或槽位尚未赋值时返回 false，调用方应退回到 Get
+func (e *Environment) Lookup(depth, slot int, name string) (Object, bool) {
+	for ; depth > 0 && e != nil; depth-- {
+		e = e.outer
+	}
+	if e == nil {
+		return nil, false
+	}
+	if slot < 0 {
+		obj, ok := e.store[name]
+		return obj, ok
+	}
+	if slot >= len(e.slots) || e.slots[slot] == nil {
+		return nil, false
+	}
+	return e.slots[slot], true
+}
+
+// 按名字绑定，名字是本层的槽位时写入槽位。解析过的绑定应当用 Bind
 func (e *Environment) Set(name string, val Object) Object {
+	for i, n := range e.names {
+		if n == name {
+			e.slots[i] = val
+			return val
+		}
+	}
 	e.store[name] = val
 	return val
 }
 
+// 写入 resolver 分配的槽位，slot 为负数时按名字绑定
+func (e *Environment) Bind(slot int, name string, val Object) Object {
+	if slot < 0 {
+		return e.Set(name, val)
+	}
+	e.slots[slot] = val
+	return val
+}
+
+// 返回外层环境，全局环境返回 nil
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// 返回当前作用域中绑定的名字，不包括外层作用域
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	for i, name := range e.names {
+		if _, ok := e.store[name]; !ok && e.slots[i] != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
 	return &Environment{store: s, outer: nil}
@@ -27,4 +101,14 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
 	return env
-}
\ No newline at end of file
+}
+
+// 创建按槽位存放绑定的内层环境，names 为 resolver 分配的槽位
+func NewScopedEnvironment(outer *Environment, names []string) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	if len(names) > 0 {
+		env.slots = make([]Object, len(names))
+		env.names = names
+	}
+	return env
+}
@@ -0,0 +1,154 @@
+package object
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// 哈希按插入顺序保存键值对
+// 哈希值相同的键放在同一个桶中，按值比较区分，避免冲突时互相覆盖
+type Hash struct {
+	buckets map[HashKey][]*HashPair
+	entries []*HashPair
+}
+
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]*HashPair)}
+}
+
+// 计算值的哈希键，数组与哈希按结构计算；值不可哈希时返回 false
+func HashKeyOf(obj Object) (HashKey, bool) {
+	switch obj := obj.(type) {
+	case Hashable:
+		return obj.HashKey(), true
+	case *Array:
+		h := fnv.New64a()
+		for _, e := range obj.Elements {
+			key, ok := HashKeyOf(e)
+			if !ok {
+				return HashKey{}, false
+			}
+			writeHashKey(h, key)
+		}
+		return HashKey{Type: obj.Type(), Value: h.Sum64()}, true
+	case *Hash:
+		// 哈希的键值对与顺序无关，逐对计算后相加
+		var sum uint64
+		for _, pair := range obj.entries {
+			key, _ := HashKeyOf(pair.Key)
+			value, ok := HashKeyOf(pair.Value)
+			if !ok {
+				return HashKey{}, false
+			}
+			h := fnv.New64a()
+			writeHashKey(h, key)
+			writeHashKey(h, value)
+			sum += h.Sum64()
+		}
+		return HashKey{Type: obj.Type(), Value: sum}, true
+	default:
+		return HashKey{}, false
+	}
+}
+
+func writeHashKey(h interface{ Write([]byte) (int, error) }, key HashKey) {
+	var buf [8]byte
+	h.Write([]byte(key.Type))
+	binary.LittleEndian.PutUint64(buf[:], key.Value)
+	h.Write(buf[:])
+}
+
+// 判断值能否作为哈希键
+func IsHashable(obj Object) bool {
+	_, ok := HashKeyOf(obj)
+	return ok
+}
+
+// 查找键所在的键值对
+func (h *Hash) lookup(key Object) (HashKey, *HashPair, bool) {
+	hashed, ok := HashKeyOf(key)
+	if !ok {
+		return hashed, nil, false
+	}
+	for _, pair := range h.buckets[hashed] {
+		if Equal(pair.Key, key) {
+			return hashed, pair, true
+		}
+	}
+	return hashed, nil, true
+}
+
+// 设置键值，已存在的键保持原有位置；键不可哈希时返回 false
+func (h *Hash) Set(key, value Object) bool {
+	hashed, pair, ok := h.lookup(key)
+	if !ok {
+		return false
+	}
+	if pair != nil {
+		pair.Value = value
+		return true
+	}
+	if h.buckets == nil {
+		h.buckets = make(map[HashKey][]*HashPair)
+	}
+	pair = &HashPair{Key: key, Value: value}
+	h.buckets[hashed] = append(h.buckets[hashed], pair)
+	h.entries = append(h.entries, pair)
+	return true
+}
+
+func (h *Hash) Get(key Object) (Object, bool) {
+	_, pair, _ := h.lookup(key)
+	if pair == nil {
+		return nil, false
+	}
+	return pair.Value, true
+}
+
+func (h *Hash) Delete(key Object) {
+	hashed, pair, _ := h.lookup(key)
+	if pair == nil {
+		return
+	}
+	h.buckets[hashed] = removePair(h.buckets[hashed], pair)
+	if len(h.buckets[hashed]) == 0 {
+		delete(h.buckets, hashed)
+	}
+	h.entries = removePair(h.entries, pair)
+}
+
+func removePair(pairs []*HashPair, pair *HashPair) []*HashPair {
+	for i, p := range pairs {
+		if p == pair {
+			return append(pairs[:i:i], pairs[i+1:]...)
+		}
+	}
+	return pairs
+}
+
+func (h *Hash) Len() int { return len(h.entries) }
+
+// 按插入顺序返回所有键值对
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, len(h.entries))
+	for i, pair := range h.entries {
+		pairs[i] = *pair
+	}
+	return pairs
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, pair := range h.entries {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
@@ -4,11 +4,20 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"io"
+	"math/big"
 	"monkey/internal/ast"
+	"monkey/internal/token"
 	"strings"
 )
 
-type BuiltinFunction func(args ...Object) Object
+// 内置函数所在的求值过程，用来回调 Monkey 函数、输出内容
+type Runtime interface {
+	ApplyFunction(fn Object, args ...Object) Object
+	Writer() io.Writer
+}
+
+type BuiltinFunction func(rt Runtime, args ...Object) Object
 
 type ObjectType string
 
@@ -31,6 +40,7 @@ type Hashable interface {
 }
 
 var _ Object = (*Integer)(nil)
+var _ Object = (*BigInteger)(nil)
 var _ Object = (*Boolean)(nil)
 var _ Object = (*Null)(nil)
 var _ Object = (*ReturnValue)(nil)
@@ -43,6 +53,7 @@ var _ Object = (*Array)(nil)
 var _ Hashable = (*String)(nil)
 var _ Hashable = (*Boolean)(nil)
 var _ Hashable = (*Integer)(nil)
+var _ Hashable = (*BigInteger)(nil)
 
 type HashKey struct {
 	Type  ObjectType
@@ -64,6 +75,43 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// 超出 int64 范围的整数，整数运算溢出时自动提升
+// 类型与 Integer 相同，对 Monkey 代码透明
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Inspect() string  { return bi.Value.String() }
+func (bi *BigInteger) Type() ObjectType { return INTEGER_OBJ }
+func (bi *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	if bi.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(bi.Value.Bytes())
+	return HashKey{Type: bi.Type(), Value: h.Sum64()}
+}
+
+// 返回 n 对应的整数对象，在 int64 范围内时使用 Integer
+func NewInteger(n *big.Int) Object {
+	if n.IsInt64() {
+		return &Integer{Value: n.Int64()}
+	}
+	return &BigInteger{Value: n}
+}
+
+// 将整数对象转换为 big.Int，不是整数时返回 false
+func ToBigInt(obj Object) (*big.Int, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value), true
+	case *BigInteger:
+		return obj.Value, true
+	default:
+		return nil, false
+	}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -103,6 +151,9 @@ type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Slots      []string       // 调用时环境中各槽位的名字，来自 ast.FunctionLiteral
+	Name       string         // let 绑定的名字，匿名函数为空
+	Pos        token.Position // 函数字面量的位置
 }
 
 func (f *Function) Inspect() string {
@@ -134,7 +185,10 @@ func (s *String) HashKey() HashKey {
 }
 
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn      BuiltinFunction
+	Doc     string // 签名与说明
+	MinArgs int    // 最少参数个数
+	MaxArgs int    // 最多参数个数，-1 表示不限
 }
 
 func (b *Builtin) Inspect() string  { return "builtin function" }
@@ -162,23 +216,6 @@ type HashPair struct {
 	Value Object
 }
 
-type Hash struct {
-	Pairs map[HashKey]HashPair
-}
-
-func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
-	var out bytes.Buffer
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
-	}
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
-	return out.String()
-}
-
 type Quote struct {
 	Node ast.Node
 }
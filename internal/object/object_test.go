@@ -19,4 +19,176 @@ func TestStringHashKey(t *testing.T) {
     if hello1.HashKey() == diff1.HashKey() {
         t.Errorf("strings with different content have same hash keys")
     }
-}
\ No newline at end of file
+}
+// 哈希值固定的键，用于模拟冲突
+type collidingKey struct{ name string }
+
+func (c *collidingKey) Type() ObjectType { return "COLLIDING" }
+func (c *collidingKey) Inspect() string  { return c.name }
+func (c *collidingKey) HashKey() HashKey { return HashKey{Type: c.Type(), Value: 42} }
+
+func TestHashCollisions(t *testing.T) {
+	a := &collidingKey{name: "a"}
+	b := &collidingKey{name: "b"}
+	if a.HashKey() != b.HashKey() {
+		t.Fatalf("keys should collide")
+	}
+
+	h := NewHash()
+	h.Set(a, &Integer{Value: 1})
+	h.Set(b, &Integer{Value: 2})
+	if h.Len() != 2 {
+		t.Fatalf("colliding keys overwrote each other. len=%d", h.Len())
+	}
+	for key, want := range map[Object]int64{a: 1, b: 2} {
+		value, ok := h.Get(key)
+		if !ok {
+			t.Fatalf("no value for key %s", key.Inspect())
+		}
+		if value.(*Integer).Value != want {
+			t.Errorf("wrong value for key %s. want=%d, got=%d", key.Inspect(), want, value.(*Integer).Value)
+		}
+	}
+
+	h.Delete(a)
+	if _, ok := h.Get(a); ok {
+		t.Errorf("deleted key still present")
+	}
+	if _, ok := h.Get(b); !ok {
+		t.Errorf("deleting a colliding key removed the other one")
+	}
+	if h.Inspect() != "{b: 2}" {
+		t.Errorf("wrong Inspect. got=%q", h.Inspect())
+	}
+}
+
+func TestStructuralHashKeys(t *testing.T) {
+	arr1 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	arr2 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	arr3 := &Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}
+
+	key1, ok1 := HashKeyOf(arr1)
+	key2, ok2 := HashKeyOf(arr2)
+	key3, _ := HashKeyOf(arr3)
+	if !ok1 || !ok2 {
+		t.Fatalf("arrays of hashable values should be hashable")
+	}
+	if key1 != key2 {
+		t.Errorf("arrays with same content have different hash keys")
+	}
+	if key1 == key3 {
+		t.Errorf("arrays with different order have same hash keys")
+	}
+
+	h1 := NewHash()
+	h1.Set(&String{Value: "x"}, &Integer{Value: 1})
+	h1.Set(&String{Value: "y"}, arr1)
+	h2 := NewHash()
+	h2.Set(&String{Value: "y"}, arr2)
+	h2.Set(&String{Value: "x"}, &Integer{Value: 1})
+	hk1, _ := HashKeyOf(h1)
+	hk2, _ := HashKeyOf(h2)
+	if hk1 != hk2 {
+		t.Errorf("hashes with same pairs in different order have different hash keys")
+	}
+
+	outer := NewHash()
+	outer.Set(arr1, &String{Value: "array"})
+	outer.Set(h1, &String{Value: "hash"})
+	if value, ok := outer.Get(arr2); !ok || value.Inspect() != "array" {
+		t.Errorf("lookup by equal array failed. got=%v", value)
+	}
+	if value, ok := outer.Get(h2); !ok || value.Inspect() != "hash" {
+		t.Errorf("lookup by equal hash failed. got=%v", value)
+	}
+
+	withFn := &Array{Elements: []Object{&Function{}}}
+	if IsHashable(withFn) {
+		t.Errorf("array containing a function should not be hashable")
+	}
+	if outer.Set(withFn, &Integer{Value: 1}) {
+		t.Errorf("Set with unhashable key should fail")
+	}
+}
+
+func TestEqualAndCompare(t *testing.T) {
+	one := &Integer{Value: 1}
+	two := &Integer{Value: 2}
+	a := &String{Value: "a"}
+	arr := func(elements ...Object) *Array { return &Array{Elements: elements} }
+
+	equal := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{one, &Integer{Value: 1}, true},
+		{one, two, false},
+		{one, a, false},
+		{&Null{}, &Null{}, true},
+		{arr(one, a), arr(&Integer{Value: 1}, &String{Value: "a"}), true},
+		{arr(one), arr(one, one), false},
+		{&Error{Message: "x"}, &Error{Message: "x"}, true},
+	}
+	for i, tt := range equal {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("equal[%d]: Equal(%s, %s) = %t, want %t", i, tt.a.Inspect(), tt.b.Inspect(), got, tt.expected)
+		}
+	}
+
+	compare := []struct {
+		a, b     Object
+		expected int
+		ok       bool
+	}{
+		{one, two, -1, true},
+		{two, one, 1, true},
+		{a, &String{Value: "b"}, -1, true},
+		{&Boolean{Value: true}, &Boolean{Value: false}, 1, true},
+		{arr(one, two), arr(one, one), 1, true},
+		{arr(one), arr(one), 0, true},
+		{arr(one), arr(a), 0, false},
+		{one, a, 0, false},
+		{NewHash(), NewHash(), 0, false},
+	}
+	for i, tt := range compare {
+		got, ok := Compare(tt.a, tt.b)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("compare[%d]: Compare(%s, %s) = (%d, %t), want (%d, %t)", i, tt.a.Inspect(), tt.b.Inspect(), got, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestScopedEnvironment(t *testing.T) {
+	global := NewEnvironment()
+	global.Set("g", &Integer{Value: 1})
+	fn := NewScopedEnvironment(global, []string{"a", "b"})
+	fn.Bind(0, "a", &Integer{Value: 2})
+	fn.Bind(-1, "other", &Integer{Value: 3})
+	inner := NewScopedEnvironment(fn, nil)
+
+	lookups := []struct {
+		depth, slot int
+		name        string
+		expected    int64
+		ok          bool
+	}{
+		{1, 0, "a", 2, true},
+		{1, 1, "b", 0, false}, // 槽位尚未赋值
+		{2, -1, "g", 1, true},
+		{1, -1, "other", 3, true},
+		{3, -1, "g", 0, false},
+	}
+	for _, tt := range lookups {
+		obj, ok := inner.Lookup(tt.depth, tt.slot, tt.name)
+		if ok != tt.ok || ok && obj.(*Integer).Value != tt.expected {
+			t.Errorf("Lookup(%d, %d, %q) = (%v, %t), want (%d, %t)", tt.depth, tt.slot, tt.name, obj, ok, tt.expected, tt.ok)
+		}
+	}
+
+	if obj, ok := inner.Get("a"); !ok || obj.(*Integer).Value != 2 {
+		t.Errorf("Get should find values stored in slots")
+	}
+	if names := fn.Names(); len(names) != 2 || names[0] != "a" || names[1] != "other" {
+		t.Errorf("wrong names. got=%v", names)
+	}
+}
@@ -1,11 +1,14 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"monkey/internal/ast"
 	"monkey/internal/lexer"
 	"monkey/internal/token"
 	"strconv"
+	"strings"
 )
 
 type (
@@ -29,6 +32,8 @@ const (
 	PRODUCT
 	// 前缀表达式
 	PREFIX
+	// **
+	POWER
 	// 括号、函数调用
 	CALL
 	// [
@@ -45,6 +50,8 @@ var precedences = map[token.TokenType]int{
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
+	token.POWER:    POWER,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
 }
@@ -54,6 +61,11 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 	errors    []string
+	details   []*Error      // 与 errors 一一对应的带位置的错误
+	lexErrors int           // 已收集的词法错误数
+	comments  []token.Token // 词法分析器保留注释时收集的注释
+	spans     map[ast.Node]Span
+	nodes     []ast.Node // 记录了范围的节点，按解析完成的顺序排列
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -62,6 +74,7 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{l: l,
 		errors:         []string{},
+		spans:          make(map[ast.Node]Span),
 		prefixParseFns: make(map[token.TokenType]prefixParseFn),
 		infixParseFns:  make(map[token.TokenType]infixParseFn),
 	}
@@ -76,11 +89,13 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE_HEAD, p.parseTemplateLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	// 注册括号解析函数
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.FOR, p.parseForExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 
 	// 注册中缀解析函数
@@ -88,6 +103,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
@@ -109,6 +126,18 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	// 注释不参与语法分析
+	for p.peekToken.Type == token.COMMENT {
+		p.comments = append(p.comments, p.peekToken)
+		p.peekToken = p.l.NextToken()
+	}
+	// 收集新产生的词法错误
+	lexErrors := p.l.Errors()
+	for _, err := range lexErrors[p.lexErrors:] {
+		p.errors = append(p.errors, err.Error())
+		p.details = append(p.details, &Error{Pos: err.Pos, End: err.Pos, Msg: err.Msg})
+	}
+	p.lexErrors = len(lexErrors)
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -123,10 +152,62 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// 带位置的语法错误
+type Error struct {
+	Pos token.Position // 出错的词法单元的起点
+	End token.Position // 出错的词法单元的终点
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// 返回带位置的错误，顺序与 Errors 相同
+func (p *Parser) DetailedErrors() []*Error {
+	return p.details
+}
+
+// 记录发生在 tok 处的错误
+func (p *Parser) errorAt(tok token.Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.details = append(p.details, &Error{Pos: tok.Pos, End: tok.End, Msg: msg})
+}
+
+// 返回已读取的注释，只有词法分析器保留注释时才有内容
+func (p *Parser) Comments() []token.Token {
+	return p.comments
+}
+
+// 语法节点在源码中的范围
+type Span struct {
+	Start token.Position // 第一个词法单元的起点
+	End   token.Position // 最后一个词法单元的终点
+}
+
+// 返回节点在源码中的范围
+func (p *Parser) Span(node ast.Node) (Span, bool) {
+	span, ok := p.spans[node]
+	return span, ok
+}
+
+// 按解析完成的顺序返回所有记录了范围的节点，子节点总在父节点之前
+func (p *Parser) Nodes() []ast.Node {
+	return p.nodes
+}
+
+// 记录从 start 到当前词法单元的节点范围，括号等包围同一节点时扩大已有的范围
+func (p *Parser) record(node ast.Node, start token.Token) {
+	if _, ok := p.spans[node]; !ok {
+		p.nodes = append(p.nodes, node)
+	}
+	p.spans[node] = Span{Start: start.Pos, End: p.curToken.End}
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 
 	hash := &ast.HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Pairs = []ast.HashPair{}
 
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
@@ -138,7 +219,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		// 解析value
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
 		// 如果下一个token是逗号，继续解析
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -155,7 +236,15 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.peekToken, msg)
+}
+
+// 返回中缀运算符的优先级，不是中缀运算符时返回 LOWEST
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -191,7 +280,9 @@ func (p *Parser) ParseProgram() *ast.Program {
 	for p.curToken.Type != token.EOF {
 		// 解析语句
 		stmt := p.parseStatement()
-		program.Statements = append(program.Statements, stmt)
+		if stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
 		// 读取下一个token
 		p.nextToken()
 	}
@@ -199,25 +290,61 @@ func (p *Parser) ParseProgram() *ast.Program {
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	start := p.curToken
 	switch p.curToken.Type {
 	case token.LET:
 		// 解析let语句
-		return p.parseLetStatement()
+		stmt := p.parseLetStatement()
+		if stmt == nil {
+			// 直接返回 stmt 会得到带类型的 nil
+			return nil
+		}
+		p.record(stmt, start)
+		return stmt
 	case token.RETURN:
 		// 解析return语句
-		return p.parseReturnStatement()
+		stmt := p.parseReturnStatement()
+		p.record(stmt, start)
+		return stmt
 	default:
-		return p.parseExpressionStatement()
+		stmt := p.parseExpressionStatement()
+		p.record(stmt, start)
+		return stmt
 	}
 }
 
-// 解析数组索引表达式
+// 解析数组索引表达式与切片表达式
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
-	// 读取下一个token
+	tok := p.curToken
+	var index ast.Expression
+	// 切片可以省略起始位置，如 xs[:n]
+	if !p.peekTokenIs(token.COLON) {
+		// 读取下一个token
+		p.nextToken()
+		// 解析索引表达式
+		index = p.parseExpression(LOWEST)
+	}
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(tok, left, index)
+	}
+	exp := &ast.IndexExpression{Token: tok, Left: left, Index: index}
+	// 期望下一个token是]
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return exp
+}
+
+// 解析切片表达式，当前token是切片起始位置（或[）
+func (p *Parser) parseSliceExpression(tok token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+	// 读取冒号
 	p.nextToken()
-	// 解析索引表达式
-	exp.Index = p.parseExpression(LOWEST)
+	// 切片可以省略结束位置，如 s[2:]
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
 	// 期望下一个token是]
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
@@ -278,6 +405,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 	// 标识符
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.record(stmt.Name, p.curToken)
 	// 解析等号
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -347,6 +475,50 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// 解析 for-in 表达式，如 for (k, v in h) { ... }
+func (p *Parser) parseForExpression() ast.Expression {
+	expression := &ast.ForExpression{Token: p.curToken}
+	// 解析for后面的左括号
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	// 解析循环变量
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	expression.Names = append(expression.Names, p.parseLoopVariable())
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		expression.Names = append(expression.Names, p.parseLoopVariable())
+	}
+	// 解析 in 关键字
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Iterable = p.parseExpression(LOWEST)
+	// 解析右括号
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	// 解析循环体的左大括号
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = p.parseBlockStatement()
+	return expression
+}
+
+// 解析循环变量
+func (p *Parser) parseLoopVariable() *ast.Identifier {
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.record(ident, p.curToken)
+	return ident
+}
+
 // 解析函数参数
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifier := []*ast.Identifier{}
@@ -359,6 +531,7 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	p.nextToken()
 	// 解析参数
 	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.record(ident, p.curToken)
 	identifier = append(identifier, ident)
 	// 如果下一个token是逗号，说明还有参数
 	for p.peekTokenIs(token.COMMA) {
@@ -368,6 +541,7 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 		p.nextToken()
 		// 解析参数
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.record(ident, p.curToken)
 		identifier = append(identifier, ident)
 	}
 	// 解析右括号
@@ -393,6 +567,10 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		// 读取下一个token
 		p.nextToken()
 	}
+	if p.curTokenIs(token.EOF) {
+		p.errorAt(p.curToken, fmt.Sprintf("expected next token to be %s, got EOF instead", token.RBRACE))
+	}
+	p.record(block, block.Token)
 	return block
 
 }
@@ -435,8 +613,12 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		p.noPrefixParseFnError(p.curToken.Type)
 		return nil
 	}
+	start := p.curToken
 	// 解析前缀表达式
 	leftExp := prefix()
+	if leftExp != nil {
+		p.record(leftExp, start)
+	}
 
 	// 解析中缀表达式
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
@@ -449,6 +631,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		p.nextToken()
 		// 解析中缀表达式
 		leftExp = infix(leftExp)
+		if leftExp != nil {
+			p.record(leftExp, start)
+		}
 	}
 
 	return leftExp
@@ -478,22 +663,90 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// 解析模板字符串，文本片段与插值表达式交替出现，空文本片段被省略
+func (p *Parser) parseTemplateLiteral() ast.Expression {
+	template := &ast.TemplateLiteral{Token: p.curToken}
+	for {
+		if p.curToken.Literal != "" {
+			text := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+			p.record(text, p.curToken)
+			template.Parts = append(template.Parts, text)
+		}
+		if p.curTokenIs(token.TEMPLATE_TAIL) {
+			return template
+		}
+
+		p.nextToken()
+		expression := p.parseExpression(LOWEST)
+		if expression == nil {
+			return nil
+		}
+		template.Parts = append(template.Parts, expression)
+
+		if p.peekTokenIs(token.TEMPLATE_MIDDLE) {
+			p.nextToken()
+		} else if !p.expectPeek(token.TEMPLATE_TAIL) {
+			return nil
+		}
+	}
+}
+
 // 解析整型字面量
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	// defer untrace(trace("parseIntegerLiteral"))
 	literal := &ast.IntegerLiteral{Token: p.curToken}
 	// 解析整型字面量
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	value, err := parseInteger(p.curToken.Literal)
 	if err != nil {
-		msg := "could not parse %q as integer"
-		p.errors = append(p.errors, fmt.Sprintf(msg, p.curToken.Literal))
+		p.errorAt(p.curToken, err.Error())
 		return nil
 	}
-	// 整型字面量值
-	literal.Value = value
+	// 整型字面量值，超出 int64 时保存为大整数
+	if value.IsInt64() {
+		literal.Value = value.Int64()
+	} else {
+		literal.Big = value
+	}
 	return literal
 }
 
+// 十进制指数的上限，避免 1e999999999 这样的字面量在解析时耗尽内存
+const maxIntegerExponent = 1 << 20
+
+// 解析整型字面量，支持 0x、0o、0b 前缀、下划线分隔符与十进制指数（如 1e3）
+func parseInteger(literal string) (*big.Int, error) {
+	mantissa, exponent := literal, ""
+	prefixed := len(literal) > 1 && literal[0] == '0' && strings.ContainsRune("xXoObB", rune(literal[1]))
+	i := strings.IndexAny(literal, "eE")
+	if i >= 0 && !prefixed {
+		mantissa, exponent = literal[:i], literal[i+1:]
+	}
+
+	value, ok := new(big.Int).SetString(mantissa, 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer literal %q", literal)
+	}
+	if i < 0 || prefixed {
+		return value, nil
+	}
+
+	exp, err := strconv.Atoi(exponent)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return nil, fmt.Errorf("invalid exponent in integer literal %q", literal)
+	}
+	// 超出 int 的指数被截断为 int 的最值，仍按正负处理
+	if exp < 0 {
+		return nil, fmt.Errorf("negative exponent in integer literal %q", literal)
+	}
+	if value.Sign() == 0 {
+		return value, nil
+	}
+	if exp > maxIntegerExponent {
+		return nil, fmt.Errorf("integer literal %s too large (max exponent %d)", literal, maxIntegerExponent)
+	}
+	return value.Mul(value, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)), nil
+}
+
 // 解析布尔值
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
@@ -501,7 +754,7 @@ func (p *Parser) parseBoolean() ast.Expression {
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := "no prefix parse function for %s found"
-	p.errors = append(p.errors, fmt.Sprintf(msg, t))
+	p.errorAt(p.curToken, fmt.Sprintf(msg, t))
 }
 
 // 解析前缀表达式
@@ -529,6 +782,10 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 	// 获取当前运算符的优先级
 	precedence := p.curPrecedence()
+	// ** 是右结合的
+	if p.curTokenIs(token.POWER) {
+		precedence--
+	}
 	// 读取下一个token
 	p.nextToken()
 	// 解析右侧表达式
@@ -41,10 +41,10 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
 			continue
 		}
 
@@ -54,7 +54,7 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			continue
 		}
 
-		testFunc(value)
+		testFunc(pair.Value)
 	}
 
 }
@@ -101,19 +101,25 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	expected := map[string]int64{
-		"one":   1,
-		"two":   2,
-		"three": 3,
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+		if literal.String() != expected[i].key {
+			t.Errorf("pair %d has wrong key. want=%q, got=%q", i, expected[i].key, literal.String())
 		}
-		expectedValue := expected[literal.String()]
-		testIntegerLiteral(t, value, expectedValue)
+		testIntegerLiteral(t, pair.Value, expected[i].value)
 	}
 
 }
@@ -144,6 +150,50 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		start interface{}
+		end   interface{}
+	}{
+		{"myArray[1:3]", 1, 3},
+		{"myArray[:n]", nil, "n"},
+		{"myArray[2:]", 2, nil},
+		{"myArray[:]", nil, nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatment. got=%T", program.Statements[0])
+		}
+		sliceExp, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+		if !testIdentifier(t, sliceExp.Left, "myArray") {
+			return
+		}
+		for _, bound := range []struct {
+			exp      ast.Expression
+			expected interface{}
+		}{{sliceExp.Start, tt.start}, {sliceExp.End, tt.end}} {
+			if bound.expected == nil {
+				if bound.exp != nil {
+					t.Errorf("%s: bound should be nil. got=%s", tt.input, bound.exp)
+				}
+				continue
+			}
+			testLiteralExpression(t, bound.exp, bound.expected)
+		}
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -202,6 +252,97 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestNumericLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0x1F", 31},
+		{"0XFF", 255},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0xFF_FF", 65535},
+		{"1e3", 1000},
+		{"2E+2", 200},
+		{"1_5e1", 150},
+		{"0e99", 0},
+		{"0e99999999999999999999", 0},
+		{"9223372036854775807", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral, got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("%s: literal.Value not %d, got=%d", tt.input, tt.expected, literal.Value)
+		}
+		if literal.TokenLiteral() != tt.input {
+			t.Errorf("literal.TokenLiteral not %s, got=%s", tt.input, literal.TokenLiteral())
+		}
+	}
+}
+
+// 超出 int64 范围的字面量保存为大整数
+func TestBigNumericLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"0xFFFFFFFFFFFFFFFFF", "295147905179352825855"},
+		{"1e19", "10000000000000000000"},
+		{"1_000e20", "100000000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		literal := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IntegerLiteral)
+		if literal.Big == nil || literal.Big.String() != tt.expected || literal.Value != 0 {
+			t.Errorf("%s: wrong literal. want Big=%s, got Value=%d Big=%v", tt.input, tt.expected, literal.Value, literal.Big)
+		}
+	}
+}
+
+func TestNumericLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1e1048577", "integer literal 1e1048577 too large (max exponent 1048576)"},
+		{"1e99999999999999999999", "integer literal 1e99999999999999999999 too large (max exponent 1048576)"},
+		{"0x", `invalid integer literal "0x"`},
+		{"0b102", `invalid integer literal "0b102"`},
+		{"1__0", `invalid integer literal "1__0"`},
+		{"12abc", `invalid integer literal "12abc"`},
+		{"1e", `invalid exponent in integer literal "1e"`},
+		{"1e-2", `negative exponent in integer literal "1e-2"`},
+		{"1e-99999999999999999999", `negative exponent in integer literal "1e-99999999999999999999"`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s: wrong errors. want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestBooleanExpression(t *testing.T) {
 
 	input := "true;false"
@@ -267,6 +408,206 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}
 }
 
+func TestForExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		names    []string
+		iterable string
+	}{
+		{"for (x in xs) { x }", []string{"x"}, "xs"},
+		{"for (k, v in h) { k }", []string{"k", "v"}, "h"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement, got=%T", program.Statements[0])
+		}
+		exp, ok := stmt.Expression.(*ast.ForExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.ForExpression, got=%T", stmt.Expression)
+		}
+		if len(exp.Names) != len(tt.names) {
+			t.Fatalf("wrong number of loop variables. want=%d, got=%d", len(tt.names), len(exp.Names))
+		}
+		for i, name := range tt.names {
+			testIdentifier(t, exp.Names[i], name)
+		}
+		testIdentifier(t, exp.Iterable, tt.iterable)
+		if len(exp.Body.Statements) != 1 {
+			t.Errorf("body is not 1 statements, got=%d", len(exp.Body.Statements))
+		}
+	}
+
+	for _, input := range []string{"for x in xs { x }", "for (x xs) { x }", "for (1 in xs) { x }"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected parser errors", input)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// add two numbers
+let add = fn(a, b) { a + b }; /* trailing */
+add(1, /* inline */ 2);`
+
+	l := lexer.New(input)
+	l.KeepComments(true)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "let add = fn(a,b)(a + b);add(1, 2)" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+
+	expected := []string{"// add two numbers", "/* trailing */", "/* inline */"}
+	comments := p.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments. want=%d, got=%d", len(expected), len(comments))
+	}
+	for i, c := range comments {
+		if c.Literal != expected[i] {
+			t.Errorf("comments[%d] wrong. want=%q, got=%q", i, expected[i], c.Literal)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	l := lexer.New("let 总数 = x2 + 总数;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "let 总数 = (x2 + 总数);" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	l := lexer.New("let s = \"\xfe\";")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "1:10: invalid UTF-8 encoding 0xfe" {
+		t.Fatalf("wrong errors. got=%q", errors)
+	}
+}
+
+func TestTemplateLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		parts    []string
+		expected string
+	}{
+		{`"a ${x} b"`, []string{"a ", "x", " b"}, "a ${x} b"},
+		{`"${x + 1}${y}"`, []string{"(x + 1)", "y"}, "${(x + 1)}${y}"},
+		{`"n=${len("${s}")}"`, []string{"n=", "len(${s})"}, "n=${len(${s})}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		template, ok := stmt.Expression.(*ast.TemplateLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.TemplateLiteral, got=%T", stmt.Expression)
+		}
+		if len(template.Parts) != len(tt.parts) {
+			t.Fatalf("wrong number of parts. want=%d, got=%d", len(tt.parts), len(template.Parts))
+		}
+		for i, part := range template.Parts {
+			if part.String() != tt.parts[i] {
+				t.Errorf("parts[%d] wrong. want=%q, got=%q", i, tt.parts[i], part.String())
+			}
+		}
+		if template.String() != tt.expected {
+			t.Errorf("template.String() wrong. want=%q, got=%q", tt.expected, template.String())
+		}
+	}
+}
+
+func TestTemplateLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${x`, "expected next token to be TEMPLATE_TAIL, got EOF instead"},
+		{`"a ${x y} b"`, "expected next token to be TEMPLATE_TAIL, got IDENT instead"},
+		{`"a ${} b"`, "no prefix parse function for TEMPLATE_TAIL found"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s: wrong errors. want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestDetailedErrors(t *testing.T) {
+	l := lexer.New("let x = 1;\nlet = 2;\nlet y = \"\xff\" + ;")
+	p := New(l)
+	p.ParseProgram()
+
+	expected := []string{
+		"2:5: expected next token to be IDENT, got = instead",
+		"2:5: no prefix parse function for = found",
+		"3:10: invalid UTF-8 encoding 0xff",
+		"3:15: no prefix parse function for ; found",
+	}
+	errors := p.DetailedErrors()
+	if len(errors) != len(expected) || len(p.Errors()) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d", len(expected), len(errors))
+	}
+	for i, err := range errors {
+		if err.Error() != expected[i] {
+			t.Errorf("errors[%d] wrong. want=%q, got=%q", i, expected[i], err.Error())
+		}
+	}
+	if end := errors[0].End.String(); end != "2:6" {
+		t.Errorf("wrong end position. want=2:6, got=%s", end)
+	}
+}
+
+func TestNoNilStatementsAfterErrors(t *testing.T) {
+	l := lexer.New("let = 1; let x 2; fn() { let = 3; y }; z")
+	p := New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+
+	statements := program.Statements
+	for _, stmt := range program.Statements {
+		if es, ok := stmt.(*ast.ExpressionStatement); ok {
+			if fn, ok := es.Expression.(*ast.FunctionLiteral); ok {
+				statements = append(statements, fn.Body.Statements...)
+			}
+		}
+	}
+	for _, stmt := range statements {
+		if ast.IsNil(stmt) {
+			t.Errorf("statement is a typed nil %T", stmt)
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 	l := lexer.New(input)
@@ -536,6 +877,13 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
 		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
 		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"a + b % c", "(a + (b % c))"},
+		{"a % b * c", "((a % b) * c)"},
+		{"a ** b ** c", "(a ** (b ** c))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"-a ** b", "(-(a ** b))"},
+		{"a ** -b", "(a ** (-b))"},
+		{"a[1] ** 2", "((a[1]) ** 2)"},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,235 @@
+// 网页版 Monkey 练习场：编辑、运行程序，查看语法树与词法单元，分享代码片段
+package playground
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"mime"
+	"monkey/internal/ast"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/token"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+//go:embed index.html
+var page []byte
+
+const maxSource = 64 << 10 // 源码的最大字节数
+
+type Server struct {
+	Dir         string        // 保存分享的代码片段的目录
+	Command     []string      // 沙箱进程的命令行，进程会设置 SandboxEnv 环境变量
+	Timeout     time.Duration // 每次运行的时间上限
+	MemoryLimit int64         // 沙箱进程的堆内存上限（字节）
+	DataLimit   int64         // 沙箱进程启动后最多再映射的内存（字节），0 表示按 MemoryLimit 估算
+	OutputLimit int           // puts 输出的最大字节数
+
+	mux     *http.ServeMux
+	running chan struct{} // 限制同时运行的沙箱个数
+}
+
+// 用默认的限制创建服务，command 为沙箱进程的命令行
+func NewServer(dir string, command []string) *Server {
+	s := &Server{
+		Dir:         dir,
+		Command:     command,
+		Timeout:     5 * time.Second,
+		MemoryLimit: 64 << 20,
+		OutputLimit: 1 << 20,
+		mux:         http.NewServeMux(),
+		running:     make(chan struct{}, 4),
+	}
+	s.mux.HandleFunc("/", s.index)
+	s.mux.HandleFunc("/api/run", s.handleRun)
+	s.mux.HandleFunc("/api/ast", s.handleAST)
+	s.mux.HandleFunc("/api/tokens", s.handleTokens)
+	s.mux.HandleFunc("/api/share", s.handleShare)
+	s.mux.HandleFunc("/api/snippets/", s.handleSnippet)
+	return s
+}
+
+// 数据段余量的默认值：栈最多可达 MemoryLimit，增长时新旧两份栈同时存在，再为堆、GC 与运行时留出余量。
+// 带插桩的运行时（如 -race）需要的更多，应当显式设置 DataLimit
+func (s *Server) dataLimit() int64 {
+	if s.DataLimit > 0 {
+		return s.DataLimit
+	}
+	return 4*s.MemoryLimit + 64<<20
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+type request struct {
+	Source string `json:"source"`
+}
+
+// 读取 POST 的 JSON 请求，出错时已写好响应
+func readSource(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return "", false
+	}
+	// 其他网页的表单无法发送 JSON，跨域的 fetch 要先通过 CORS 预检，而服务不允许跨域
+	if t, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || t != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return "", false
+	}
+	var req request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSource+1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad request: "+err.Error())
+		return "", false
+	}
+	if len(req.Source) > maxSource {
+		writeError(w, http.StatusRequestEntityTooLarge, "source too large")
+		return "", false
+	}
+	return req.Source, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	src, ok := readSource(w, r)
+	if !ok {
+		return
+	}
+	// 语法错误不必启动沙箱
+	p := parser.New(lexer.New(src))
+	p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		writeJSON(w, http.StatusOK, &Result{Error: syntaxError(p), Duration: "0s"})
+		return
+	}
+
+	// 排队等待时也计入时间上限，请求取消时放弃
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+	select {
+	case s.running <- struct{}{}:
+		defer func() { <-s.running }()
+	case <-ctx.Done():
+		writeError(w, http.StatusServiceUnavailable, "server busy, try again")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.run(ctx, src))
+}
+
+func (s *Server) handleAST(w http.ResponseWriter, r *http.Request) {
+	src, ok := readSource(w, r)
+	if !ok {
+		return
+	}
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		writeJSON(w, http.StatusOK, map[string]string{"error": syntaxError(p)})
+		return
+	}
+	data, err := ast.EncodeJSON(program)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]json.RawMessage{"ast": data})
+}
+
+type tokenInfo struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Pos     token.Position  `json:"pos"`
+}
+
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request) {
+	src, ok := readSource(w, r)
+	if !ok {
+		return
+	}
+	l := lexer.New(src)
+	l.KeepComments(true)
+	tokens := []tokenInfo{}
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+		tokens = append(tokens, tokenInfo{tok.Type, tok.Literal, tok.Pos})
+	}
+	resp := map[string]interface{}{"tokens": tokens}
+	var errs []string
+	for _, err := range l.Errors() {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		resp["errors"] = errs
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// 片段以内容的哈希命名，相同的代码得到相同的链接
+var snippetID = regexp.MustCompile(`^[0-9a-f]{12}$`)
+
+func (s *Server) handleShare(w http.ResponseWriter, r *http.Request) {
+	src, ok := readSource(w, r)
+	if !ok {
+		return
+	}
+	sum := sha256.Sum256([]byte(src))
+	id := hex.EncodeToString(sum[:])[:12]
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := os.WriteFile(filepath.Join(s.Dir, id+".mk"), []byte(src), 0644); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": id})
+}
+
+func (s *Server) handleSnippet(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Path[len("/api/snippets/"):]
+	if !snippetID.MatchString(id) {
+		writeError(w, http.StatusNotFound, "snippet not found")
+		return
+	}
+	src, err := os.ReadFile(filepath.Join(s.Dir, id+".mk"))
+	if errors.Is(err, fs.ErrNotExist) {
+		writeError(w, http.StatusNotFound, "snippet not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, request{Source: string(src)})
+}
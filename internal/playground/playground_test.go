@@ -0,0 +1,209 @@
+package playground
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 带 -race 构建时为真，见 race_test.go
+var raceEnabled bool
+
+// 测试二进制同时充当沙箱进程
+func TestMain(m *testing.M) {
+	if limits := os.Getenv(SandboxEnv); limits != "" {
+		memory, data, _ := ParseLimits(limits)
+		os.Exit(Sandbox(os.Stdin, os.Stdout, os.Stderr, memory, data))
+	}
+	// 带 -race 时沙箱进程退出前默认会等待一秒
+	os.Setenv("GORACE", "atexit_sleep_ms=0")
+	os.Exit(m.Run())
+}
+
+func newTestServer(t *testing.T) *Server {
+	s := NewServer(t.TempDir(), []string{os.Args[0]})
+	s.Timeout = time.Second
+	s.MemoryLimit = 32 << 20
+	s.OutputLimit = 1 << 10
+	if raceEnabled {
+		// 竞争检测器为栈与堆另外映射数倍的影子内存
+		s.DataLimit = 8*s.MemoryLimit + 64<<20
+	}
+	return s
+}
+
+func post(t *testing.T, s *Server, path, src string, v interface{}) int {
+	t.Helper()
+	body, _ := json.Marshal(request{Source: src})
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("%s: bad response %q: %s", path, w.Body.String(), err)
+	}
+	return w.Code
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string
+		value  string
+		error  string
+	}{
+		{`puts("hi"); 1 + 2`, "hi\n", "3", ""},
+		{`let x = 1;`, "", "", ""},
+		{`puts(1); 1 + "a"`, "1\n", "", "ERROR: type mismatch: INTEGER + STRING"},
+		{`let x = ;`, "", "", "1:9: no prefix parse function for ; found"},
+		// 深度有限但永不结束的递归
+		{`let g = fn(n) { if (n == 0) { 0 } else { g(n - 1) + g(n - 1) } }; g(60)`, "", "", "time limit exceeded (1s)"},
+		{`let f = fn(s, n) { if (n == 0) { len(s) } else { f(s + s, n - 1) } }; f("x", 40)`, "", "", "memory limit exceeded (32MB)"},
+		// 一次分配就超出硬上限，来不及定期检查
+		{`len("ab" * 100000000)`, "", "", "memory limit exceeded (32MB)"},
+		{`let f = fn(n) { f(n + 1) }; f(0)`, "", "", "stack overflow: recursion too deep"},
+		{`for (i in range(100)) { puts("0123456789abcdef") }`, strings.Repeat("0123456789abcdef\n", 61)[:1024], "", "output limit exceeded (1024 bytes)"},
+	}
+
+	s := newTestServer(t)
+	for _, tt := range tests {
+		var r Result
+		if code := post(t, s, "/api/run", tt.input, &r); code != http.StatusOK {
+			t.Fatalf("%s: wrong status %d", tt.input, code)
+		}
+		if r.Output != tt.output || r.Value != tt.value || r.Error != tt.error {
+			t.Errorf("%s: wrong result.\nwant output=%q value=%q error=%q\ngot  output=%q value=%q error=%q",
+				tt.input, tt.output, tt.value, tt.error, r.Output, r.Value, r.Error)
+		}
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	s := newTestServer(t)
+	done := make(chan Result)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			var r Result
+			post(t, s, "/api/run", "puts("+strconv.Itoa(i)+")", &r)
+			done <- r
+		}(i)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 8; i++ {
+		r := <-done
+		seen[r.Output] = true
+	}
+	if len(seen) != 8 {
+		t.Errorf("outputs mixed up between runs: %v", seen)
+	}
+}
+
+func TestASTAndTokens(t *testing.T) {
+	s := newTestServer(t)
+
+	var tree struct {
+		AST struct {
+			Kind       string `json:"kind"`
+			Statements []struct {
+				Kind string `json:"kind"`
+			} `json:"statements"`
+		} `json:"ast"`
+		Error string `json:"error"`
+	}
+	post(t, s, "/api/ast", "let x = 1; x", &tree)
+	if tree.AST.Kind != "Program" || len(tree.AST.Statements) != 2 || tree.AST.Statements[0].Kind != "LetStatement" {
+		t.Errorf("wrong ast: %+v", tree)
+	}
+	post(t, s, "/api/ast", "let = 1", &tree)
+	if !strings.HasPrefix(tree.Error, "1:5: ") {
+		t.Errorf("wrong ast error: %q", tree.Error)
+	}
+
+	var tokens struct {
+		Tokens []tokenInfo `json:"tokens"`
+	}
+	post(t, s, "/api/tokens", "x + 1 // c", &tokens)
+	var got []string
+	for _, tok := range tokens.Tokens {
+		got = append(got, string(tok.Type)+" "+tok.Literal+" "+tok.Pos.String())
+	}
+	want := "IDENT x 1:1, + + 1:3, INT 1 1:5, COMMENT // c 1:7"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("wrong tokens.\nwant=%s\ngot=%s", want, strings.Join(got, ", "))
+	}
+}
+
+func TestSnippets(t *testing.T) {
+	s := newTestServer(t)
+	var shared struct{ ID string }
+	post(t, s, "/api/share", "puts(1)", &shared)
+	if !snippetID.MatchString(shared.ID) {
+		t.Fatalf("wrong id %q", shared.ID)
+	}
+	if data, err := os.ReadFile(s.Dir + "/" + shared.ID + ".mk"); err != nil || string(data) != "puts(1)" {
+		t.Fatalf("snippet not stored: %q, %v", data, err)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/api/snippets/" + shared.ID, http.StatusOK, `{"source":"puts(1)"}`},
+		{"/api/snippets/000000000000", http.StatusNotFound, `{"error":"snippet not found"}`},
+		{"/api/snippets/..secret", http.StatusNotFound, `{"error":"snippet not found"}`},
+		{"/api/run", http.StatusMethodNotAllowed, `{"error":"method not allowed"}`},
+		{"/nope", http.StatusNotFound, "404 page not found"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.status || strings.TrimSpace(w.Body.String()) != tt.body {
+			t.Errorf("%s: wrong response %d %q", tt.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	s := newTestServer(t)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<title>Monkey Playground</title>") {
+		t.Errorf("wrong index page: %d", w.Code)
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		path        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"/api/run", "application/json", "not json", http.StatusBadRequest},
+		{"/api/run", "application/json", `{"source": "` + strings.Repeat("x", maxSource+1) + `"}`, http.StatusRequestEntityTooLarge},
+		{"/api/run", "application/json", `{"source": "` + strings.Repeat("x", 2*maxSource) + `"}`, http.StatusBadRequest},
+		{"/api/run", "application/json; charset=utf-8", `{"source": "1"}`, http.StatusOK},
+		// 其他网页的表单可以直接提交到这里，不能运行或保存
+		{"/api/run", "application/x-www-form-urlencoded", `{"source": "1"}`, http.StatusUnsupportedMediaType},
+		{"/api/share", "text/plain", `{"source": "1"}`, http.StatusUnsupportedMediaType},
+		{"/api/share", "", `{"source": "1"}`, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s %s %.20s: wrong status %d, want %d", tt.path, tt.contentType, tt.body, w.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package playground
+
+func init() {
+	raceEnabled = true
+}
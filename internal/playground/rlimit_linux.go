@@ -0,0 +1,42 @@
+package playground
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// 限制进程可以映射的私有可写内存，在已映射的部分之外最多再映射 allowance 字节，
+// 超出时分配直接失败，Go 运行时随即退出。
+// 已映射的部分因构建方式差别很大（如 -race 的影子内存），所以从 /proc 读取而不是估计
+func limitMemory(allowance int64) error {
+	used, err := dataSize()
+	if err != nil {
+		return err
+	}
+	limit := uint64(used + allowance)
+	return syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{Cur: limit, Max: limit})
+}
+
+// 进程当前的数据段大小（字节），即 /proc/self/status 中的 VmData
+func dataSize() (int64, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if kb, ok := strings.CutPrefix(scanner.Text(), "VmData:"); ok {
+			n, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(kb), " kB"), 10, 64)
+			return n << 10, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("VmData not found in /proc/self/status")
+}
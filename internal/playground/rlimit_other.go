@@ -0,0 +1,8 @@
+//go:build !linux
+
+package playground
+
+// 其他系统上没有可靠的数据段上限，只靠 watch 定期检查
+func limitMemory(allowance int64) error {
+	return nil
+}
@@ -0,0 +1,181 @@
+package playground
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/internal/evaluator"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 设置了这个环境变量的进程作为沙箱运行，值为“堆内存上限,数据段余量”两个字节数，见 ParseLimits 与 Sandbox
+const SandboxEnv = "MONKEY_SANDBOX"
+
+// 运行一段程序的结果
+type Result struct {
+	Output   string `json:"output"`          // puts 的输出
+	Value    string `json:"value,omitempty"` // 程序的值
+	Error    string `json:"error,omitempty"` // 语法错误、运行时错误或超出限制
+	Duration string `json:"duration"`
+}
+
+// 沙箱进程的入口：从 stdin 读取源码并求值，puts 写到 stdout，结束时把 Result 的 JSON 写到 stderr。
+// 堆内存超过 memoryLimit 字节时直接退出进程
+//
+// 进程在启动后最多再映射 dataLimit 字节的内存，单次巨大的分配（如很长的字符串重复、
+// 大整数的乘方）在两次检查之间就会直接失败，由 Server 报告为超出内存上限
+func Sandbox(stdin io.Reader, stdout, stderr io.Writer, memoryLimit, dataLimit int64) int {
+	src, err := io.ReadAll(stdin)
+	if err != nil {
+		return report(stderr, &Result{Error: err.Error()})
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return report(stderr, &Result{Error: syntaxError(p)})
+	}
+	resolver.Resolve(program, nil)
+
+	// 软上限让 GC 更积极地回收，超过后由 watch 结束进程。栈也计入软上限，
+	// 深递归时栈增长会临时同时占用新旧两份，只允许到上限的四分之一，以免 GC 为给栈腾出空间而空转
+	debug.SetMemoryLimit(memoryLimit)
+	debug.SetMaxStack(int(memoryLimit / 4))
+	if err := limitMemory(dataLimit); err != nil {
+		return report(stderr, &Result{Error: "sandbox: cannot limit memory: " + err.Error()})
+	}
+	go watch(stderr, memoryLimit)
+
+	ctx := &evaluator.Context{Output: stdout}
+	result := ctx.Eval(program, object.NewEnvironment())
+	if err, ok := result.(*object.Error); ok {
+		return report(stderr, &Result{Error: "ERROR: " + err.Message})
+	}
+	if result == nil {
+		return report(stderr, &Result{})
+	}
+	return report(stderr, &Result{Value: result.Inspect()})
+}
+
+func report(w io.Writer, r *Result) int {
+	json.NewEncoder(w).Encode(r)
+	if r.Error != "" {
+		return 1
+	}
+	return 0
+}
+
+// 定期检查堆的大小，超过上限时报告错误并退出
+func watch(stderr io.Writer, limit int64) {
+	var m runtime.MemStats
+	for range time.Tick(5 * time.Millisecond) {
+		runtime.ReadMemStats(&m)
+		if int64(m.HeapAlloc) > limit {
+			report(stderr, &Result{Error: fmt.Sprintf("memory limit exceeded (%s)", megabytes(limit))})
+			os.Exit(1)
+		}
+	}
+}
+
+// 解析 SandboxEnv 的值
+func ParseLimits(limits string) (memoryLimit, dataLimit int64, err error) {
+	memory, data, ok := strings.Cut(limits, ",")
+	if !ok {
+		return 0, 0, errors.New("missing data limit")
+	}
+	if memoryLimit, err = strconv.ParseInt(memory, 10, 64); err != nil {
+		return 0, 0, err
+	}
+	if dataLimit, err = strconv.ParseInt(data, 10, 64); err != nil {
+		return 0, 0, err
+	}
+	return memoryLimit, dataLimit, nil
+}
+
+func megabytes(n int64) string {
+	return fmt.Sprintf("%dMB", n>>20)
+}
+
+func syntaxError(p *parser.Parser) string {
+	var lines []string
+	for _, err := range p.DetailedErrors() {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 在子进程中运行源码，超时或输出过多时结束子进程
+func (s *Server) run(ctx context.Context, src string) *Result {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	limits := fmt.Sprintf("%d,%d", s.MemoryLimit, s.dataLimit())
+	cmd.Env = append(os.Environ(), SandboxEnv+"="+limits)
+	cmd.Stdin = strings.NewReader(src)
+	stdout := &limitedBuffer{max: s.OutputLimit, full: cancel}
+	stderr := &limitedBuffer{max: s.OutputLimit + 1<<16, full: cancel}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	err := cmd.Run()
+
+	r := &Result{}
+	switch {
+	case stdout.overflow:
+		r.Error = fmt.Sprintf("output limit exceeded (%d bytes)", s.OutputLimit)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		r.Error = fmt.Sprintf("time limit exceeded (%s)", s.Timeout)
+	default:
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		if json.Unmarshal([]byte(lines[len(lines)-1]), r) != nil {
+			// 沙箱没有正常结束，如栈溢出时 Go 运行时直接退出
+			r.Error = fmt.Sprintf("sandbox failed: %v", err)
+			switch {
+			case strings.Contains(stderr.String(), "stack exceeds"):
+				r.Error = "stack overflow: recursion too deep"
+			case strings.Contains(stderr.String(), "out of memory"),
+				strings.Contains(stderr.String(), "cannot allocate memory"),
+				strings.Contains(stderr.String(), "ThreadSanitizer failed to allocate"):
+				// 分配超出了数据段的硬上限
+				r.Error = fmt.Sprintf("memory limit exceeded (%s)", megabytes(s.MemoryLimit))
+			}
+		}
+	}
+	r.Output = stdout.String()
+	r.Duration = time.Since(start).Round(time.Microsecond).String()
+	return r
+}
+
+// 最多保存 max 字节，超出时调用 full。不嵌入 bytes.Buffer，以免 io.Copy 经由 ReadFrom 绕过上限
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	max      int
+	full     func()
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.max {
+		b.buf.Write(p[:b.max-b.buf.Len()])
+		b.overflow = true
+		b.full()
+		return 0, errors.New("output limit exceeded")
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,152 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// 以 gzip 压缩的 pprof protobuf 格式输出，可用 go tool pprof 查看，如 -http 的火焰图
+// 每个样本是一条调用栈，值依次为调用次数、自身耗时、自身分配的字节数与对象数
+func (prof *Profiler) WritePprof(w io.Writer) error {
+	var b protobuf
+	index := map[string]int64{}
+	str := func(s string) int64 {
+		if i, ok := index[s]; ok {
+			return i
+		}
+		i := int64(len(index))
+		index[s] = i
+		return i
+	}
+	str("")
+
+	for _, t := range [][2]string{{"calls", "count"}, {"time", "nanoseconds"}, {"alloc_space", "bytes"}, {"alloc_objects", "count"}} {
+		var vt protobuf
+		vt.int(1, str(t[0]))
+		vt.int(2, str(t[1]))
+		b.message(1, &vt)
+	}
+
+	// 调用树中每个被调用过的节点是一个样本，按函数 id 深度优先遍历，输出顺序固定
+	var visit func(n *node)
+	visit = func(n *node) {
+		if n.calls > 0 {
+			var ids []uint64
+			for c := n; c != nil; c = c.parent {
+				ids = append(ids, c.fn.id)
+			}
+			var sample protobuf
+			sample.packed(1, ids)
+			sample.packed(2, []uint64{uint64(n.calls), uint64(n.cost.Time), uint64(n.cost.Bytes), uint64(n.cost.Objects)})
+			b.message(2, &sample)
+		}
+		children := make([]*node, 0, len(n.children))
+		for _, c := range n.children {
+			children = append(children, c)
+		}
+		sort.Slice(children, func(i, j int) bool { return children[i].fn.id < children[j].fn.id })
+		for _, c := range children {
+			visit(c)
+		}
+	}
+	if prof.root != nil {
+		visit(prof.root)
+	}
+
+	// 每个函数一个 location，id 与函数相同
+	fns := prof.Functions()
+	sort.Slice(fns, func(i, j int) bool { return fns[i].id < fns[j].id })
+	for _, f := range fns {
+		var line, loc protobuf
+		line.uint(1, f.id)
+		line.int(2, int64(f.Line))
+		loc.uint(1, f.id)
+		loc.message(4, &line)
+		b.message(4, &loc)
+	}
+	for _, f := range fns {
+		var fn protobuf
+		fn.uint(1, f.id)
+		fn.int(2, str(f.Name))
+		fn.int(3, str(f.Name))
+		fn.int(4, str(f.File))
+		fn.int(5, int64(f.Line))
+		b.message(5, &fn)
+	}
+
+	b.int(9, prof.start.UnixNano())
+	b.int(10, int64(prof.duration))
+	var period protobuf
+	period.int(1, str("time"))
+	period.int(2, str("nanoseconds"))
+	b.message(11, &period)
+	b.int(12, 1)
+	b.int(14, str("time")) // 默认显示耗时
+
+	table := make([]string, len(index))
+	for s, i := range index {
+		table[i] = s
+	}
+	for _, s := range table {
+		b.string(6, s)
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(b.buf); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// 只实现 pprof 用到的 protobuf 编码：varint 与长度前缀字段
+type protobuf struct {
+	buf []byte
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.buf = append(b.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	b.buf = append(b.buf, byte(x))
+}
+
+func (b *protobuf) key(field, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (b *protobuf) uint(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.key(field, 0)
+	b.varint(x)
+}
+
+func (b *protobuf) int(field int, x int64) {
+	b.uint(field, uint64(x))
+}
+
+func (b *protobuf) bytes(field int, data []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(data)))
+	b.buf = append(b.buf, data...)
+}
+
+// string_table 中的空字符串也必须写出
+func (b *protobuf) string(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+func (b *protobuf) message(field int, m *protobuf) {
+	b.bytes(field, m.buf)
+}
+
+func (b *protobuf) packed(field int, xs []uint64) {
+	var p protobuf
+	for _, x := range xs {
+		p.varint(x)
+	}
+	b.bytes(field, p.buf)
+}
@@ -0,0 +1,264 @@
+// 统计 Monkey 函数的调用次数、耗时与内存分配，输出文本摘要与 pprof 格式的性能剖析。
+// 统计靠插桩而不是定时采样：每次调用都经过 Tracer 记录，调用次数是精确的，
+// 但大量调用小函数的程序在剖析时会明显变慢
+package profiler
+
+import (
+	"fmt"
+	"io"
+	"monkey/internal/ast"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"runtime/metrics"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// 一个函数定义处的统计，Inclusive 包括被调用的函数，Exclusive 不包括
+type Function struct {
+	Name string // let 绑定的名字，匿名函数为 fn@行:列，顶层代码为 main
+	File string // 未登记的文件（如标准库模块）为空
+	Line int
+	Col  int
+
+	Calls     int
+	Inclusive Cost
+	Exclusive Cost
+	id        uint64
+	active    int // 在调用栈上的次数，递归时只统计最外层的 Inclusive
+}
+
+type Cost struct {
+	Time    time.Duration
+	Bytes   int64 // 分配的字节数
+	Objects int64 // 分配的对象数
+}
+
+func (c *Cost) add(o Cost) {
+	c.Time += o.Time
+	c.Bytes += o.Bytes
+	c.Objects += o.Objects
+}
+
+func (c Cost) sub(o Cost) Cost {
+	return Cost{c.Time - o.Time, c.Bytes - o.Bytes, c.Objects - o.Objects}
+}
+
+type frame struct {
+	fn       *Function
+	node     *node
+	start    Cost // 调用开始时的累计值
+	children Cost // 被调用函数的 Inclusive 之和
+}
+
+// 调用树的节点，从根（main）到节点的路径是一条调用栈，pprof 据此画出火焰图
+type node struct {
+	fn       *Function
+	parent   *node
+	children map[*Function]*node
+	calls    int64
+	cost     Cost // 在这条调用栈上的 Exclusive 之和
+}
+
+func (n *node) child(fn *Function) *node {
+	c, ok := n.children[fn]
+	if !ok {
+		c = &node{fn: fn, parent: n, children: map[*Function]*node{}}
+		n.children[fn] = c
+	}
+	return c
+}
+
+// 实现 evaluator.Tracer。用 Start 与 Stop 包住求值过程。每次调用与返回都读取一次计时与分配
+//
+// 分配按 runtime/metrics 的累计值统计，不会暂停整个程序。运行时以 span 为单位
+// 更新这些值，因此分配是按抽样的方式计入触发更新的函数，调用次数足够多时才准确。
+// 统计本身的耗时与分配记在 overhead 中，从各函数的结果里扣除
+type Profiler struct {
+	files     map[*ast.BlockStatement]string
+	functions map[*ast.BlockStatement]*Function
+	main      *Function
+	root      *node
+	stack     []frame
+	start     time.Time
+	duration  time.Duration
+	metrics   []metrics.Sample
+	overhead  Cost
+}
+
+func New() *Profiler {
+	return &Profiler{
+		files:     map[*ast.BlockStatement]string{},
+		functions: map[*ast.BlockStatement]*Function{},
+		metrics: []metrics.Sample{
+			{Name: "/gc/heap/allocs:bytes"},
+			{Name: "/gc/heap/allocs:objects"},
+		},
+	}
+}
+
+// 登记源文件中定义的函数，p 为解析该文件的解析器。第一个登记的文件的顶层代码记为 main
+func (prof *Profiler) Add(path string, p *parser.Parser) {
+	if prof.main == nil {
+		prof.main = &Function{Name: "main", File: path, Line: 1, Col: 1, id: 1}
+	}
+	for _, node := range p.Nodes() {
+		if fn, ok := node.(*ast.FunctionLiteral); ok {
+			prof.files[fn.Body] = path
+		}
+	}
+}
+
+// 开始统计顶层代码
+func (prof *Profiler) Start() {
+	if prof.main == nil {
+		prof.main = &Function{Name: "main", Line: 1, Col: 1, id: 1}
+	}
+	if prof.root == nil {
+		prof.root = &node{fn: prof.main, children: map[*Function]*node{}}
+	}
+	prof.main.Calls++
+	prof.start = time.Now()
+	prof.stack = []frame{{fn: prof.main, node: prof.root, start: prof.read().sub(prof.overhead)}}
+}
+
+// 结束统计，之后才能输出结果
+func (prof *Profiler) Stop() {
+	// 出错时未返回的调用在这里依次结束
+	for len(prof.stack) > 0 {
+		prof.pop()
+	}
+	prof.duration = time.Since(prof.start)
+}
+
+// 读取当前的累计耗时与分配，包括统计本身的开销
+func (prof *Profiler) read() Cost {
+	metrics.Read(prof.metrics)
+	return Cost{
+		Time:    time.Since(prof.start),
+		Bytes:   int64(prof.metrics[0].Value.Uint64()),
+		Objects: int64(prof.metrics[1].Value.Uint64()),
+	}
+}
+
+// 记录从 enter 开始的统计开销，返回扣除开销后 enter 时刻的累计值
+func (prof *Profiler) account(enter Cost) Cost {
+	at := enter.sub(prof.overhead)
+	prof.overhead.add(prof.read().sub(enter))
+	return at
+}
+
+func (prof *Profiler) Statement(stmt ast.Statement, env *object.Environment) {}
+
+func (prof *Profiler) Call(fn *object.Function, args []object.Object, env *object.Environment) {
+	enter := prof.read()
+	f, ok := prof.functions[fn.Body]
+	if !ok {
+		name := fn.Name
+		if name == "" {
+			name = fmt.Sprintf("fn@%d:%d", fn.Pos.Line, fn.Pos.Column)
+		}
+		f = &Function{Name: name, File: prof.files[fn.Body], Line: fn.Pos.Line, Col: fn.Pos.Column}
+		f.id = uint64(len(prof.functions) + 2)
+		prof.functions[fn.Body] = f
+	}
+	f.Calls++
+	f.active++
+	caller := prof.stack[len(prof.stack)-1].node
+	prof.stack = append(prof.stack, frame{fn: f, node: caller.child(f)})
+	prof.stack[len(prof.stack)-1].start = prof.account(enter)
+}
+
+func (prof *Profiler) Return(fn *object.Function, result object.Object) {
+	if len(prof.stack) > 1 {
+		prof.pop()
+	}
+}
+
+// 结束栈顶的调用，把耗时计入函数与调用栈
+func (prof *Profiler) pop() {
+	enter := prof.read()
+	top := prof.stack[len(prof.stack)-1]
+	inclusive := enter.sub(prof.overhead).sub(top.start)
+	exclusive := inclusive.sub(top.children)
+	prof.stack = prof.stack[:len(prof.stack)-1]
+
+	f := top.fn
+	f.active--
+	if f.active <= 0 {
+		f.Inclusive.add(inclusive)
+	}
+	f.Exclusive.add(exclusive)
+	if len(prof.stack) > 0 {
+		prof.stack[len(prof.stack)-1].children.add(inclusive)
+	}
+	top.node.calls++
+	top.node.cost.add(exclusive)
+	prof.account(enter)
+}
+
+// 所有被调用过的函数与顶层代码，按 Exclusive 耗时从多到少排序
+func (prof *Profiler) Functions() []*Function {
+	var fns []*Function
+	if prof.main != nil && prof.main.Calls > 0 {
+		fns = append(fns, prof.main)
+	}
+	for _, f := range prof.functions {
+		fns = append(fns, f)
+	}
+	sort.Slice(fns, func(i, j int) bool {
+		if fns[i].Exclusive.Time != fns[j].Exclusive.Time {
+			return fns[i].Exclusive.Time > fns[j].Exclusive.Time
+		}
+		return fns[i].id < fns[j].id
+	})
+	return fns
+}
+
+// 定义处，如 main.mk:3:9
+func (f *Function) Site() string {
+	file := f.File
+	if file == "" {
+		file = "?"
+	}
+	return fmt.Sprintf("%s:%d:%d", file, f.Line, f.Col)
+}
+
+// 输出耗时最多的 n 个函数，n <= 0 时输出全部
+func (prof *Profiler) WriteText(w io.Writer, n int) error {
+	fns := prof.Functions()
+	if n > 0 && n < len(fns) {
+		fns = fns[:n]
+	}
+	total := prof.main.Inclusive.Time
+	fmt.Fprintf(w, "Duration: %s, %d functions called\n", prof.duration.Round(time.Microsecond), len(prof.functions))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "calls\tflat\tflat%\tcum\tcum%\tflat alloc\tcum alloc\tcum objects\t\tfunction")
+	for _, f := range fns {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t\t%s %s\n",
+			f.Calls,
+			f.Exclusive.Time.Round(time.Microsecond), share(f.Exclusive.Time, total),
+			f.Inclusive.Time.Round(time.Microsecond), share(f.Inclusive.Time, total),
+			size(f.Exclusive.Bytes), size(f.Inclusive.Bytes), f.Inclusive.Objects,
+			f.Name, f.Site())
+	}
+	return tw.Flush()
+}
+
+func share(d, total time.Duration) string {
+	if total <= 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(d)/float64(total))
+}
+
+func size(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fkB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
@@ -0,0 +1,197 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"monkey/internal/evaluator"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"strings"
+	"testing"
+)
+
+const source = `let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let strs = fn(n) { map(range(n), fn(i) { "x" + str(i) }) };
+let str = fn(i) { "" + type(i) };
+[fib(10), len(strs(5)), unknown];
+`
+
+func profile(t *testing.T) *Profiler {
+	t.Helper()
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	resolver.Resolve(program, nil)
+
+	prof := New()
+	prof.Add("main.mk", p)
+	ctx := &evaluator.Context{Tracer: prof}
+	prof.Start()
+	result := ctx.Eval(program, object.NewEnvironment())
+	prof.Stop()
+	if _, ok := result.(*object.Error); !ok {
+		t.Fatalf("expected error, got %s", result.Inspect())
+	}
+	return prof
+}
+
+func TestProfiler(t *testing.T) {
+	prof := profile(t)
+	fns := map[string]*Function{}
+	for _, f := range prof.Functions() {
+		fns[f.Name] = f
+	}
+
+	tests := []struct {
+		name  string
+		calls int
+		site  string
+	}{
+		{"main", 1, "main.mk:1:1"},
+		{"fib", 177, "main.mk:1:11"},
+		{"strs", 1, "main.mk:2:12"},
+		{"fn@2:34", 5, "main.mk:2:34"},
+		{"str", 5, "main.mk:3:11"},
+	}
+	if len(fns) != len(tests) {
+		t.Fatalf("wrong number of functions. want=%d, got=%d", len(tests), len(fns))
+	}
+	for _, tt := range tests {
+		f, ok := fns[tt.name]
+		if !ok {
+			t.Fatalf("function %s not found", tt.name)
+		}
+		if f.Calls != tt.calls || f.Site() != tt.site {
+			t.Errorf("%s: want calls=%d site=%s, got calls=%d site=%s", tt.name, tt.calls, tt.site, f.Calls, f.Site())
+		}
+		if f.Exclusive.Time > f.Inclusive.Time || f.Exclusive.Bytes > f.Inclusive.Bytes || f.Inclusive.Time > fns["main"].Inclusive.Time {
+			t.Errorf("%s: inconsistent costs. exclusive=%+v, inclusive=%+v", tt.name, f.Exclusive, f.Inclusive)
+		}
+	}
+	// 递归调用只统计最外层的 Inclusive
+	if fns["fib"].Inclusive.Time > fns["main"].Inclusive.Time-fns["main"].Exclusive.Time {
+		t.Errorf("recursive inclusive time counted more than once")
+	}
+	if fns["strs"].Inclusive.Objects < fns["str"].Inclusive.Objects {
+		t.Errorf("allocations not attributed to callers")
+	}
+
+	var out bytes.Buffer
+	prof.WriteText(&out, 2)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "Duration: ") || !strings.HasSuffix(lines[1], "function") {
+		t.Errorf("wrong text summary:\n%s", out.String())
+	}
+}
+
+// 分配是按 span 抽样统计的，分配足够多时才能稳定地计入函数
+func TestAllocations(t *testing.T) {
+	p := parser.New(lexer.New(`let alloc = fn(n) { map(range(n), fn(i) { [i, i, i] }) }; len(alloc(20000))`))
+	program := p.ParseProgram()
+	resolver.Resolve(program, nil)
+
+	prof := New()
+	prof.Add("main.mk", p)
+	ctx := &evaluator.Context{Tracer: prof}
+	prof.Start()
+	ctx.Eval(program, object.NewEnvironment())
+	prof.Stop()
+
+	fns := map[string]*Function{}
+	for _, f := range prof.Functions() {
+		fns[f.Name] = f
+	}
+	alloc, main := fns["alloc"], fns["main"]
+	// 每个元素至少有一个三元素数组
+	if alloc.Inclusive.Bytes < 20000*3*16 || alloc.Inclusive.Objects < 20000 {
+		t.Errorf("allocations not counted. got=%+v", alloc.Inclusive)
+	}
+	if main.Inclusive.Bytes < alloc.Inclusive.Bytes || alloc.Exclusive.Bytes > alloc.Inclusive.Bytes {
+		t.Errorf("inconsistent allocations. main=%+v, alloc=%+v", main.Inclusive, alloc)
+	}
+}
+
+// 读取 protobuf 消息中的字段，varint 字段为值，长度前缀字段为内容
+func fields(t *testing.T, data []byte) map[int][][]byte {
+	t.Helper()
+	result := map[int][][]byte{}
+	varint := func() uint64 {
+		var x uint64
+		for shift := 0; ; shift += 7 {
+			if len(data) == 0 {
+				t.Fatal("truncated varint")
+			}
+			b := data[0]
+			data = data[1:]
+			x |= uint64(b&0x7f) << shift
+			if b < 0x80 {
+				return x
+			}
+		}
+	}
+	for len(data) > 0 {
+		key := varint()
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			x := varint()
+			result[field] = append(result[field], []byte{byte(x)})
+		case 2:
+			n := varint()
+			result[field] = append(result[field], data[:n])
+			data = data[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return result
+}
+
+func TestWritePprof(t *testing.T) {
+	prof := profile(t)
+	var out bytes.Buffer
+	if err := prof.WritePprof(&out); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile := fields(t, data)
+	var table []string
+	for _, s := range profile[6] {
+		table = append(table, string(s))
+	}
+	if len(table) == 0 || table[0] != "" {
+		t.Fatalf("string table must start with the empty string. got=%q", table)
+	}
+	for _, want := range []string{"calls", "time", "alloc_space", "alloc_objects", "fib", "fn@2:34", "main.mk"} {
+		found := false
+		for _, s := range table {
+			found = found || s == want
+		}
+		if !found {
+			t.Errorf("string table does not contain %q", want)
+		}
+	}
+	if len(profile[1]) != 4 {
+		t.Errorf("want 4 sample types, got %d", len(profile[1]))
+	}
+	if len(profile[4]) != 5 || len(profile[5]) != 5 {
+		t.Errorf("want 5 locations and functions, got %d and %d", len(profile[4]), len(profile[5]))
+	}
+	// 调用栈：main、main>fib、main>fib>fib…、main>strs、main>strs>fn、main>strs>fn>str
+	if len(profile[2]) != 1+10+3 {
+		t.Errorf("wrong number of samples. got=%d", len(profile[2]))
+	}
+}
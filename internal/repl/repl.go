@@ -8,6 +8,7 @@ import (
 	"monkey/internal/lexer"
 	"monkey/internal/object"
 	"monkey/internal/parser"
+	"monkey/internal/resolver"
 )
 
 const PROMPT = ">> "
@@ -46,6 +47,8 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
+		// 只为标识符标注槽位，之前输入的变量在解析时未知，不报告未定义的名字
+		resolver.Resolve(program, nil)
 		evaluated := evaluator.Eval(program, env)
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
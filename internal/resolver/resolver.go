@@ -0,0 +1,305 @@
+// 在求值之前对程序做静态的作用域解析：
+// 报告未定义的名字、遮蔽与未使用的绑定，并为标识符标注 (depth, slot)
+package resolver
+
+import (
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/token"
+	"sort"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Undefined Kind = iota // 使用了没有定义的名字
+	Shadowed              // 内层的绑定遮蔽了外层的同名绑定
+	Unused                // 局部的 let 或参数没有被使用
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Undefined:
+		return "undefined"
+	case Shadowed:
+		return "shadowed"
+	default:
+		return "unused"
+	}
+}
+
+type Diagnostic struct {
+	Kind Kind
+	Pos  token.Position
+	End  token.Position
+	Msg  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Msg)
+}
+
+type BindingKind int
+
+const (
+	Let BindingKind = iota
+	Parameter
+	LoopVariable
+)
+
+// 一次声明，参数与循环变量的 Let 为 nil
+type Declaration struct {
+	Name *ast.Identifier
+	Let  *ast.LetStatement
+}
+
+// 作用域中的一个名字。同一作用域中重复的 let 与求值器一样共用同一个绑定
+type Binding struct {
+	Name  string
+	Kind  BindingKind
+	Decls []Declaration // 按源码顺序排列
+	Refs  []*ast.Identifier
+	Scope *Scope
+	Slot  int // 全局作用域中为 -1
+}
+
+// 程序、函数与 for 循环体各自是一个作用域，对应求值时的一层环境；
+// if 的代码块与外层共用作用域
+type Scope struct {
+	Parent   *Scope
+	Node     ast.Node // *ast.Program、*ast.FunctionLiteral 或 *ast.ForExpression
+	Bindings []*Binding
+	names    map[string]*Binding
+}
+
+// 在当前作用域中按名字查找绑定，不查找外层
+func (s *Scope) Lookup(name string) (*Binding, bool) {
+	b, ok := s.names[name]
+	return b, ok
+}
+
+func (s *Scope) function() bool {
+	_, ok := s.Node.(*ast.FunctionLiteral)
+	return ok
+}
+
+type Result struct {
+	Scopes      []*Scope // Scopes[0] 为全局作用域，外层总在内层之前
+	Bindings    map[*ast.Identifier]*Binding
+	Diagnostics []Diagnostic // 按位置排列
+}
+
+type resolver struct {
+	*Result
+	predeclared map[string]bool
+	scope       *Scope
+	pending     []pending // 等外层作用域解析完之后再解析的函数体
+}
+
+type pending struct {
+	fn    *ast.FunctionLiteral
+	scope *Scope // 函数自己的作用域
+}
+
+// 解析程序并就地标注标识符与作用域的槽位。
+// predeclared 为内置函数、REPL 中已定义的变量等不报告为未定义的名字
+func Resolve(program *ast.Program, predeclared []string) *Result {
+	r := &resolver{
+		Result:      &Result{Bindings: map[*ast.Identifier]*Binding{}},
+		predeclared: map[string]bool{},
+	}
+	for _, name := range predeclared {
+		r.predeclared[name] = true
+	}
+
+	r.scope = r.newScope(program, nil)
+	r.statements(program.Statements)
+	// 函数体中的名字可能引用外层之后才声明的绑定，
+	// 所以函数体在外层全部声明之后才解析
+	for len(r.pending) > 0 {
+		p := r.pending[0]
+		r.pending = r.pending[1:]
+		r.function(p.fn, p.scope)
+	}
+
+	r.unused()
+	sort.SliceStable(r.Diagnostics, func(i, j int) bool {
+		return r.Diagnostics[i].Pos.Offset < r.Diagnostics[j].Pos.Offset
+	})
+	return r.Result
+}
+
+func (r *resolver) newScope(node ast.Node, parent *Scope) *Scope {
+	s := &Scope{Parent: parent, Node: node, names: map[string]*Binding{}}
+	r.Scopes = append(r.Scopes, s)
+	return s
+}
+
+func (r *resolver) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		r.node(stmt)
+	}
+}
+
+func (r *resolver) node(node ast.Node) {
+	if ast.IsNil(node) {
+		return
+	}
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		r.statements(node.Statements)
+	case *ast.LetStatement:
+		// 等号右侧先求值，所以名字在 let 语句之后才可见
+		r.node(node.Value)
+		r.declare(node.Name, Let, node)
+	case *ast.ReturnStatement:
+		r.node(node.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.node(node.Expression)
+	case *ast.Identifier:
+		r.use(node)
+	case *ast.PrefixExpression:
+		r.node(node.Right)
+	case *ast.InfixExpression:
+		r.node(node.Left)
+		r.node(node.Right)
+	case *ast.IfExpression:
+		r.node(node.Condition)
+		r.node(node.Consequence)
+		r.node(node.Alternative)
+	case *ast.ForExpression:
+		r.node(node.Iterable)
+		outer := r.scope
+		r.scope = r.newScope(node, outer)
+		for _, name := range node.Names {
+			r.declare(name, LoopVariable, nil)
+		}
+		r.node(node.Body)
+		node.Slots = r.scope.slots()
+		r.scope = outer
+	case *ast.FunctionLiteral:
+		r.pending = append(r.pending, pending{node, r.newScope(node, r.scope)})
+	case *ast.CallExpression:
+		// quote 的参数不求值
+		if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			return
+		}
+		r.node(node.Function)
+		for _, arg := range node.Arguments {
+			r.node(arg)
+		}
+	case *ast.TemplateLiteral:
+		for _, part := range node.Parts {
+			r.node(part)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			r.node(el)
+		}
+	case *ast.IndexExpression:
+		r.node(node.Left)
+		r.node(node.Index)
+	case *ast.SliceExpression:
+		r.node(node.Left)
+		r.node(node.Start)
+		r.node(node.End)
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			r.node(pair.Key)
+			r.node(pair.Value)
+		}
+	}
+}
+
+func (r *resolver) function(fn *ast.FunctionLiteral, scope *Scope) {
+	r.scope = scope
+	for _, param := range fn.Parameters {
+		r.declare(param, Parameter, nil)
+	}
+	if fn.Body != nil {
+		r.statements(fn.Body.Statements)
+	}
+	fn.Slots = scope.slots()
+}
+
+func (s *Scope) slots() []string {
+	names := make([]string, len(s.Bindings))
+	for i, b := range s.Bindings {
+		names[i] = b.Name
+	}
+	return names
+}
+
+func (r *resolver) declare(ident *ast.Identifier, kind BindingKind, let *ast.LetStatement) {
+	if ast.IsNil(ident) {
+		return
+	}
+	b, ok := r.scope.names[ident.Value]
+	if !ok {
+		if outer, _, found := r.lookup(r.scope.Parent, ident.Value); found {
+			r.report(Shadowed, ident, "%s shadows declaration at %s", ident.Value, outer.Decls[0].Name.Token.Pos)
+		}
+		b = &Binding{Name: ident.Value, Kind: kind, Scope: r.scope, Slot: -1}
+		if r.scope.Parent != nil {
+			b.Slot = len(r.scope.Bindings)
+		}
+		r.scope.Bindings = append(r.scope.Bindings, b)
+		r.scope.names[ident.Value] = b
+	}
+	b.Decls = append(b.Decls, Declaration{ident, let})
+	r.Bindings[ident] = b
+	ident.Resolved, ident.Depth, ident.Slot = true, 0, b.Slot
+}
+
+// 从 s 开始逐层向外查找绑定，返回跨过的层数
+func (r *resolver) lookup(s *Scope, name string) (*Binding, int, bool) {
+	for depth := 0; s != nil; depth++ {
+		if b, ok := s.names[name]; ok {
+			return b, depth, true
+		}
+		s = s.Parent
+	}
+	return nil, 0, false
+}
+
+func (r *resolver) use(ident *ast.Identifier) {
+	b, depth, ok := r.lookup(r.scope, ident.Value)
+	if !ok {
+		ident.Resolved = false
+		if !r.predeclared[ident.Value] {
+			r.report(Undefined, ident, "undefined: %s", ident.Value)
+		}
+		return
+	}
+	b.Refs = append(b.Refs, ident)
+	r.Bindings[ident] = b
+	ident.Resolved, ident.Depth, ident.Slot = true, depth, b.Slot
+}
+
+// 全局作用域中的绑定可能被导入或在 REPL 中使用，只检查局部绑定；
+// 以下划线开头的名字表示有意不使用
+func (r *resolver) unused() {
+	for _, s := range r.Scopes[1:] {
+		for _, b := range s.Bindings {
+			if len(b.Refs) > 0 || strings.HasPrefix(b.Name, "_") {
+				continue
+			}
+			switch b.Kind {
+			case Let:
+				r.report(Unused, b.Decls[0].Name, "%s declared and not used", b.Name)
+			case Parameter:
+				r.report(Unused, b.Decls[0].Name, "unused parameter %s", b.Name)
+			}
+		}
+	}
+}
+
+func (r *resolver) report(kind Kind, ident *ast.Identifier, format string, args ...interface{}) {
+	r.Diagnostics = append(r.Diagnostics, Diagnostic{
+		Kind: kind,
+		Pos:  ident.Token.Pos,
+		End:  ident.Token.End,
+		Msg:  fmt.Sprintf(format, args...),
+	})
+}
@@ -0,0 +1,136 @@
+package resolver
+
+import (
+	"monkey/internal/ast"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"reflect"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%q: parser errors: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x", nil},
+		{"puts(y)", []string{"1:6: undefined: y"}},
+		{"let x = x;", []string{"1:9: undefined: x"}},
+		{"y; let y = 1;", []string{"1:1: undefined: y"}},
+		{"if (true) { let a = 1; }; a", nil},
+		{"let f = fn() { g() }; let g = fn() { f() };", nil},
+		{"let f = fn(a, b) { a };", []string{"1:15: unused parameter b"}},
+		{"let f = fn() { let t = 1; 2 };", []string{"1:20: t declared and not used"}},
+		{"let f = fn(_a) { let _t = 1; 2 };", nil},
+		{"for (k, v in {}) { v }", nil},
+		{
+			"let x = 1; let f = fn(x) { let y = 2; fn() { let y = 3; x + y } };",
+			[]string{"1:23: x shadows declaration at 1:5", "1:32: y declared and not used", "1:50: y shadows declaration at 1:32"},
+		},
+		{"let x = 1; let x = x + 1; x", nil},
+		{"quote(a + b)", nil},
+		{"let s = \"${name}\";", []string{"1:12: undefined: name"}},
+		{"len([1]) + nope", []string{"1:12: undefined: nope"}},
+	}
+
+	for _, tt := range tests {
+		result := Resolve(parse(t, tt.input), []string{"len", "puts", "quote"})
+		var got []string
+		for _, d := range result.Diagnostics {
+			got = append(got, d.String())
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%q: wrong diagnostics.\nwant=%q\ngot= %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	input := `let g = 1;
+let f = fn(a, b) {
+	let c = a;
+	for (i in [b]) {
+		let d = fn() { i + c + g + h };
+	}
+};
+let h = 2;`
+	program := parse(t, input)
+	result := Resolve(program, nil)
+
+	f := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	loop := f.Body.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.ForExpression)
+	d := loop.Body.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+
+	if !reflect.DeepEqual(f.Slots, []string{"a", "b", "c"}) {
+		t.Errorf("wrong function slots. got=%v", f.Slots)
+	}
+	if !reflect.DeepEqual(loop.Slots, []string{"i", "d"}) {
+		t.Errorf("wrong loop slots. got=%v", loop.Slots)
+	}
+	if len(d.Slots) != 0 {
+		t.Errorf("expected no slots, got=%v", d.Slots)
+	}
+
+	// i + c + g + h
+	var idents []*ast.Identifier
+	var collect func(e ast.Expression)
+	collect = func(e ast.Expression) {
+		switch e := e.(type) {
+		case *ast.InfixExpression:
+			collect(e.Left)
+			collect(e.Right)
+		case *ast.Identifier:
+			idents = append(idents, e)
+		}
+	}
+	collect(d.Body.Statements[0].(*ast.ExpressionStatement).Expression)
+
+	tests := []struct {
+		name        string
+		depth, slot int
+	}{
+		{"i", 1, 0},
+		{"c", 2, 2},
+		{"g", 3, -1},
+		{"h", 3, -1},
+	}
+	for i, tt := range tests {
+		ident := idents[i]
+		if ident.Value != tt.name || !ident.Resolved || ident.Depth != tt.depth || ident.Slot != tt.slot {
+			t.Errorf("%s: wrong annotation. want=(%d, %d), got=%v (%d, %d)",
+				tt.name, tt.depth, tt.slot, ident.Resolved, ident.Depth, ident.Slot)
+		}
+	}
+
+	h := result.Scopes[0].Bindings[2]
+	if h.Name != "h" || len(h.Refs) != 1 || h.Refs[0] != idents[3] || result.Bindings[idents[3]] != h {
+		t.Errorf("wrong binding for h. got=%+v", h)
+	}
+	if len(result.Scopes) != 4 || result.Scopes[1].Node != f || result.Scopes[2].Node != loop {
+		t.Errorf("wrong scopes. got=%d", len(result.Scopes))
+	}
+}
+
+func TestRedeclaration(t *testing.T) {
+	program := parse(t, "let f = fn() { let x = 1; let x = x + 1; x };")
+	Resolve(program, nil)
+
+	f := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if !reflect.DeepEqual(f.Slots, []string{"x"}) {
+		t.Fatalf("redeclared names should share a slot. got=%v", f.Slots)
+	}
+	second := f.Body.Statements[1].(*ast.LetStatement)
+	if second.Name.Slot != 0 || second.Value.(*ast.InfixExpression).Left.(*ast.Identifier).Slot != 0 {
+		t.Errorf("wrong slots for redeclaration")
+	}
+}
@@ -0,0 +1,47 @@
+let identity = fn(x) { x };
+
+let constant = fn(x) {
+	fn(_ignored) { x }
+};
+
+let compose = fn(f, g) {
+	fn(x) { f(g(x)) }
+};
+
+let pipe = fn(fns) {
+	fn(x) {
+		let iter = fn(fns, acc) {
+			if (len(fns) == 0) {
+				acc
+			} else {
+				iter(rest(fns), first(fns)(acc))
+			}
+		};
+		iter(fns, x)
+	}
+};
+
+let flip = fn(f) {
+	fn(a, b) { f(b, a) }
+};
+
+let partial = fn(f, a) {
+	fn(b) { f(a, b) }
+};
+
+let curry = fn(f) {
+	fn(a) {
+		fn(b) { f(a, b) }
+	}
+};
+
+let times = fn(n, f) {
+	let iter = fn(i, acc) {
+		if (i < n) {
+			iter(i + 1, push(acc, f(i)))
+		} else {
+			acc
+		}
+	};
+	iter(0, [])
+};
@@ -0,0 +1,34 @@
+let get_or = fn(h, key, default) {
+	if (has_key(h, key)) {
+		h[key]
+	} else {
+		default
+	}
+};
+
+let has = fn(h, key) {
+	has_key(h, key)
+};
+
+let get_in = fn(h, path) {
+	if (len(path) == 0) {
+		h
+	} else {
+		if (type(h) == "HASH") {
+			get_in(h[first(path)], rest(path))
+		} else {
+			{}[first(path)]
+		}
+	}
+};
+
+let lookup = fn(h, keys) {
+	let iter = fn(keys, acc) {
+		if (len(keys) == 0) {
+			acc
+		} else {
+			iter(rest(keys), push(acc, h[first(keys)]))
+		}
+	};
+	iter(keys, [])
+};
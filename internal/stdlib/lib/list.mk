@@ -0,0 +1,70 @@
+// 下面同名的绑定会遮住内置的 map、filter 与 reduce，先以私有名字保存
+let _map = map;
+let _filter = filter;
+let _reduce = reduce;
+
+let map = fn(arr, f) {
+	_map(arr, f)
+};
+
+let filter = fn(arr, f) {
+	_filter(arr, f)
+};
+
+let reduce = fn(arr, f, initial) {
+	_reduce(arr, f, initial)
+};
+
+let each = fn(arr, f) {
+	reduce(arr, fn(acc, x) { f(x); acc }, arr);
+};
+
+let reverse = fn(arr) {
+	reduce(arr, fn(acc, x) { concat([x], acc) }, []);
+};
+
+let concat = fn(a, b) {
+	reduce(b, fn(acc, x) { push(acc, x) }, a);
+};
+
+let take = fn(arr, n) {
+	if (n == 0) {
+		[]
+	} else {
+		if (len(arr) == 0) {
+			[]
+		} else {
+			concat([first(arr)], take(rest(arr), n - 1))
+		}
+	}
+};
+
+let drop = fn(arr, n) {
+	if (n == 0) {
+		arr
+	} else {
+		if (len(arr) == 0) {
+			[]
+		} else {
+			drop(rest(arr), n - 1)
+		}
+	}
+};
+
+let contains = fn(arr, value) {
+	reduce(arr, fn(acc, x) {
+		if (acc) {
+			true
+		} else {
+			x == value
+		}
+	}, false);
+};
+
+let sum = fn(arr) {
+	reduce(arr, fn(acc, x) { acc + x }, 0);
+};
+
+let count = fn(arr, f) {
+	len(filter(arr, f));
+};
@@ -0,0 +1,59 @@
+let abs = fn(n) {
+	if (n < 0) { -n } else { n }
+};
+
+let sign = fn(n) {
+	if (n < 0) { -1 } else { if (n > 0) { 1 } else { 0 } }
+};
+
+let max = fn(a, b) {
+	if (a > b) { a } else { b }
+};
+
+let min = fn(a, b) {
+	if (a < b) { a } else { b }
+};
+
+let clamp = fn(n, low, high) {
+	max(low, min(n, high))
+};
+
+let mod = fn(a, b) {
+	a % b
+};
+
+let is_even = fn(n) {
+	mod(n, 2) == 0
+};
+
+let is_odd = fn(n) {
+	mod(n, 2) != 0
+};
+
+let pow = fn(base, exp) {
+	base ** exp
+};
+
+let factorial = fn(n) {
+	if (n < 2) {
+		1
+	} else {
+		n * factorial(n - 1)
+	}
+};
+
+let gcd = fn(a, b) {
+	if (b == 0) {
+		abs(a)
+	} else {
+		gcd(b, mod(a, b))
+	}
+};
+
+let lcm = fn(a, b) {
+	if (a == 0) {
+		0
+	} else {
+		abs(a * b) / gcd(a, b)
+	}
+};
@@ -0,0 +1,42 @@
+let repeat = fn(s, n) {
+	if (n > 0) {
+		s + repeat(s, n - 1)
+	} else {
+		""
+	}
+};
+
+let join = fn(arr, sep) {
+	if (len(arr) == 0) {
+		""
+	} else {
+		let iter = fn(arr, acc) {
+			if (len(arr) == 0) {
+				acc
+			} else {
+				iter(rest(arr), acc + sep + first(arr))
+			}
+		};
+		iter(rest(arr), first(arr))
+	}
+};
+
+let pad_left = fn(s, width, pad) {
+	if (len(s) < width) {
+		repeat(pad, width - len(s)) + s
+	} else {
+		s
+	}
+};
+
+let pad_right = fn(s, width, pad) {
+	if (len(s) < width) {
+		s + repeat(pad, width - len(s))
+	} else {
+		s
+	}
+};
+
+let surround = fn(s, left, right) {
+	left + s + right
+};
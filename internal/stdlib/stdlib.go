@@ -0,0 +1,41 @@
+package stdlib
+
+import (
+	"embed"
+	"path"
+	"sort"
+	"strings"
+)
+
+// 标准库模块源码，编译时嵌入二进制
+//
+//go:embed lib/*.mk
+var files embed.FS
+
+const ext = ".mk"
+
+// 返回模块源码
+func Source(name string) (string, bool) {
+	if strings.ContainsAny(name, "/\\.") {
+		return "", false
+	}
+	data, err := files.ReadFile(path.Join("lib", name+ext))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// 返回所有模块名
+func Modules() []string {
+	entries, err := files.ReadDir("lib")
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ext))
+	}
+	sort.Strings(names)
+	return names
+}
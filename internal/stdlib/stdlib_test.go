@@ -0,0 +1,66 @@
+package stdlib_test
+
+import (
+	"monkey/internal/evaluator"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/stdlib"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	for _, name := range []string{"list", "string", "hash", "math", "functional"} {
+		if _, ok := stdlib.Source(name); !ok {
+			t.Errorf("module %q not found", name)
+		}
+	}
+	for _, name := range []string{"nope", "../stdlib", "lib/list"} {
+		if _, ok := stdlib.Source(name); ok {
+			t.Errorf("module %q should not be found", name)
+		}
+	}
+}
+
+// testdata 下的每个 Monkey 测试文件求值为 [名称, 实际值, 期望值] 的数组
+func TestModules(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*_test.mk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test files found")
+	}
+
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := parser.New(lexer.New(string(source)))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Errorf("%s: parser errors: %s", file, strings.Join(p.Errors(), "; "))
+			continue
+		}
+
+		evaluated := evaluator.Eval(program, object.NewEnvironment())
+		cases, ok := evaluated.(*object.Array)
+		if !ok {
+			t.Errorf("%s: result is not Array. got=%T (%+v)", file, evaluated, evaluated)
+			continue
+		}
+
+		for _, c := range cases.Elements {
+			tc := c.(*object.Array).Elements
+			got, want := tc[1].Inspect(), tc[2].Inspect()
+			if got != want {
+				t.Errorf("%s: %s: got=%s, want=%s", file, tc[0].Inspect(), got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,14 @@
+let f = import("functional");
+let inc = fn(x) { x + 1 };
+let double = fn(x) { x * 2 };
+
+[
+	["identity", f["identity"](5), 5],
+	["constant", f["constant"](5)(1), 5],
+	["compose", f["compose"](inc, double)(3), 7],
+	["pipe", f["pipe"]([inc, double])(3), 8],
+	["flip", f["flip"](fn(a, b) { a - b })(1, 10), 9],
+	["partial", f["partial"](fn(a, b) { a - b }, 10)(1), 9],
+	["curry", f["curry"](fn(a, b) { a - b })(10)(1), 9],
+	["times", f["times"](3, double), [0, 2, 4]]
+]
@@ -0,0 +1,12 @@
+let hash = import("hash");
+let h = {"a": 1, "b": {"c": 2}};
+
+[
+	["get_or present", hash["get_or"](h, "a", 0), 1],
+	["get_or missing", hash["get_or"](h, "z", 0), 0],
+	["has", hash["has"](h, "a"), true],
+	["has missing", hash["has"](h, "z"), false],
+	["get_in", hash["get_in"](h, ["b", "c"]), 2],
+	["get_in missing", hash["get_in"](h, ["z", "c"]), hash["get_in"](h, ["z"])],
+	["lookup", hash["lookup"](h, ["a", "a"]), [1, 1]]
+]
@@ -0,0 +1,22 @@
+let list = import("list");
+let double = fn(x) { x * 2 };
+let even = fn(x) { (x / 2) * 2 == x };
+
+[
+	["map", list["map"]([1, 2, 3], double), [2, 4, 6]],
+	["map empty", list["map"]([], double), []],
+	["filter", list["filter"]([1, 2, 3, 4], even), [2, 4]],
+	["reduce", list["reduce"]([1, 2, 3, 4], fn(acc, x) { acc + x }, 0), 10],
+	["reduce empty", list["reduce"]([], fn(acc, x) { acc + x }, 0), 0],
+	["filter none", list["filter"]([1, 3], even), []],
+	["each", list["each"]([1, 2], fn(x) { x }), [1, 2]],
+	["reverse", list["reverse"]([1, 2, 3]), [3, 2, 1]],
+	["concat", list["concat"]([1], [2, 3]), [1, 2, 3]],
+	["take", list["take"]([1, 2, 3], 2), [1, 2]],
+	["take past end", list["take"]([1], 5), [1]],
+	["drop", list["drop"]([1, 2, 3], 2), [3]],
+	["contains", list["contains"]([1, "a", true], "a"), true],
+	["contains missing", list["contains"]([1, 2], 3), false],
+	["sum", list["sum"]([1, 2, 3]), 6],
+	["count", list["count"]([1, 2, 3, 4], even), 2]
+]
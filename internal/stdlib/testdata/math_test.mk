@@ -0,0 +1,16 @@
+let math = import("math");
+
+[
+	["abs", math["abs"](-3), 3],
+	["sign", math["sign"](-3), -1],
+	["max", math["max"](2, 5), 5],
+	["min", math["min"](2, 5), 2],
+	["clamp", math["clamp"](12, 0, 10), 10],
+	["mod", math["mod"](7, 3), 1],
+	["is_even", math["is_even"](4), true],
+	["is_odd", math["is_odd"](4), false],
+	["pow", math["pow"](2, 10), 1024],
+	["factorial", math["factorial"](5), 120],
+	["gcd", math["gcd"](12, 18), 6],
+	["lcm", math["lcm"](4, 6), 12]
+]
@@ -0,0 +1,12 @@
+let string = import("string");
+
+[
+	["repeat", string["repeat"]("ab", 3), "ababab"],
+	["repeat zero", string["repeat"]("ab", 0), ""],
+	["join", string["join"](["a", "b", "c"], ", "), "a, b, c"],
+	["join empty", string["join"]([], ", "), ""],
+	["pad_left", string["pad_left"]("7", 3, "0"), "007"],
+	["pad_right", string["pad_right"]("ab", 4, "."), "ab.."],
+	["pad wide", string["pad_left"]("abcd", 2, " "), "abcd"],
+	["surround", string["surround"]("x", "(", ")"), "(x)"]
+]
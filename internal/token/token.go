@@ -1,20 +1,41 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
+// 源码中的位置，行与列从 1 开始，列按字符（码点）计数
+type Position struct {
+	Offset int `json:"offset"` // 字节偏移
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // 词法单元第一个字符的位置
+	End     Position // 词法单元之后第一个字符的位置
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // 注释，只有在词法分析器保留注释时出现
 
 	// 标识符 + 字面量
 	IDENT = "IDENT" // add, foobar, x, y, ...
 	INT   = "INT"   // 123456789
-	STRING = "STRING" // "abc"
+	STRING = "STRING" // "abc"、`raw`
+
+	// 模板字符串 "a ${x} b ${y} c" 依次产生 TEMPLATE_HEAD "a "、x、TEMPLATE_MIDDLE " b "、y、TEMPLATE_TAIL " c"
+	TEMPLATE_HEAD   = "TEMPLATE_HEAD"
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE"
+	TEMPLATE_TAIL   = "TEMPLATE_TAIL"
 	// TODO: 
 	// FLOAT = "FLOAT" // 123.456
 
@@ -26,6 +47,8 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
 
 	LBRACKET = "["
     RBRACKET = "]"
@@ -63,8 +86,9 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETRUN"
+	FOR      = "FOR"
+	IN       = "IN"
 	// TODO:
-	// FOR = "FOR"
 	// WHILE = "WHILE"
 	// BREAK = "BREAK"
 	// CONTINUE = "CONTINUE"
@@ -79,6 +103,8 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"for":    FOR,
+	"in":     IN,
 }
 
 func LookupIdent(ident string) TokenType {
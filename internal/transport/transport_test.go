@@ -0,0 +1,53 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewConn(nil, &buf)
+	c.Write(map[string]int{"a": 1})
+	c.Write("变量")
+
+	if got := buf.String(); got != "Content-Length: 7\r\n\r\n{\"a\":1}Content-Length: 8\r\n\r\n\"变量\"" {
+		t.Fatalf("wrong output. got=%q", got)
+	}
+
+	c = NewConn(&buf, nil)
+	for _, want := range []string{`{"a":1}`, `"变量"`} {
+		body, err := c.Read()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(body) != want {
+			t.Errorf("wrong body. want=%q, got=%q", want, body)
+		}
+	}
+	if _, err := c.Read(); err != io.EOF {
+		t.Errorf("expected io.EOF, got=%v", err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Content-Type: x\r\n\r\n{}", "missing Content-Length header"},
+		{"Content-Length: x\r\n\r\n", `invalid Content-Length " x"`},
+		{"garbage\r\n\r\n", `malformed header "garbage"`},
+		{"Content-Length: 10\r\n\r\n{}", "reading body: unexpected EOF"},
+		{"Content-Length: 2\r\n", "reading header: EOF"},
+	}
+
+	for _, tt := range tests {
+		_, err := NewConn(strings.NewReader(tt.input), nil).Read()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"monkey/internal/cli"
 	"monkey/internal/repl"
 	"os"
 	"os/user"
@@ -9,6 +10,11 @@ import (
 
 
 func main() {
+	// 带参数时执行子命令，如 monkey fmt
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -18,4 +24,4 @@ func main() {
 	fmt.Printf("Feel free to type in commands\n")
 
 	repl.Start(os.Stdin, os.Stdout)
-}
\ No newline at end of file
+}